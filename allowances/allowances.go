@@ -0,0 +1,338 @@
+package allowances
+
+import (
+	"Shop/audit"
+	"Shop/config"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/treasury"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// MaxAmount сколько монет правило может начислить одному сотруднику за запуск.
+const MaxAmount = 100000
+
+var (
+	ErrRuleNotFound   = errors.New("правило не найдено")
+	ErrInvalidAmount  = fmt.Errorf("сумма должна быть от 1 до %d", MaxAmount)
+	ErrReasonRequired = errors.New("нужно указать причину до 255 символов")
+	ErrInvalidZone    = errors.New("неизвестный часовой пояс")
+	ErrUnknownRole    = errors.New("неизвестная роль в аудитории")
+	ErrNeverFires     = errors.New("расписание не срабатывает в ближайшие пять лет")
+)
+
+// RuleInput параметры правила, которые задает админ.
+type RuleInput struct {
+	Amount   uint     `json:"amount" example:"500"`
+	Roles    []string `json:"roles" example:"EMPLOYEE_ROLE"`
+	Schedule string   `json:"schedule" example:"0 9 1 * *"`
+	Timezone string   `json:"timezone,omitempty" example:"Europe/Moscow"`
+	Reason   string   `json:"reason" example:"Ежемесячное начисление"`
+	Enabled  bool     `json:"enabled"`
+}
+
+// nextRun следующий запуск правила после now в его часовом поясе.
+func nextRun(rule models.AllowanceRule, now time.Time) (*time.Time, error) {
+	cron, err := ParseCron(rule.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		return nil, ErrInvalidZone
+	}
+	next := cron.Next(now.In(location))
+	if next.IsZero() {
+		return nil, ErrNeverFires
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// Save создает правило name или заменяет его параметры и пересчитывает следующий запуск.
+// Выключенное правило не запускается. Вызывать внутри транзакции.
+func Save(tx *gorm.DB, adminID uuid.UUID, name string, input RuleInput, now time.Time) (models.AllowanceRule, error) {
+	rule := models.AllowanceRule{Name: name}
+	if input.Amount == 0 || input.Amount > MaxAmount {
+		return rule, ErrInvalidAmount
+	}
+	if input.Reason == "" || len([]rune(input.Reason)) > 255 {
+		return rule, ErrReasonRequired
+	}
+	if input.Timezone == "" {
+		input.Timezone = "UTC"
+	}
+	if input.Roles == nil {
+		input.Roles = []string{}
+	}
+	for _, role := range input.Roles {
+		if role == models.SYSTEM_ROLE {
+			return rule, ErrUnknownRole
+		}
+	}
+	if len(input.Roles) > 0 {
+		var known int64
+		if err := tx.Model(&models.Role{}).Where("name IN ?", input.Roles).Count(&known).Error; err != nil {
+			return rule, err
+		}
+		if int(known) != len(uniqueStrings(input.Roles)) {
+			return rule, ErrUnknownRole
+		}
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&rule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return rule, err
+	}
+	before := rule
+	if rule.ID == uuid.Nil {
+		rule.CreatedBy = adminID
+	}
+	rule.Amount = input.Amount
+	rule.Roles = uniqueStrings(input.Roles)
+	rule.Schedule = input.Schedule
+	rule.Timezone = input.Timezone
+	rule.Reason = input.Reason
+	rule.Enabled = input.Enabled
+	rule.UpdatedBy = adminID
+
+	next, err := nextRun(rule, now)
+	if err != nil {
+		return rule, err
+	}
+	// Если расписание не менялось, наступивший, но еще не выполненный запуск не теряется.
+	unchanged := before.Enabled && before.Schedule == rule.Schedule && before.Timezone == rule.Timezone
+	switch {
+	case !rule.Enabled:
+		rule.NextRunAt = nil
+	case !unchanged || before.NextRunAt == nil:
+		rule.NextRunAt = next
+	}
+	if err := tx.Save(&rule).Error; err != nil {
+		return rule, err
+	}
+
+	event := audit.Event{
+		Action:     models.AUDIT_ALLOWANCE_UPDATE,
+		TargetType: "allowance",
+		TargetID:   rule.ID.String(),
+		After:      ruleState(rule),
+	}
+	if before.ID != uuid.Nil {
+		event.Before = ruleState(before)
+	}
+	return rule, audit.Write(tx, adminID, event)
+}
+
+func ruleState(rule models.AllowanceRule) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     rule.Name,
+		"amount":   rule.Amount,
+		"roles":    rule.Roles,
+		"schedule": rule.Schedule,
+		"timezone": rule.Timezone,
+		"reason":   rule.Reason,
+		"enabled":  rule.Enabled,
+	}
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// RunDue запускает правила, чей срок наступил к now, каждое не больше одного раза. Правило
+// блокируется с SKIP LOCKED и запускается в одной транзакции со сдвигом следующего запуска,
+// а успешный запуск за период может быть только один (уникальный индекс), поэтому при
+// нескольких экземплярах сервиса период оплачивается ровно один раз. Если сервис простоял
+// несколько периодов, пропущенные не догоняются: делается один запуск, и следующий
+// считается от now. Неудачная попытка сохраняется, правило повторится при следующей проверке.
+func RunDue(db *gorm.DB, now time.Time) ([]models.AllowanceRun, []uuid.UUID, error) {
+	var runs []models.AllowanceRun
+	var granted []uuid.UUID
+	attempted := []uuid.UUID{uuid.Nil}
+	for {
+		run, users, err := runNext(db, now, attempted)
+		if err != nil {
+			return runs, granted, err
+		}
+		if run == nil {
+			return runs, granted, nil
+		}
+		attempted = append(attempted, run.RuleID)
+		runs = append(runs, *run)
+		granted = append(granted, users...)
+	}
+}
+
+func runNext(db *gorm.DB, now time.Time, attempted []uuid.UUID) (*models.AllowanceRun, []uuid.UUID, error) {
+	var run *models.AllowanceRun
+	var granted []uuid.UUID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var rule models.AllowanceRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled AND next_run_at <= ? AND id NOT IN ?", now, attempted).
+			Order("next_run_at").
+			First(&rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		run = &models.AllowanceRun{
+			ID:          uuid.New(),
+			RuleID:      rule.ID,
+			ScheduledAt: *rule.NextRunAt,
+			StartedAt:   time.Now(),
+		}
+		// Начисления идут в точке сохранения: при ошибке откатываются только они,
+		// а неудачная попытка сохраняется.
+		applyErr := tx.Transaction(func(grants *gorm.DB) error {
+			granted, err = grantAudience(grants, rule, run, now)
+			return err
+		})
+		run.FinishedAt = time.Now()
+		if applyErr != nil {
+			granted = nil
+			run.Status = models.ALLOWANCE_RUN_FAILED
+			run.Recipients, run.TotalAmount = 0, 0
+			run.Error = applyErr.Error()
+			if err := tx.Create(run).Error; err != nil {
+				return err
+			}
+			return tx.Model(&rule).Update("last_error", run.Error).Error
+		}
+		return nil
+	})
+	if err != nil || run == nil {
+		return nil, nil, err
+	}
+	if run.Status == models.ALLOWANCE_RUN_FAILED {
+		loging.Log.Errorf("Правило начисления %s не выполнено: %s", run.RuleID, run.Error)
+	}
+	return run, granted, nil
+}
+
+// grantAudience начисляет монеты аудитории правила, сохраняет запуск и сдвигает следующий
+// на период после now.
+func grantAudience(tx *gorm.DB, rule models.AllowanceRule, run *models.AllowanceRun, now time.Time) ([]uuid.UUID, error) {
+	query := tx.Table("users").
+		Joins("JOIN wallets ON wallets.user_id = users.id").
+		Where("users.role <> ?", models.SYSTEM_ROLE)
+	if len(rule.Roles) > 0 {
+		query = query.Where("users.role IN ?", rule.Roles)
+	}
+	var userIDs []uuid.UUID
+	if err := query.Order("users.id").Pluck("users.id", &userIDs).Error; err != nil {
+		return nil, fmt.Errorf("аудитория правила: %w", err)
+	}
+	coins, err := treasury.LockWallets(tx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Начисление доводит баланс не выше MAX_WALLET_BALANCE: у кого кошелек полон, тот пропускается.
+	limit := config.MaxWalletBalance()
+	grants := make([]models.AllowanceGrant, 0, len(userIDs))
+	granted := make([]uuid.UUID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		amount := rule.Amount
+		if limit > 0 {
+			if coins[userID] >= limit {
+				continue
+			}
+			amount = min(amount, limit-coins[userID])
+		}
+		transaction, entry, err := treasury.Grant(tx, rule.UpdatedBy, userID, amount, rule.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("начисление сотруднику %s: %w", userID, err)
+		}
+		grants = append(grants, models.AllowanceGrant{
+			RunID:         run.ID,
+			UserID:        userID,
+			Amount:        amount,
+			TransactionID: transaction.ID,
+			EntryID:       entry.ID,
+		})
+		granted = append(granted, userID)
+		run.TotalAmount += int64(amount)
+	}
+
+	run.Status = models.ALLOWANCE_RUN_COMPLETED
+	run.Recipients = len(grants)
+	if err := tx.Create(run).Error; err != nil {
+		return nil, err
+	}
+	if len(grants) > 0 {
+		if err := tx.CreateInBatches(&grants, 500).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	scheduledAt := run.ScheduledAt
+	if now.Before(scheduledAt) {
+		now = scheduledAt
+	}
+	next, err := nextRun(rule, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&rule).Updates(map[string]interface{}{
+		"next_run_at": next,
+		"last_run_at": scheduledAt,
+		"last_error":  "",
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	err = audit.Write(tx, uuid.Nil, audit.Event{
+		Action:     models.AUDIT_ALLOWANCE_RUN,
+		TargetType: "allowance",
+		TargetID:   rule.ID.String(),
+		After:      map[string]interface{}{"run": run.ID, "scheduledAt": scheduledAt, "recipients": run.Recipients, "totalAmount": run.TotalAmount},
+		Details:    fmt.Sprintf("правило %s: %d сотрудникам, всего %d монет", rule.Name, run.Recipients, run.TotalAmount),
+	})
+	return granted, err
+}
+
+// Schedule проверяет правила каждые interval, пока не отменен ctx. onGranted получает
+// сотрудников, которым начислены монеты, например чтобы сбросить кэш.
+func Schedule(ctx context.Context, db *gorm.DB, interval time.Duration, onGranted func([]uuid.UUID)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			runs, granted, err := RunDue(db.WithContext(ctx), now)
+			if err != nil {
+				loging.Log.WithError(err).Error("Ошибка регулярных начислений")
+			}
+			for _, run := range runs {
+				if run.Status == models.ALLOWANCE_RUN_COMPLETED {
+					loging.Log.Infof("Правило начисления %s выполнено за %s: сотрудников %d, монет %d",
+						run.RuleID, run.ScheduledAt.Format(time.RFC3339), run.Recipients, run.TotalAmount)
+				}
+			}
+			if len(granted) > 0 && onGranted != nil {
+				onGranted(granted)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package allowances
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron расписание в формате cron из пяти полей: минута, час, день месяца, месяц, день недели
+// (0 или 7 — воскресенье). Поле — *, число, диапазон a-b, шаг */n или a-b/n и списки через
+// запятую. Если заданы и день месяца, и день недели, подходит любой из них, как в cron.
+// Вместо полей можно указать @monthly, @weekly, @daily или @hourly.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var ErrInvalidSchedule = errors.New("некорректное расписание")
+
+var macros = map[string]string{
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"минута", 0, 59},
+	{"час", 0, 23},
+	{"день месяца", 1, 31},
+	{"месяц", 1, 12},
+	{"день недели", 0, 7},
+}
+
+// ParseCron разбирает расписание.
+func ParseCron(spec string) (Cron, error) {
+	var cron Cron
+	spec = strings.TrimSpace(spec)
+	if macro, ok := macros[spec]; ok {
+		spec = macro
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return cron, fmt.Errorf("%w: нужно пять полей через пробел", ErrInvalidSchedule)
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return cron, err
+		}
+		sets[i] = set
+	}
+	cron.minute, cron.hour, cron.dom, cron.month, cron.dow = sets[0], sets[1], sets[2], sets[3], sets[4]
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	cron.domAny = parts[2] == "*"
+	cron.dowAny = parts[4] == "*"
+	return cron, nil
+}
+
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: шаг в поле «%s»", ErrInvalidSchedule, f.name)
+			}
+			rangePart, step = item[:i], n
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("%w: значение %q в поле «%s»", ErrInvalidSchedule, bounds[0], f.name)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("%w: значение %q в поле «%s»", ErrInvalidSchedule, bounds[1], f.name)
+				}
+			} else if step > 1 {
+				high = f.max
+			}
+		}
+		if low < f.min || high > f.max || low > high {
+			return 0, fmt.Errorf("%w: поле «%s» должно быть от %d до %d", ErrInvalidSchedule, f.name, f.min, f.max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next первый момент расписания строго после after в часовом поясе after. Если расписание
+// не срабатывает в ближайшие пять лет (например, 31 февраля), возвращается нулевое время.
+func (c Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"Shop/database/models"
+	"Shop/utils"
+	"encoding/json"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event изменение для журнала аудита. Before и After — любые значения, сериализуемые в JSON-объект
+// (структура или map); nil означает, что объекта до или после изменения не было.
+type Event struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+	Details    string
+}
+
+// Write добавляет запись в журнал аудита. Вызывается в той же транзакции, что и само изменение,
+// чтобы изменение без записи аудита не могло зафиксироваться. ID запроса и IP клиента берутся
+// из контекста транзакции (tx.WithContext(r.Context())).
+func Write(tx *gorm.DB, actorID uuid.UUID, event Event) error {
+	before, err := object(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := object(event.After)
+	if err != nil {
+		return err
+	}
+
+	record := models.AuditEvent{
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Before:     before,
+		After:      after,
+		Details:    event.Details,
+	}
+	if actorID != uuid.Nil {
+		record.ActorID = &actorID
+	}
+	if ctx := tx.Statement.Context; ctx != nil {
+		record.RequestID = utils.RequestID(ctx)
+		record.IP = utils.ClientIP(ctx)
+	}
+	return tx.Create(&record).Error
+}
+
+// Record добавляет в журнал аудита запись без состояний до и после.
+func Record(tx *gorm.DB, actorID uuid.UUID, action, targetType, targetID, details string) error {
+	return Write(tx, actorID, Event{Action: action, TargetType: targetType, TargetID: targetID, Details: details})
+}
+
+// object приводит значение к JSON-объекту. Значения, которые не сериализуются в объект,
+// сохраняются под ключом value.
+func object(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return map[string]interface{}{"value": value}, nil
+	}
+	return result, nil
+}
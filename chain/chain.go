@@ -0,0 +1,165 @@
+package chain
+
+import (
+	"Shop/database/models"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+const batchSize = 1000
+
+// Break первое нарушенное звено цепочки.
+type Break struct {
+	Seq    int64  `json:"seq"`
+	ID     string `json:"id"`
+	Reason string `json:"reason" example:"хеш записи не совпадает с ее содержимым"`
+}
+
+// ChainReport результат проверки цепочки одной таблицы. Unchained — записи без звена. Старые записи
+// включаются в цепочку один раз, при первом старте с цепочками, поэтому такие записи вставлены
+// в обход приложения и тоже считаются нарушением.
+type ChainReport struct {
+	Table     string `json:"table"`
+	Records   int64  `json:"records"`
+	Unchained int64  `json:"unchained"`
+	HeadHash  string `json:"headHash,omitempty"`
+	Valid     bool   `json:"valid"`
+	Broken    *Break `json:"broken,omitempty"`
+}
+
+// Report результат проверки всех цепочек.
+type Report struct {
+	Valid  bool          `json:"valid"`
+	Chains []ChainReport `json:"chains"`
+}
+
+// Verify проходит цепочки переводов, покупок и журнала аудита по порядку и пересчитывает хеши.
+// В каждой цепочке сообщает о первом разрыве: пропущенном номере, несовпадении ссылки
+// на предыдущую запись или хеша с содержимым записи.
+func Verify(db *gorm.DB) (Report, error) {
+	report := Report{Valid: true}
+	for _, verify := range []func(*gorm.DB) (ChainReport, error){
+		verifyTable[models.Transaction],
+		verifyTable[models.Purchase],
+		verifyTable[models.AuditEvent],
+	} {
+		chain, err := verify(db)
+		if err != nil {
+			return report, err
+		}
+		report.Valid = report.Valid && chain.Valid
+		report.Chains = append(report.Chains, chain)
+	}
+	return report, nil
+}
+
+func verifyTable[T any, PT interface {
+	*T
+	models.Chained
+}](db *gorm.DB) (ChainReport, error) {
+	report := ChainReport{Table: PT(new(T)).ChainTable(), Valid: true}
+
+	if err := db.Model(new(T)).Where("chain_seq IS NULL").Count(&report.Unchained).Error; err != nil {
+		return report, fmt.Errorf("подсчет записей вне цепочки %s: %w", report.Table, err)
+	}
+
+	prevHash := ""
+	expected := int64(1)
+	for {
+		var batch []T
+		err := db.Where("chain_seq >= ?", expected).Order("chain_seq").Limit(batchSize).Find(&batch).Error
+		if err != nil {
+			return report, fmt.Errorf("чтение цепочки %s: %w", report.Table, err)
+		}
+		for i := range batch {
+			record := PT(&batch[i])
+			link := record.Link()
+			if reason := checkLink(record, *link, expected, prevHash); reason != "" {
+				report.Valid = false
+				report.Broken = &Break{Seq: expected, ID: record.ChainID().String(), Reason: reason}
+				return report, nil
+			}
+			report.Records++
+			prevHash = link.Hash
+			expected++
+		}
+		if len(batch) < batchSize {
+			break
+		}
+	}
+	report.HeadHash = prevHash
+	report.Valid = report.Unchained == 0
+	return report, nil
+}
+
+func checkLink(record models.Chained, link models.ChainLink, expected int64, prevHash string) string {
+	if *link.ChainSeq != expected {
+		return fmt.Sprintf("пропущены записи с номерами %d-%d", expected, *link.ChainSeq-1)
+	}
+	if link.PrevHash != prevHash {
+		return "ссылка на предыдущую запись не совпадает с ее хешем"
+	}
+	hash, err := models.ChainHash(link.PrevHash, expected, record.ChainPayload())
+	if err != nil || hash != link.Hash {
+		return "хеш записи не совпадает с ее содержимым"
+	}
+	return ""
+}
+
+// Backfill включает в цепочки записи, созданные до ее появления, в порядке создания.
+// Выполняется при старте, но достраивает только цепочку, в которой еще нет ни одного звена.
+// Запись без звена в начатой цепочке вставлена в обход приложения: Backfill ее не трогает,
+// а Verify сообщает о ней как о нарушении.
+func Backfill(db *gorm.DB) error {
+	for _, backfill := range []func(*gorm.DB) error{
+		backfillTable[models.Transaction],
+		backfillTable[models.Purchase],
+		backfillTable[models.AuditEvent],
+	} {
+		if err := db.Transaction(backfill); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backfillTable[T any, PT interface {
+	*T
+	models.Chained
+}](tx *gorm.DB) error {
+	table := PT(new(T)).ChainTable()
+	last, err := models.ChainHead(tx, table)
+	if err != nil {
+		return err
+	}
+	if last.ChainSeq != nil {
+		return nil
+	}
+
+	var pending []T
+	if err := tx.Where("chain_seq IS NULL").Order("created_at, id").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	seq := int64(0)
+	prevHash := ""
+
+	for i := range pending {
+		record := PT(&pending[i])
+		seq++
+		hash, err := models.ChainHash(prevHash, seq, record.ChainPayload())
+		if err != nil {
+			return err
+		}
+		err = tx.Model(record).UpdateColumns(map[string]interface{}{
+			"chain_seq": seq,
+			"prev_hash": prevHash,
+			"hash":      hash,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("включение записи в цепочку %s: %w", table, err)
+		}
+		prevHash = hash
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"Shop/chain"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/loging"
+	"Shop/reconcile"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Использование:
+  main                                      запуск сервера
+  main bootstrap-admin [-email] [-password] создание первого администратора
+                                            (по умолчанию BOOTSTRAP_ADMIN_EMAIL и BOOTSTRAP_ADMIN_PASSWORD)
+  main verify-chain                         проверка хеш-цепочек переводов, покупок и журнала аудита
+  main reconcile                            сверка кошельков с историей операций; расхождения
+                                            исправляет админ через /api/admin/reconciliation`
+
+// runCommand выполняет консольную подкоманду и возвращает код выхода.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "bootstrap-admin":
+		return bootstrapAdminCommand(args[1:])
+	case "reconcile":
+		return reconcileCommand()
+	case "verify-chain":
+		return verifyChainCommand()
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Неизвестная команда %q\n%s\n", args[0], usage)
+		return 2
+	}
+}
+
+func bootstrapAdminCommand(args []string) int {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	email := flags.String("email", os.Getenv("BOOTSTRAP_ADMIN_EMAIL"), "email администратора")
+	password := flags.String("password", os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"), "пароль администратора")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "Не задан email администратора")
+		return 2
+	}
+
+	migrations.InitDB()
+	user, created, err := handlers.BootstrapAdmin(migrations.DB, *email, *password)
+	if err != nil {
+		loging.Log.WithError(err).Error("Не удалось создать администратора")
+		return 1
+	}
+	if !created {
+		fmt.Printf("Администратор уже существует: %s (%s)\n", user.Username, user.Email)
+		return 0
+	}
+	fmt.Printf("Администратор создан: %s (%s)\n", user.Username, user.Email)
+	return 0
+}
+
+// verifyChainCommand печатает результат проверки хеш-цепочек. Код выхода 1, если цепочка нарушена.
+func verifyChainCommand() int {
+	migrations.InitDB()
+	report, err := chain.Verify(migrations.DB)
+	if err != nil {
+		loging.Log.WithError(err).Error("Не удалось проверить хеш-цепочки")
+		return 1
+	}
+
+	for _, c := range report.Chains {
+		switch {
+		case c.Broken != nil:
+			fmt.Printf("%s: РАЗРЫВ на записи %d (%s): %s\n", c.Table, c.Broken.Seq, c.Broken.ID, c.Broken.Reason)
+		case c.Unchained > 0:
+			fmt.Printf("%s: %d записей вне цепочки\n", c.Table, c.Unchained)
+		default:
+			fmt.Printf("%s: в порядке, записей %d, хеш последней %s\n", c.Table, c.Records, c.HeadHash)
+		}
+	}
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// reconcileCommand выполняет сверку кошельков и печатает расхождения. Код выхода 1, если они есть.
+func reconcileCommand() int {
+	migrations.InitDB()
+	run, mismatches, err := reconcile.Run(migrations.DB, models.RECONCILIATION_TRIGGER_CLI, nil)
+	if err != nil {
+		loging.Log.WithError(err).Error("Не удалось выполнить сверку кошельков")
+		return 1
+	}
+
+	fmt.Printf("Сверка %s: проверено кошельков %d, расхождений %d\n", run.ID, run.Wallets, run.Mismatches)
+	for _, mismatch := range mismatches {
+		fmt.Printf("  %s (%s): в кошельке %d, по истории %d, разница %d, история %v\n",
+			mismatch.UserID, mismatch.ID, mismatch.WalletCoin, mismatch.ExpectedBalance, mismatch.Difference, mismatch.Breakdown)
+	}
+	for _, entry := range run.UnbalancedEntries {
+		fmt.Printf("  несбалансированная запись журнала %s\n", entry)
+	}
+	if run.Mismatches > 0 || len(run.UnbalancedEntries) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// bootstrapAdminFromEnv создает первого администратора при старте сервера, если заданы
+// BOOTSTRAP_ADMIN_EMAIL и BOOTSTRAP_ADMIN_PASSWORD.
+func bootstrapAdminFromEnv() {
+	email := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	if email == "" {
+		return
+	}
+	user, created, err := handlers.BootstrapAdmin(migrations.DB, email, os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"))
+	if err != nil {
+		loging.Log.WithError(err).Fatal("Не удалось создать первого администратора")
+	}
+	if created {
+		loging.Log.Info("Создан первый администратор: " + user.Username)
+	}
+}
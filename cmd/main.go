@@ -1,12 +1,16 @@
 package main
 
 import (
+	"Shop/allowances"
 	"Shop/config"
 	"Shop/database/migrations"
 	"Shop/database/models"
 	_ "Shop/docs"
+	"Shop/expiry"
+	"Shop/grants"
 	"Shop/handlers"
 	"Shop/loging"
+	"Shop/reconcile"
 	"Shop/utils"
 	"context"
 	"github.com/gorilla/mux"
@@ -25,37 +29,110 @@ import (
 func main() {
 	loging.InitLogging()
 	config.LoadEnv()
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	if err := utils.InitJWTKeys(); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка загрузки ключей подписи JWT")
+	}
 	migrations.InitDB()
 	config.InitRedis()
+	bootstrapAdminFromEnv()
 
 	r := mux.NewRouter()
+	r.Use(utils.RequestMetaMiddleware)
 	loging.Log.Info("Сервер запущен успешно")
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
 	apiRouter := r.PathPrefix("/api").Subrouter()
 	apiRouter.HandleFunc("/ping", handlers.PingHandler).Methods("GET")
+	apiRouter.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
 	apiRouter.HandleFunc("/auth", handlers.AuthHandler).Methods("POST")
 	apiRouter.HandleFunc("/auth/logout", handlers.LogoutHandler).Methods("POST")
+	apiRouter.HandleFunc("/auth/refresh", handlers.RefreshHandler).Methods("POST")
+	apiRouter.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler).Methods("GET")
 	apiRouter.HandleFunc("/merch", handlers.ShowMerchHandler).Methods("GET")
 	apiRouter.HandleFunc("/users", handlers.ShowEmployeesHandler).Methods("GET")
 
 	employeeInfoRouter := apiRouter.PathPrefix("/info").Subrouter()
-	employeeInfoRouter.Use(utils.AuthMiddleware(models.EMPLOYEE_ROLE))
+	employeeInfoRouter.Use(utils.AuthMiddleware(models.PERMISSION_INFO_READ))
 	employeeInfoRouter.HandleFunc("", handlers.InformationHandler).Methods("GET")
 
+	employeeTransactionsRouter := apiRouter.PathPrefix("/transactions").Subrouter()
+	employeeTransactionsRouter.Use(utils.AuthMiddleware(models.PERMISSION_INFO_READ))
+	employeeTransactionsRouter.HandleFunc("", handlers.TransactionsHandler).Methods("GET")
+
 	employeeSendCoinRouter := apiRouter.PathPrefix("/sendCoin").Subrouter()
-	employeeSendCoinRouter.Use(utils.AuthMiddleware(models.EMPLOYEE_ROLE))
+	employeeSendCoinRouter.Use(utils.AuthMiddleware(models.PERMISSION_COINS_SEND))
+	employeeSendCoinRouter.Use(utils.IdempotencyMiddleware)
 	employeeSendCoinRouter.HandleFunc("", handlers.SendCoinHandler).Methods("POST")
 
-	employeeBuyItemRouter := apiRouter.PathPrefix("/buy/{item}").Subrouter()
-	employeeBuyItemRouter.Use(utils.AuthMiddleware(models.EMPLOYEE_ROLE))
-	employeeBuyItemRouter.HandleFunc("", handlers.BuyItemHandler).Methods("GET")
+	employeeBuyRouter := apiRouter.PathPrefix("/buy").Subrouter()
+	employeeBuyRouter.Use(utils.AuthMiddleware(models.PERMISSION_MERCH_BUY))
+	employeeBuyRouter.Use(utils.IdempotencyMiddleware)
+	employeeBuyRouter.HandleFunc("", handlers.PurchaseHandler).Methods("POST")
+
+	if config.LegacyBuyEnabled() {
+		employeeBuyItemRouter := apiRouter.PathPrefix("/buy/{item}").Subrouter()
+		employeeBuyItemRouter.Use(utils.DeprecationMiddleware(config.LegacyBuySunset(), "/api/buy"))
+		employeeBuyItemRouter.Use(utils.AuthMiddleware(models.PERMISSION_MERCH_BUY))
+		employeeBuyItemRouter.Use(utils.IdempotencyMiddleware)
+		employeeBuyItemRouter.HandleFunc("", handlers.BuyItemHandler).Methods("GET")
+	} else {
+		loging.Log.Info("Устаревший маршрут GET /api/buy/{item} отключен")
+	}
+
+	cartRouter := apiRouter.PathPrefix("/cart").Subrouter()
+	cartRouter.Use(utils.AuthMiddleware(models.PERMISSION_MERCH_BUY))
+	cartRouter.HandleFunc("", handlers.GetCartHandler).Methods("GET")
+	cartRouter.HandleFunc("/items", handlers.AddToCartHandler).Methods("POST")
+	cartRouter.HandleFunc("/items/{item}", handlers.SetCartQuantityHandler).Methods("PUT")
+	cartRouter.HandleFunc("/items/{item}", handlers.RemoveFromCartHandler).Methods("DELETE")
+
+	checkoutRouter := apiRouter.PathPrefix("/checkout").Subrouter()
+	checkoutRouter.Use(utils.AuthMiddleware(models.PERMISSION_MERCH_BUY))
+	checkoutRouter.Use(utils.IdempotencyMiddleware)
+	checkoutRouter.HandleFunc("", handlers.CheckoutHandler).Methods("POST")
+
+	ordersRouter := apiRouter.PathPrefix("/orders").Subrouter()
+	ordersRouter.Use(utils.AuthMiddleware(models.PERMISSION_MERCH_BUY))
+	ordersRouter.HandleFunc("", handlers.MyOrdersHandler).Methods("GET")
+	ordersRouter.HandleFunc("/{id}/cancel", handlers.CancelMyOrderHandler).Methods("POST")
 
 	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
-	adminRouter.Use(utils.AuthMiddleware(models.ADMIN_ROLE))
-	adminRouter.HandleFunc("/users", handlers.PutMoneyHandler).Methods("POST")
-	adminRouter.HandleFunc("/merch/new", handlers.AddOrChangeMerchHandler).Methods("POST")
+	adminRouter.Handle("/users", requirePermission(models.PERMISSION_USERS_READ, http.HandlerFunc(handlers.ListUsersHandler))).Methods("GET")
+	adminRouter.Handle("/users", requirePermission(models.PERMISSION_COINS_MINT, utils.IdempotencyMiddleware(http.HandlerFunc(handlers.PutMoneyHandler)))).Methods("POST")
+	adminRouter.Handle("/merch/new", requirePermission(models.PERMISSION_MERCH_WRITE, http.HandlerFunc(handlers.AddOrChangeMerchHandler))).Methods("POST")
+	adminRouter.Handle("/merch/low-stock", requirePermission(models.PERMISSION_MERCH_WRITE, http.HandlerFunc(handlers.LowStockHandler))).Methods("GET")
+	adminRouter.Handle("/merch/{item}/restock", requirePermission(models.PERMISSION_MERCH_WRITE, http.HandlerFunc(handlers.RestockMerchHandler))).Methods("POST")
+	adminRouter.Handle("/merch/{item}/variants/{sku}", requirePermission(models.PERMISSION_MERCH_WRITE, http.HandlerFunc(handlers.PutVariantHandler))).Methods("PUT")
+	adminRouter.Handle("/orders", requirePermission(models.PERMISSION_ORDERS_MANAGE, http.HandlerFunc(handlers.AdminOrdersHandler))).Methods("GET")
+	adminRouter.Handle("/orders/{id}/status", requirePermission(models.PERMISSION_ORDERS_MANAGE, http.HandlerFunc(handlers.UpdateOrderStatusHandler))).Methods("POST")
+	adminRouter.Handle("/purchases/{id}/refund", requirePermission(models.PERMISSION_REFUNDS_MANAGE, http.HandlerFunc(handlers.RefundPurchaseHandler))).Methods("POST")
+	adminRouter.Handle("/grants/bulk", requirePermission(models.PERMISSION_COINS_MINT, utils.IdempotencyMiddleware(http.HandlerFunc(handlers.BulkGrantHandler)))).Methods("POST")
+	adminRouter.Handle("/grants/bulk/{id}", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.BulkGrantJobHandler))).Methods("GET")
+	adminRouter.Handle("/grants/bulk/{id}/resume", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.ResumeBulkGrantHandler))).Methods("POST")
+	adminRouter.Handle("/allowances", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.ListAllowancesHandler))).Methods("GET")
+	adminRouter.Handle("/allowances/{name}", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.PutAllowanceHandler))).Methods("PUT")
+	adminRouter.Handle("/allowances/{name}/runs", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.AllowanceRunsHandler))).Methods("GET")
+	adminRouter.Handle("/allowance-runs/{id}", requirePermission(models.PERMISSION_COINS_MINT, http.HandlerFunc(handlers.AllowanceRunHandler))).Methods("GET")
+	adminRouter.Handle("/top-ups/{id}/reverse", requirePermission(models.PERMISSION_REFUNDS_MANAGE, http.HandlerFunc(handlers.ReverseTopUpHandler))).Methods("POST")
+	adminRouter.Handle("/audit", requirePermission(models.PERMISSION_AUDIT_READ, http.HandlerFunc(handlers.AuditHandler))).Methods("GET")
+	adminRouter.Handle("/audit/verify", requirePermission(models.PERMISSION_AUDIT_READ, http.HandlerFunc(handlers.VerifyChainHandler))).Methods("GET")
+	adminRouter.Handle("/ledger/verify", requirePermission(models.PERMISSION_LEDGER_READ, http.HandlerFunc(handlers.VerifyLedgerHandler))).Methods("GET")
+	adminRouter.Handle("/reconciliation/runs", requirePermission(models.PERMISSION_LEDGER_READ, http.HandlerFunc(handlers.RunReconciliationHandler))).Methods("POST")
+	adminRouter.Handle("/reconciliation/mismatches", requirePermission(models.PERMISSION_LEDGER_READ, http.HandlerFunc(handlers.MismatchesHandler))).Methods("GET")
+	adminRouter.Handle("/reconciliation/mismatches/{id}/resolve", requirePermission(models.PERMISSION_LEDGER_CORRECT, http.HandlerFunc(handlers.ResolveMismatchHandler))).Methods("POST")
+	adminRouter.Handle("/blocked-transfers", requirePermission(models.PERMISSION_TRANSFERS_REVIEW, http.HandlerFunc(handlers.BlockedTransfersHandler))).Methods("GET")
+	adminRouter.Handle("/blocked-transfers/{id}/review", requirePermission(models.PERMISSION_TRANSFERS_REVIEW, http.HandlerFunc(handlers.ReviewBlockedTransferHandler))).Methods("POST")
+	adminRouter.Handle("/invites", requirePermission(models.PERMISSION_USERS_INVITE, http.HandlerFunc(handlers.CreateInviteHandler))).Methods("POST")
+	adminRouter.Handle("/roles", requirePermission(models.PERMISSION_ROLES_MANAGE, http.HandlerFunc(handlers.ListRolesHandler))).Methods("GET")
+	adminRouter.Handle("/roles/grant", requirePermission(models.PERMISSION_ROLES_MANAGE, http.HandlerFunc(handlers.GrantRoleHandler))).Methods("POST")
+	adminRouter.Handle("/roles/revoke", requirePermission(models.PERMISSION_ROLES_MANAGE, http.HandlerFunc(handlers.RevokeRoleHandler))).Methods("POST")
+	adminRouter.Handle("/roles/{name}", requirePermission(models.PERMISSION_ROLES_MANAGE, http.HandlerFunc(handlers.PutRoleHandler))).Methods("PUT")
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -65,6 +142,21 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if config.ReconcileEnabled() {
+		go reconcile.Schedule(jobsCtx, migrations.DB, config.ReconcileInterval())
+	}
+	if config.AllowancesEnabled() {
+		go allowances.Schedule(jobsCtx, migrations.DB, config.AllowanceTick(), handlers.InvalidateCoinHistory)
+	}
+	expirySchedule, err := allowances.ParseCron(config.CoinExpirySchedule())
+	if err != nil {
+		loging.Log.WithError(err).Fatal("Некорректное расписание COIN_EXPIRY_SCHEDULE")
+	}
+	go expiry.Schedule(jobsCtx, migrations.DB, expirySchedule, handlers.InvalidateCoinHistory)
+	go grants.Run(jobsCtx, migrations.DB, config.BulkGrantPollInterval(), handlers.InvalidateCoinHistory)
+
 	go func() {
 		loging.Log.Info("Сервер успешно запущен на порту: 8080")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -75,6 +167,7 @@ func main() {
 	<-stop
 
 	loging.Log.Info("Выключение сервера...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -85,3 +178,8 @@ func main() {
 
 	loging.Log.Info("Сервер выключен")
 }
+
+// requirePermission оборачивает обработчик проверкой токена и права.
+func requirePermission(permission string, handler http.Handler) http.Handler {
+	return utils.AuthMiddleware(permission)(handler)
+}
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+const defaultAllowanceTick = time.Minute
+
+// AllowancesEnabled запускать ли регулярные начисления по правилам (ALLOWANCES_ENABLED).
+func AllowancesEnabled() bool {
+	return boolFromEnv("ALLOWANCES_ENABLED", true)
+}
+
+// AllowanceTick как часто проверяются правила регулярных начислений, у которых наступил срок
+// (ALLOWANCE_TICK, по умолчанию 1m). Запуск случается не позже чем через этот интервал после срока.
+func AllowanceTick() time.Duration {
+	return durationFromEnv("ALLOWANCE_TICK", defaultAllowanceTick)
+}
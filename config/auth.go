@@ -0,0 +1,80 @@
+package config
+
+import (
+	"Shop/loging"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	defaultStartingBalance = 1000
+)
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		loging.Log.Warnf("Некорректное значение %s=%q, используется %s", name, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// AccessTokenTTL время жизни access-токена (ACCESS_TOKEN_TTL, по умолчанию 15m).
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL время жизни refresh-токена (REFRESH_TOKEN_TTL, по умолчанию 720h).
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func boolFromEnv(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		loging.Log.Warnf("Некорректное значение %s=%q, используется %t", name, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// AutoProvisionUsers создавать ли пользователя при входе с неизвестным email (AUTH_AUTO_PROVISION).
+// Старое поведение, оставлено для демо-стендов; по умолчанию выключено.
+func AutoProvisionUsers() bool {
+	return boolFromEnv("AUTH_AUTO_PROVISION", false)
+}
+
+// InviteRequired требуется ли код приглашения для регистрации (REGISTRATION_INVITE_REQUIRED).
+func InviteRequired() bool {
+	return boolFromEnv("REGISTRATION_INVITE_REQUIRED", false)
+}
+
+// AllowedEmailDomains домены почты, с которыми можно зарегистрироваться без приглашения
+// (REGISTRATION_ALLOWED_DOMAINS через запятую). Пустой список — любой домен.
+func AllowedEmailDomains() []string {
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv("REGISTRATION_ALLOWED_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// StartingBalance стартовый баланс нового сотрудника (STARTING_BALANCE, по умолчанию 1000).
+func StartingBalance() uint {
+	return uintFromEnv("STARTING_BALANCE", defaultStartingBalance)
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"Shop/loging"
+	"os"
+	"time"
+)
+
+// defaultLegacyBuySunset дата, после которой устаревший GET /api/buy/{item} может быть удален.
+var defaultLegacyBuySunset = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+// LegacyBuyEnabled доступна ли покупка через устаревший GET /api/buy/{item} (BUY_GET_ENABLED).
+// По умолчанию включено, чтобы старые клиенты успели перейти на POST /api/buy.
+func LegacyBuyEnabled() bool {
+	return boolFromEnv("BUY_GET_ENABLED", true)
+}
+
+// LegacyBuySunset дата отключения GET /api/buy/{item} для заголовка Sunset
+// (BUY_GET_SUNSET в формате 2006-01-02, по умолчанию 2027-04-01).
+func LegacyBuySunset() time.Time {
+	value := os.Getenv("BUY_GET_SUNSET")
+	if value == "" {
+		return defaultLegacyBuySunset
+	}
+	sunset, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		loging.Log.Warnf("Некорректное значение BUY_GET_SUNSET=%q, используется %s", value, defaultLegacyBuySunset.Format(time.DateOnly))
+		return defaultLegacyBuySunset
+	}
+	return sunset
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"Shop/loging"
+	"os"
+	"strconv"
+)
+
+const (
+	defaultCoinExpirySchedule = "0 3 * * *"
+	defaultExpiryNoticeDays   = 30
+)
+
+func uintFromEnv(name string, fallback uint) uint {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		loging.Log.Warnf("Некорректное значение %s=%q, используется %d", name, value, fallback)
+		return fallback
+	}
+	return uint(parsed)
+}
+
+// CoinExpiryMonths через сколько месяцев сгорают начисленные монеты (COIN_EXPIRY_MONTHS,
+// по умолчанию 0 — не сгорают). Новое значение действует на монеты, начисленные после его смены.
+func CoinExpiryMonths() uint {
+	return uintFromEnv("COIN_EXPIRY_MONTHS", 0)
+}
+
+// CoinExpirySchedule когда списываются сгоревшие монеты, в формате cron по UTC
+// (COIN_EXPIRY_SCHEDULE, по умолчанию каждую ночь в 3:00).
+func CoinExpirySchedule() string {
+	if value := os.Getenv("COIN_EXPIRY_SCHEDULE"); value != "" {
+		return value
+	}
+	return defaultCoinExpirySchedule
+}
+
+// ExpiryNoticeDays за сколько дней до сгорания монеты показываются в /api/info
+// (EXPIRY_NOTICE_DAYS, по умолчанию 30).
+func ExpiryNoticeDays() uint {
+	return uintFromEnv("EXPIRY_NOTICE_DAYS", defaultExpiryNoticeDays)
+}
+
+// MaxWalletBalance больше скольких монет не может быть на кошельке после перевода или
+// начисления (MAX_WALLET_BALANCE, по умолчанию 0 — без ограничения).
+func MaxWalletBalance() uint {
+	return uintFromEnv("MAX_WALLET_BALANCE", 0)
+}
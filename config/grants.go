@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+const defaultBulkGrantPollInterval = 30 * time.Second
+
+// BulkGrantPollInterval как часто фоновая обработка проверяет задания массового начисления,
+// созданные другими экземплярами или прерванные перезапуском (BULK_GRANT_POLL_INTERVAL, по умолчанию 30s).
+func BulkGrantPollInterval() time.Duration {
+	return durationFromEnv("BULK_GRANT_POLL_INTERVAL", defaultBulkGrantPollInterval)
+}
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+const defaultReconcileInterval = 24 * time.Hour
+
+// ReconcileEnabled запускать ли плановую сверку кошельков с историей операций (RECONCILE_ENABLED).
+func ReconcileEnabled() bool {
+	return boolFromEnv("RECONCILE_ENABLED", true)
+}
+
+// ReconcileInterval период плановой сверки (RECONCILE_INTERVAL, по умолчанию 24h).
+func ReconcileInterval() time.Duration {
+	return durationFromEnv("RECONCILE_INTERVAL", defaultReconcileInterval)
+}
@@ -0,0 +1,40 @@
+package config
+
+import "time"
+
+// Ограничения переводов между сотрудниками. 0 — ограничение выключено.
+
+// TransferMaxAmount сколько монет можно перевести одним переводом (TRANSFER_MAX_AMOUNT).
+func TransferMaxAmount() uint {
+	return uintFromEnv("TRANSFER_MAX_AMOUNT", 0)
+}
+
+// TransferDailyLimit сколько монет сотрудник может перевести за последние 24 часа
+// (TRANSFER_DAILY_LIMIT).
+func TransferDailyLimit() uint {
+	return uintFromEnv("TRANSFER_DAILY_LIMIT", 0)
+}
+
+// TransferWeeklyLimit сколько монет сотрудник может перевести за последние 7 дней
+// (TRANSFER_WEEKLY_LIMIT).
+func TransferWeeklyLimit() uint {
+	return uintFromEnv("TRANSFER_WEEKLY_LIMIT", 0)
+}
+
+// TransferRecipientDailyLimit сколько монет сотрудник может перевести одному получателю
+// за последние 24 часа (TRANSFER_RECIPIENT_DAILY_LIMIT).
+func TransferRecipientDailyLimit() uint {
+	return uintFromEnv("TRANSFER_RECIPIENT_DAILY_LIMIT", 0)
+}
+
+// TransferMaxPerHour сколько переводов сотрудник может сделать за последний час
+// (TRANSFER_MAX_PER_HOUR).
+func TransferMaxPerHour() uint {
+	return uintFromEnv("TRANSFER_MAX_PER_HOUR", 0)
+}
+
+// NewAccountTransferCooldown сколько времени после регистрации сотрудник не может переводить
+// монеты (NEW_ACCOUNT_TRANSFER_COOLDOWN, например 72h).
+func NewAccountTransferCooldown() time.Duration {
+	return durationFromEnv("NEW_ACCOUNT_TRANSFER_COOLDOWN", 0)
+}
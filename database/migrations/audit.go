@@ -0,0 +1,32 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// ProtectAuditEvents запрещает изменять и удалять записи журнала аудита: триггер отклоняет
+// UPDATE и DELETE, новые записи добавляются как обычно. Исключение — однократное включение
+// старой записи в хеш-цепочку (chain.Backfill), при котором меняются только поля звена.
+// TRUNCATE (очистка тестовой базы) построчные триггеры не вызывает.
+func ProtectAuditEvents(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' AND OLD.hash IS NULL AND NEW.hash IS NOT NULL
+				AND to_jsonb(OLD) - 'chain_seq' - 'prev_hash' - 'hash' = to_jsonb(NEW) - 'chain_seq' - 'prev_hash' - 'hash' THEN
+				RETURN NEW;
+			END IF;
+			RAISE EXCEPTION 'audit_events: записи журнала аудита нельзя изменять или удалять';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+		`CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only()`,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
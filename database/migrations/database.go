@@ -1,6 +1,7 @@
 package migrations
 
 import (
+	"Shop/chain"
 	"Shop/database/models"
 	"Shop/loging"
 	"fmt"
@@ -31,12 +32,56 @@ func InitDB() {
 	if err := DB.AutoMigrate(
 		&models.User{},
 		&models.Merch{},
+		&models.MerchVariant{},
 		&models.RevokedToken{},
 		&models.Transaction{},
 		&models.Purchase{},
 		&models.Wallet{},
+		&models.LedgerAccount{},
+		&models.JournalEntry{},
+		&models.Posting{},
+		&models.IdempotencyKey{},
+		&models.RefreshToken{},
+		&models.InviteCode{},
+		&models.AuditEvent{},
+		&models.Role{},
+		&models.Permission{},
+		&models.RolePermission{},
+		&models.CartItem{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.Reversal{},
+		&models.ReconciliationRun{},
+		&models.BalanceMismatch{},
+		&models.BulkGrantJob{},
+		&models.BulkGrantItem{},
+		&models.AllowanceRule{},
+		&models.AllowanceRun{},
+		&models.AllowanceGrant{},
+		&models.CoinLot{},
+		&models.BlockedTransfer{},
 	); err != nil {
 		loging.Log.WithError(err).Fatal("Ошибка миграции базы данных.")
 		return
 	}
+
+	if err := NormalizeEmails(DB); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка приведения email пользователей к нижнему регистру.")
+	}
+
+	if err := ProtectAuditEvents(DB); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка защиты журнала аудита.")
+	}
+
+	if err := chain.Backfill(DB); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка построения хеш-цепочек.")
+	}
+
+	if err := SeedRoles(DB); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка заполнения ролей и прав.")
+	}
+
+	if err := SeedTreasury(DB); err != nil {
+		loging.Log.WithError(err).Fatal("Ошибка создания пользователя казны.")
+	}
 }
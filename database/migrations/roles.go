@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"Shop/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedRoles заводит известные права и встроенные роли. ADMIN_ROLE при каждом запуске получает
+// все права, включая добавленные в новых версиях. EMPLOYEE_ROLE получает набор по умолчанию
+// только при создании, чтобы не затирать изменения, сделанные через API.
+func SeedRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(&models.Permissions).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.Role{Name: models.ADMIN_ROLE, Description: "Администратор, все права"}).Error; err != nil {
+			return err
+		}
+		adminPermissions := make([]models.RolePermission, 0, len(models.Permissions))
+		for _, permission := range models.Permissions {
+			adminPermissions = append(adminPermissions, models.RolePermission{RoleName: models.ADMIN_ROLE, PermissionName: permission.Name})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&adminPermissions).Error; err != nil {
+			return err
+		}
+
+		employee := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.Role{Name: models.EMPLOYEE_ROLE, Description: "Сотрудник"})
+		if employee.Error != nil {
+			return employee.Error
+		}
+		if employee.RowsAffected == 0 {
+			return nil
+		}
+		employeePermissions := make([]models.RolePermission, 0, len(models.EmployeePermissions))
+		for _, permission := range models.EmployeePermissions {
+			employeePermissions = append(employeePermissions, models.RolePermission{RoleName: models.EMPLOYEE_ROLE, PermissionName: permission})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&employeePermissions).Error
+	})
+}
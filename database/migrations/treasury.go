@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"Shop/database/models"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SeedTreasury заводит служебного пользователя казны, от имени которого в истории переводов
+// показываются начисления и списания админов.
+func SeedTreasury(db *gorm.DB) error {
+	treasury := models.Treasury()
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&treasury).Error; err != nil {
+		return err
+	}
+	var existing models.User
+	if err := db.Where("id = ?", models.TreasuryUserID).First(&existing).Error; err != nil {
+		return fmt.Errorf("никнейм или email казны занят другим пользователем: %w", err)
+	}
+	return nil
+}
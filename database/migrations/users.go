@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"Shop/loging"
+	"gorm.io/gorm"
+)
+
+// NormalizeEmails приводит email пользователей к нижнему регистру без пробелов, как их сохраняет
+// регистрация и ищет вход. Учетные записи, созданные раньше с исходным написанием, иначе не
+// смогли бы войти. Если несколько email совпадают после приведения, нормализуется один:
+// уже записанный в нижнем регистре, а если такого нет — самый ранний. Остальные остаются как
+// есть и пишутся в лог: их email админ исправляет вручную.
+func NormalizeEmails(db *gorm.DB) error {
+	err := db.Exec(`
+		WITH ranked AS (
+			SELECT id, LOWER(TRIM(email)) AS normalized,
+				ROW_NUMBER() OVER (
+					PARTITION BY LOWER(TRIM(email))
+					ORDER BY email = LOWER(TRIM(email)) DESC, created_at, id
+				) AS position
+			FROM users
+		)
+		UPDATE users SET email = ranked.normalized
+		FROM ranked
+		WHERE users.id = ranked.id AND ranked.position = 1 AND users.email <> ranked.normalized`).Error
+	if err != nil {
+		return err
+	}
+
+	var collisions []string
+	if err := db.Table("users").
+		Where("email <> LOWER(TRIM(email))").
+		Pluck("email", &collisions).Error; err != nil {
+		return err
+	}
+	for _, email := range collisions {
+		loging.Log.Warnf("Email %s совпадает с другим после приведения к нижнему регистру, вход по нему невозможен до ручного исправления", email)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ALLOWANCE_RUN_COMPLETED string = "completed"
+	ALLOWANCE_RUN_FAILED    string = "failed"
+)
+
+// AllowanceRule
+//
+// @Description Правило регулярного начисления: amount монет каждому сотруднику с кошельком и ролью из roles (пустой список — всем, кроме служебных) по расписанию schedule в формате cron (минута, час, день месяца, месяц, день недели) в часовом поясе timezone
+type AllowanceRule struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	Name      string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"name" example:"monthly"`
+	Amount    uint       `gorm:"not null" json:"amount" example:"500"`
+	Roles     []string   `gorm:"type:jsonb;serializer:json" json:"roles" example:"EMPLOYEE_ROLE"`
+	Schedule  string     `gorm:"type:varchar(100);not null" json:"schedule" example:"0 9 1 * *"`
+	Timezone  string     `gorm:"type:varchar(64);not null" json:"timezone" example:"Europe/Moscow"`
+	Reason    string     `gorm:"type:varchar(255);not null" json:"reason" example:"Ежемесячное начисление"`
+	Enabled   bool       `gorm:"not null" json:"enabled"`
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"createdBy"`
+	UpdatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"updatedBy"`
+	NextRunAt *time.Time `gorm:"index" json:"nextRunAt,omitempty"`
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	LastError string     `gorm:"type:text" json:"lastError,omitempty"`
+	CreatedAt time.Time  `gorm:"precision:6" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"precision:6" json:"updatedAt"`
+}
+
+// AllowanceRun
+//
+// @Description Запуск правила регулярного начисления за период scheduledAt. Успешный запуск за период может быть только один, неудачные попытки тоже сохраняются
+type AllowanceRun struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	RuleID      uuid.UUID `gorm:"type:uuid;not null;index;uniqueIndex:idx_allowance_runs_period,where:status = 'completed'" json:"ruleId"`
+	ScheduledAt time.Time `gorm:"precision:6;not null;uniqueIndex:idx_allowance_runs_period,where:status = 'completed'" json:"scheduledAt"`
+	Status      string    `gorm:"type:varchar(20);not null" json:"status" example:"completed"`
+	Recipients  int       `gorm:"not null" json:"recipients"`
+	TotalAmount int64     `gorm:"not null" json:"totalAmount"`
+	Error       string    `gorm:"type:text" json:"error,omitempty"`
+	StartedAt   time.Time `gorm:"precision:6" json:"startedAt"`
+	FinishedAt  time.Time `gorm:"precision:6" json:"finishedAt"`
+}
+
+// AllowanceGrant начисление, сделанное запуском правила: получатель и перевод от казны.
+type AllowanceGrant struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	RunID         uuid.UUID `gorm:"type:uuid;not null;index" json:"runId"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null" json:"userId"`
+	Amount        uint      `gorm:"not null" json:"amount"`
+	TransactionID uuid.UUID `gorm:"type:uuid;not null" json:"transactionId"`
+	EntryID       uuid.UUID `gorm:"type:uuid;not null" json:"entryId"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	AUDIT_ROLE_GRANT         string = "role.grant"
+	AUDIT_ROLE_REVOKE        string = "role.revoke"
+	AUDIT_ROLE_UPDATE        string = "role.update"
+	AUDIT_ADMIN_BOOTSTRAP    string = "admin.bootstrap"
+	AUDIT_MERCH_RESTOCK      string = "merch.restock"
+	AUDIT_MERCH_VARIANT      string = "merch.variant"
+	AUDIT_ORDER_STATUS       string = "order.status"
+	AUDIT_PURCHASE_REFUND    string = "purchase.refund"
+	AUDIT_TOP_UP_REVERSAL    string = "top_up.reversal"
+	AUDIT_COINS_MINT         string = "coins.mint"
+	AUDIT_COINS_TRANSFER     string = "coins.transfer"
+	AUDIT_MERCH_CREATE       string = "merch.create"
+	AUDIT_MERCH_PRICE        string = "merch.price"
+	AUDIT_PURCHASE           string = "purchase.create"
+	AUDIT_ORDER_CREATE       string = "order.create"
+	AUDIT_BALANCE_CORRECTION string = "balance.correction"
+	AUDIT_BULK_GRANT         string = "coins.bulk_grant"
+	AUDIT_ALLOWANCE_UPDATE   string = "allowance.update"
+	AUDIT_ALLOWANCE_RUN      string = "allowance.run"
+	AUDIT_COINS_EXPIRE       string = "coins.expire"
+	AUDIT_TRANSFER_REVIEW    string = "transfer.review"
+	AUDIT_INVITE_CREATE      string = "invite.create"
+)
+
+// AuditEvent
+//
+// @Description Запись журнала аудита. ActorID пустой, если действие выполнено из консоли или системой. Before и After — состояние объекта до и после изменения, RequestID и IP пустые вне HTTP-запроса. Таблица только дополняется: изменение и удаление записей запрещены триггером, а хеш-цепочка (chainSeq, prevHash, hash) выявляет правки в обход триггера
+type AuditEvent struct {
+	ID         uuid.UUID              `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	ActorID    *uuid.UUID             `gorm:"type:uuid;index" json:"actorId,omitempty"`
+	Action     string                 `gorm:"type:varchar(100);not null;index" json:"action"`
+	TargetType string                 `gorm:"type:varchar(50);index:idx_audit_events_target,priority:1" json:"targetType"`
+	TargetID   string                 `gorm:"type:varchar(100);index;index:idx_audit_events_target,priority:2" json:"targetId"`
+	Before     map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"before,omitempty" swaggertype:"object"`
+	After      map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"after,omitempty" swaggertype:"object"`
+	Details    string                 `gorm:"type:text" json:"details,omitempty"`
+	RequestID  string                 `gorm:"type:varchar(100);index" json:"requestId,omitempty"`
+	IP         string                 `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	CreatedAt  time.Time              `gorm:"precision:6;index" json:"createdAt"`
+	ChainLink
+}
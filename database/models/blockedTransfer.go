@@ -0,0 +1,44 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	TRANSFER_RULE_MAX_AMOUNT      string = "max_amount"
+	TRANSFER_RULE_NEW_ACCOUNT     string = "new_account"
+	TRANSFER_RULE_DAILY_LIMIT     string = "daily_limit"
+	TRANSFER_RULE_WEEKLY_LIMIT    string = "weekly_limit"
+	TRANSFER_RULE_RECIPIENT_LIMIT string = "recipient_daily_limit"
+	TRANSFER_RULE_VELOCITY        string = "velocity"
+
+	BLOCKED_TRANSFER_OPEN     string = "open"
+	BLOCKED_TRANSFER_REVIEWED string = "reviewed"
+)
+
+// TransferViolation
+//
+// @Description Нарушенное правило переводов. Limit — предел правила, Used — сколько уже использовано к моменту перевода (монет, переводов или секунд с регистрации)
+type TransferViolation struct {
+	Rule    string `json:"rule" example:"daily_limit"`
+	Message string `json:"message" example:"За сутки можно перевести не больше 1000 монет, уже переведено 900"`
+	Limit   int64  `json:"limit" example:"1000"`
+	Used    int64  `json:"used" example:"900"`
+}
+
+// BlockedTransfer
+//
+// @Description Перевод, отклоненный правилами переводов, для разбора админом. Монеты не списывались
+type BlockedTransfer struct {
+	ID          uuid.UUID           `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	SenderID    uuid.UUID           `gorm:"type:uuid;not null;index" json:"senderId"`
+	RecipientID uuid.UUID           `gorm:"type:uuid;not null" json:"recipientId"`
+	Amount      uint                `gorm:"not null" json:"amount" example:"200"`
+	Violations  []TransferViolation `gorm:"type:jsonb;serializer:json" json:"violations"`
+	Status      string              `gorm:"type:varchar(20);not null;index" json:"status" example:"open"`
+	ReviewedBy  *uuid.UUID          `gorm:"type:uuid" json:"reviewedBy,omitempty"`
+	Note        string              `gorm:"type:varchar(255)" json:"note,omitempty"`
+	ReviewedAt  *time.Time          `json:"reviewedAt,omitempty"`
+	CreatedAt   time.Time           `gorm:"precision:6;index" json:"createdAt"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// BULK_GRANT_STATUS_PENDING задание ждет обработки следующей порции.
+	BULK_GRANT_STATUS_PENDING   string = "pending"
+	BULK_GRANT_STATUS_COMPLETED string = "completed"
+	// BULK_GRANT_STATUS_FAILED порция не применилась. Уже начисленное остается, задание можно
+	// продолжить.
+	BULK_GRANT_STATUS_FAILED string = "failed"
+
+	BULK_GRANT_ITEM_PENDING string = "pending"
+	BULK_GRANT_ITEM_GRANTED string = "granted"
+)
+
+// BulkGrantJob
+//
+// @Description Задание массового начисления. ChunkSize = 0 — все начисления применены одной транзакцией, иначе они применяются порциями в фоне. Прогресс — granted из items
+type BulkGrantJob struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	AdminID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"adminId"`
+	ChunkSize     int        `gorm:"not null" json:"chunkSize"`
+	Status        string     `gorm:"type:varchar(20);not null;index" json:"status" example:"pending"`
+	Items         int        `gorm:"not null" json:"items"`
+	Granted       int        `gorm:"not null" json:"granted"`
+	TotalAmount   int64      `gorm:"not null" json:"totalAmount"`
+	GrantedAmount int64      `gorm:"not null" json:"grantedAmount"`
+	Error         string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt     time.Time  `gorm:"precision:6" json:"createdAt"`
+	UpdatedAt     time.Time  `gorm:"precision:6" json:"updatedAt"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BulkGrantItem одно начисление задания. Line — номер строки в загруженном файле.
+type BulkGrantItem struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	JobID         uuid.UUID  `gorm:"type:uuid;not null;index:idx_bulk_grant_items_job_line,priority:1" json:"jobId"`
+	Line          int        `gorm:"not null;index:idx_bulk_grant_items_job_line,priority:2" json:"line"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null" json:"userId"`
+	Username      string     `gorm:"type:varchar(100);not null" json:"username"`
+	Amount        uint       `gorm:"not null" json:"amount"`
+	Reason        string     `gorm:"type:varchar(255);not null" json:"reason"`
+	Status        string     `gorm:"type:varchar(20);not null" json:"status" example:"pending"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transactionId,omitempty"`
+	EntryID       *uuid.UUID `gorm:"type:uuid" json:"entryId,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MaxCartQuantity максимальное количество одного товара в корзине.
+const MaxCartQuantity = 100
+
+// CartItem
+//
+// @Description Строка корзины сотрудника. Item — название мерча или SKU варианта
+type CartItem struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_cart_items_user_item" json:"-"`
+	Item      string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_cart_items_user_item" json:"item"`
+	MerchID   uuid.UUID  `gorm:"type:uuid;not null" json:"-"`
+	VariantID *uuid.UUID `gorm:"type:uuid" json:"-"`
+	Quantity  uint       `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time  `gorm:"precision:6" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"precision:6" json:"updatedAt"`
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ChainLink звено хеш-цепочки таблицы: порядковый номер записи, хеш предыдущей записи и хеш
+// этой записи (ее содержимого вместе с PrevHash). Правка или удаление строки напрямую в БД
+// рвет цепочку, что находит проверка chain.Verify.
+type ChainLink struct {
+	ChainSeq *int64 `gorm:"uniqueIndex" json:"chainSeq,omitempty"`
+	PrevHash string `gorm:"type:varchar(64)" json:"prevHash,omitempty"`
+	Hash     string `gorm:"type:varchar(64)" json:"hash,omitempty"`
+}
+
+// Chained запись, входящая в хеш-цепочку своей таблицы.
+type Chained interface {
+	ChainTable() string
+	ChainID() uuid.UUID
+	ChainPayload() []interface{}
+	Link() *ChainLink
+}
+
+// ChainHash хеш записи номер seq с содержимым payload после записи с хешем prevHash.
+func ChainHash(prevHash string, seq int64, payload []interface{}) (string, error) {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%s", prevHash, seq, content)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ChainTime время в том виде, в котором оно входит в хеш: с точностью до микросекунд, как его
+// хранит Postgres, и в UTC.
+func ChainTime(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// appendToChain присоединяет новую запись к концу цепочки. Advisory-блокировка до конца
+// транзакции выстраивает вставки в таблицу в очередь, чтобы цепочка не разветвилась. При
+// вставке нескольких записей одним запросом последнее звено берется из предыдущего элемента:
+// в БД его еще нет.
+func appendToChain(tx *gorm.DB, record Chained) error {
+	last, ok := tx.InstanceGet(chainLastKey)
+	if !ok {
+		previous, err := ChainHead(tx.Session(&gorm.Session{NewDB: true}), record.ChainTable())
+		if err != nil {
+			return err
+		}
+		last = previous
+	}
+	prev := last.(ChainLink)
+
+	seq := int64(1)
+	if prev.ChainSeq != nil {
+		seq = *prev.ChainSeq + 1
+	}
+	hash, err := ChainHash(prev.Hash, seq, record.ChainPayload())
+	if err != nil {
+		return err
+	}
+	link := ChainLink{ChainSeq: &seq, PrevHash: prev.Hash, Hash: hash}
+	*record.Link() = link
+	tx.InstanceSet(chainLastKey, link)
+	return nil
+}
+
+const chainLastKey = "chain:last"
+
+// ChainHead блокирует цепочку таблицы до конца транзакции и возвращает ее последнее звено.
+func ChainHead(db *gorm.DB, table string) (ChainLink, error) {
+	var last ChainLink
+	if err := db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "chain:"+table).Error; err != nil {
+		return last, err
+	}
+	err := db.Table(table).
+		Select("chain_seq, hash").
+		Where("chain_seq IS NOT NULL").
+		Order("chain_seq DESC").
+		Limit(1).
+		Scan(&last).Error
+	return last, err
+}
+
+// prepareChained заполняет ID и время создания до вставки: оба входят в хеш, поэтому их нельзя
+// оставлять на значения по умолчанию из БД.
+func prepareChained(id *uuid.UUID, createdAt *time.Time) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+	if createdAt.IsZero() {
+		*createdAt = time.Now()
+	}
+	*createdAt = createdAt.Truncate(time.Microsecond)
+}
+
+func (t *Transaction) ChainTable() string { return "transactions" }
+func (t *Transaction) ChainID() uuid.UUID { return t.ID }
+func (t *Transaction) Link() *ChainLink   { return &t.ChainLink }
+func (t *Transaction) ChainPayload() []interface{} {
+	payload := []interface{}{t.ID, t.FromUser, t.ToUser, t.Amount, t.Memo, t.Category, ChainTime(t.CreatedAt)}
+	// Вид операции появился позже цепочки: у обычных переводов его нет в хеше, чтобы хеши
+	// уже записанных переводов не изменились.
+	if t.Kind != "" && t.Kind != TRANSACTION_KIND_TRANSFER {
+		payload = append(payload, t.Kind, optionalID(t.AdminID), t.Reason)
+	}
+	return payload
+}
+
+// BeforeCreate присоединяет перевод к хеш-цепочке.
+func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
+	prepareChained(&t.ID, &t.CreatedAt)
+	return appendToChain(tx, t)
+}
+
+func (p *Purchase) ChainTable() string { return "purchases" }
+func (p *Purchase) ChainID() uuid.UUID { return p.ID }
+func (p *Purchase) Link() *ChainLink   { return &p.ChainLink }
+func (p *Purchase) ChainPayload() []interface{} {
+	return []interface{}{p.ID, p.UserID, p.MerchID, optionalID(p.VariantID), p.Quantity, optionalID(p.OrderID), ChainTime(p.CreatedAt)}
+}
+
+// BeforeCreate присоединяет покупку к хеш-цепочке.
+func (p *Purchase) BeforeCreate(tx *gorm.DB) error {
+	prepareChained(&p.ID, &p.CreatedAt)
+	return appendToChain(tx, p)
+}
+
+func (e *AuditEvent) ChainTable() string { return "audit_events" }
+func (e *AuditEvent) ChainID() uuid.UUID { return e.ID }
+func (e *AuditEvent) Link() *ChainLink   { return &e.ChainLink }
+func (e *AuditEvent) ChainPayload() []interface{} {
+	return []interface{}{e.ID, optionalID(e.ActorID), e.Action, e.TargetType, e.TargetID, e.Before, e.After,
+		e.Details, e.RequestID, e.IP, ChainTime(e.CreatedAt)}
+}
+
+// BeforeCreate присоединяет запись аудита к хеш-цепочке.
+func (e *AuditEvent) BeforeCreate(tx *gorm.DB) error {
+	prepareChained(&e.ID, &e.CreatedAt)
+	return appendToChain(tx, e)
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LOT_SOURCE_BALANCE партия для монет, которые были на кошельке до учета партий или
+// появились на нем не через журнал (исправление сверки). Такие монеты не сгорают.
+const LOT_SOURCE_BALANCE string = "BALANCE"
+
+// CoinLot
+//
+// @Description Партия монет на кошельке сотрудника. Сумма остатков партий равна балансу кошелька. Монеты тратятся начиная с партии, которая сгорит раньше, партии без срока — последними. При переводе срок монет переходит к получателю вместе с ними
+type CoinLot struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId"`
+	Amount    uint       `gorm:"not null" json:"amount"`
+	Remaining uint       `gorm:"not null" json:"remaining"`
+	Source    string     `gorm:"type:varchar(20);not null" json:"source" example:"MINT"`
+	EntryID   *uuid.UUID `gorm:"type:uuid" json:"entryId,omitempty"`
+	GrantedAt time.Time  `gorm:"precision:6;not null" json:"grantedAt"`
+	ExpiresAt *time.Time `gorm:"index" json:"expiresAt,omitempty"`
+	CreatedAt time.Time  `gorm:"precision:6" json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// IdempotencyKey
+//
+// @Description Сохраненный ответ на запрос с заголовком Idempotency-Key. StatusCode = 0, пока запрос выполняется.
+type IdempotencyKey struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key"`
+	Method      string    `gorm:"type:varchar(10);not null"`
+	Path        string    `gorm:"type:varchar(255);not null"`
+	Fingerprint string    `gorm:"type:varchar(64);not null"`
+	StatusCode  int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"type:varchar(100)"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"precision:6;index"`
+}
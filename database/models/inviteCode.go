@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// InviteCode
+//
+// @Description Одноразовый код приглашения, выданный админом
+type InviteCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	Code      string     `gorm:"type:varchar(64);unique;not null" json:"code"`
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"createdBy"`
+	UsedBy    *uuid.UUID `gorm:"type:uuid" json:"usedBy,omitempty"`
+	UsedAt    *time.Time `gorm:"precision:6" json:"usedAt,omitempty"`
+	ExpiresAt *time.Time `gorm:"precision:6" json:"expiresAt,omitempty"`
+	CreatedAt time.Time  `gorm:"precision:6" json:"createdAt"`
+}
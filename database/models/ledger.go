@@ -0,0 +1,64 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	LEDGER_ACCOUNT_USER   string = "USER"
+	LEDGER_ACCOUNT_SYSTEM string = "SYSTEM"
+
+	SYSTEM_ACCOUNT_MINT    string = "system:mint"
+	SYSTEM_ACCOUNT_REVENUE string = "system:revenue"
+	SYSTEM_ACCOUNT_OPENING string = "system:opening"
+	// SYSTEM_ACCOUNT_CORRECTIONS встречный счет корректирующих записей сверки.
+	SYSTEM_ACCOUNT_CORRECTIONS string = "system:corrections"
+	// SYSTEM_ACCOUNT_EXPIRED счет, на который списываются сгоревшие монеты.
+	SYSTEM_ACCOUNT_EXPIRED string = "system:expired"
+)
+
+const (
+	ENTRY_OPENING    string = "OPENING"
+	ENTRY_TRANSFER   string = "TRANSFER"
+	ENTRY_PURCHASE   string = "PURCHASE"
+	ENTRY_MINT       string = "MINT"
+	ENTRY_ORDER      string = "ORDER"
+	ENTRY_REFUND     string = "REFUND"
+	ENTRY_REVERSAL   string = "REVERSAL"
+	ENTRY_CORRECTION string = "CORRECTION"
+	ENTRY_EXPIRY     string = "EXPIRY"
+)
+
+// LedgerAccount
+//
+// @Description Счет в журнале проводок. Счет сотрудника привязан к его кошельку, системные счета (эмиссия, выручка магазина) могут уходить в минус.
+type LedgerAccount struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	Code      string     `gorm:"type:varchar(100);unique;not null"`
+	Type      string     `gorm:"type:varchar(20);not null"`
+	UserID    *uuid.UUID `gorm:"type:uuid;uniqueIndex"`
+	CreatedAt time.Time  `gorm:"precision:6"`
+}
+
+// JournalEntry
+//
+// @Description Запись журнала: набор проводок, сумма которых всегда равна нулю
+type JournalEntry struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	Kind      string    `gorm:"type:varchar(50);not null;index"`
+	Reference string    `gorm:"type:varchar(100);index"`
+	Postings  []Posting `gorm:"foreignKey:EntryID"`
+	CreatedAt time.Time `gorm:"precision:6"`
+}
+
+// Posting
+//
+// @Description Проводка по счету: положительная сумма — кредит (поступление), отрицательная — дебет (списание)
+type Posting struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	EntryID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Amount    int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"precision:6"`
+}
@@ -4,9 +4,12 @@ import "github.com/google/uuid"
 
 // Merch
 //
-// @Description Структура сделки
+// @Description Структура сделки. Stock пустой — запас не ограничен. Если у мерча есть варианты, покупается конкретный вариант по SKU
 type Merch struct {
-	ID    uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
-	Name  string    `gorm:"unique;not null"`
-	Price uint      `gorm:"not null"`
+	ID                uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	Name              string    `gorm:"unique;not null"`
+	Price             uint      `gorm:"not null"`
+	Stock             *uint
+	LowStockThreshold uint           `gorm:"not null;default:0"`
+	Variants          []MerchVariant `gorm:"foreignKey:MerchID"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// MerchVariant
+//
+// @Description Вариант мерча (размер, цвет). Price пустой — действует цена мерча, Stock пустой — запас не ограничен
+type MerchVariant struct {
+	ID         uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	MerchID    uuid.UUID         `gorm:"type:uuid;not null;index" json:"-"`
+	SKU        string            `gorm:"type:varchar(64);unique;not null" json:"sku"`
+	Attributes map[string]string `gorm:"type:jsonb;serializer:json" json:"attributes"`
+	Price      *uint             `json:"price,omitempty"`
+	Stock      *uint             `json:"stock"`
+	CreatedAt  time.Time         `gorm:"precision:6" json:"-"`
+}
+
+// EffectivePrice цена варианта с учетом цены мерча.
+func (v MerchVariant) EffectivePrice(merch Merch) uint {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return merch.Price
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ORDER_STATUS_PLACED           string = "placed"
+	ORDER_STATUS_READY_FOR_PICKUP string = "ready_for_pickup"
+	ORDER_STATUS_FULFILLED        string = "fulfilled"
+	ORDER_STATUS_CANCELLED        string = "cancelled"
+)
+
+// OrderTransitions допустимые переходы статусов заказа.
+var OrderTransitions = map[string][]string{
+	ORDER_STATUS_PLACED:           {ORDER_STATUS_READY_FOR_PICKUP, ORDER_STATUS_CANCELLED},
+	ORDER_STATUS_READY_FOR_PICKUP: {ORDER_STATUS_FULFILLED, ORDER_STATUS_CANCELLED},
+}
+
+// Order
+//
+// @Description Заказ: оформленный из корзины или разовая покупка. PickupCode сотрудник называет при получении товара
+type Order struct {
+	ID           uuid.UUID   `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	UserID       uuid.UUID   `gorm:"type:uuid;not null;index" json:"userId"`
+	Status       string      `gorm:"type:varchar(30);not null;index" json:"status"`
+	Total        uint        `gorm:"not null" json:"total"`
+	PickupCode   string      `gorm:"type:varchar(6)" json:"pickupCode,omitempty"`
+	CancelReason string      `gorm:"type:varchar(255)" json:"cancelReason,omitempty"`
+	Items        []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
+	CreatedAt    time.Time   `gorm:"precision:6;index" json:"createdAt"`
+	UpdatedAt    time.Time   `gorm:"precision:6" json:"updatedAt"`
+	FulfilledAt  *time.Time  `gorm:"precision:6" json:"fulfilledAt,omitempty"`
+	CancelledAt  *time.Time  `gorm:"precision:6" json:"cancelledAt,omitempty"`
+}
+
+// OrderItem
+//
+// @Description Строка заказа. Price — цена одной штуки на момент оформления
+type OrderItem struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	OrderID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	MerchID   uuid.UUID  `gorm:"type:uuid;not null" json:"merchId"`
+	VariantID *uuid.UUID `gorm:"type:uuid" json:"variantId,omitempty"`
+	Item      string     `gorm:"type:varchar(100);not null" json:"item"`
+	Quantity  uint       `gorm:"not null" json:"quantity"`
+	Price     uint       `gorm:"not null" json:"price"`
+}
@@ -7,10 +7,14 @@ import (
 
 // Purchase
 //
-// @Description Структура сделки
+// @Description Структура сделки. Покупки через /api/buy ссылаются на заказ из одной строки, по которому отслеживается выдача
 type Purchase struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;OnDelete:CASCADE"`
-	MerchID   uuid.UUID `gorm:"type:uuid;not null"`
-	CreatedAt time.Time `gorm:"precision:6"`
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;OnDelete:CASCADE"`
+	MerchID   uuid.UUID  `gorm:"type:uuid;not null"`
+	VariantID *uuid.UUID `gorm:"type:uuid;index"`
+	Quantity  uint       `gorm:"not null;default:1"`
+	OrderID   *uuid.UUID `gorm:"type:uuid;index"`
+	CreatedAt time.Time  `gorm:"precision:6"`
+	ChainLink
 }
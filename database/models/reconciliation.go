@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	RECONCILIATION_TRIGGER_SCHEDULE string = "schedule"
+	RECONCILIATION_TRIGGER_CLI      string = "cli"
+	RECONCILIATION_TRIGGER_MANUAL   string = "manual"
+
+	MISMATCH_STATUS_OPEN       string = "open"
+	MISMATCH_STATUS_RESOLVED   string = "resolved"
+	MISMATCH_STATUS_SUPERSEDED string = "superseded"
+
+	// MISMATCH_RESOLUTION_LEDGER признать остаток кошелька: в журнал пишется корректирующая запись
+	// на разницу, кошелек не меняется.
+	MISMATCH_RESOLUTION_LEDGER string = "ledger"
+	// MISMATCH_RESOLUTION_WALLET вернуть кошельку остаток, посчитанный по истории.
+	MISMATCH_RESOLUTION_WALLET string = "wallet"
+)
+
+// ReconciliationRun
+//
+// @Description Запуск сверки кошельков с историей операций
+type ReconciliationRun struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	Trigger           string     `gorm:"type:varchar(20);not null" json:"trigger" example:"schedule"`
+	ActorID           *uuid.UUID `gorm:"type:uuid" json:"actorId,omitempty"`
+	Wallets           int64      `gorm:"not null" json:"wallets"`
+	Mismatches        int        `gorm:"not null" json:"mismatches"`
+	UnbalancedEntries []string   `gorm:"type:jsonb;serializer:json" json:"unbalancedEntries"`
+	StartedAt         time.Time  `gorm:"precision:6" json:"startedAt"`
+	FinishedAt        time.Time  `gorm:"precision:6;index" json:"finishedAt"`
+}
+
+// BalanceMismatch
+//
+// @Description Расхождение остатка кошелька с историей операций. Breakdown — из чего сложился ожидаемый остаток: стартовый баланс, начисления, полученные и отправленные переводы, покупки, возвраты. Difference = walletCoin − expectedBalance
+type BalanceMismatch struct {
+	ID              uuid.UUID        `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	RunID           uuid.UUID        `gorm:"type:uuid;not null;index" json:"runId"`
+	UserID          uuid.UUID        `gorm:"type:uuid;not null;index" json:"userId"`
+	WalletCoin      int64            `gorm:"not null" json:"walletCoin"`
+	ExpectedBalance int64            `gorm:"not null" json:"expectedBalance"`
+	Difference      int64            `gorm:"not null" json:"difference"`
+	Breakdown       map[string]int64 `gorm:"type:jsonb;serializer:json" json:"breakdown" swaggertype:"object"`
+	Status          string           `gorm:"type:varchar(20);not null;index" json:"status" example:"open"`
+	Resolution      string           `gorm:"type:varchar(20)" json:"resolution,omitempty" example:"ledger"`
+	ResolvedBy      *uuid.UUID       `gorm:"type:uuid" json:"resolvedBy,omitempty"`
+	Reason          string           `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	EntryID         *uuid.UUID       `gorm:"type:uuid" json:"entryId,omitempty"`
+	ResolvedAt      *time.Time       `json:"resolvedAt,omitempty"`
+	CreatedAt       time.Time        `gorm:"precision:6" json:"createdAt"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RefreshToken
+//
+// @Description Refresh-токен. Хранится только хэш токена; все токены, полученные ротацией от одного входа, образуют семейство
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null;index"`
+	TokenHash string     `gorm:"type:varchar(64);unique;not null"`
+	ExpiresAt time.Time  `gorm:"precision:6;not null"`
+	UsedAt    *time.Time `gorm:"precision:6"`
+	RevokedAt *time.Time `gorm:"precision:6"`
+	CreatedAt time.Time  `gorm:"precision:6"`
+}
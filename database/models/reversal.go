@@ -0,0 +1,26 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	REVERSAL_PURCHASE string = "purchase"
+	REVERSAL_TOP_UP   string = "top_up"
+)
+
+// Reversal
+//
+// @Description Компенсирующая запись: возврат покупки или отмена начисления админом. Исходные записи не удаляются. Amount — изменение баланса сотрудника: положительное при возврате, отрицательное при отмене начисления
+type Reversal struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key" json:"id"`
+	Kind      string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_reversals_target,priority:1" json:"kind" example:"purchase"`
+	TargetID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_reversals_target,priority:2" json:"targetId"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"userId"`
+	AdminID   uuid.UUID `gorm:"type:uuid;not null" json:"adminId"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	Reason    string    `gorm:"type:varchar(255);not null" json:"reason"`
+	EntryID   uuid.UUID `gorm:"type:uuid;not null" json:"entryId"`
+	CreatedAt time.Time `gorm:"precision:6" json:"createdAt"`
+}
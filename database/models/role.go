@@ -0,0 +1,68 @@
+package models
+
+const (
+	PERMISSION_INFO_READ        string = "info:read"
+	PERMISSION_COINS_SEND       string = "coins:send"
+	PERMISSION_MERCH_BUY        string = "merch:buy"
+	PERMISSION_MERCH_WRITE      string = "merch:write"
+	PERMISSION_COINS_MINT       string = "coins:mint"
+	PERMISSION_USERS_READ       string = "users:read"
+	PERMISSION_USERS_INVITE     string = "users:invite"
+	PERMISSION_LEDGER_READ      string = "ledger:read"
+	PERMISSION_ROLES_MANAGE     string = "roles:manage"
+	PERMISSION_ORDERS_MANAGE    string = "orders:manage"
+	PERMISSION_REFUNDS_MANAGE   string = "refunds:manage"
+	PERMISSION_AUDIT_READ       string = "audit:read"
+	PERMISSION_LEDGER_CORRECT   string = "ledger:correct"
+	PERMISSION_TRANSFERS_REVIEW string = "transfers:review"
+)
+
+// Permissions все известные права с описанием. ADMIN_ROLE всегда получает их полностью.
+var Permissions = []Permission{
+	{Name: PERMISSION_INFO_READ, Description: "Просмотр своего баланса, инвентаря и истории"},
+	{Name: PERMISSION_COINS_SEND, Description: "Перевод монет другим сотрудникам"},
+	{Name: PERMISSION_MERCH_BUY, Description: "Покупка мерча"},
+	{Name: PERMISSION_MERCH_WRITE, Description: "Добавление и изменение мерча"},
+	{Name: PERMISSION_COINS_MINT, Description: "Начисление монет"},
+	{Name: PERMISSION_USERS_READ, Description: "Просмотр пользователей"},
+	{Name: PERMISSION_USERS_INVITE, Description: "Выпуск кодов приглашения"},
+	{Name: PERMISSION_LEDGER_READ, Description: "Сверка журнала проводок"},
+	{Name: PERMISSION_ROLES_MANAGE, Description: "Управление ролями и их правами"},
+	{Name: PERMISSION_ORDERS_MANAGE, Description: "Выдача и отмена заказов"},
+	{Name: PERMISSION_REFUNDS_MANAGE, Description: "Возврат покупок и отмена начислений"},
+	{Name: PERMISSION_AUDIT_READ, Description: "Просмотр журнала аудита"},
+	{Name: PERMISSION_LEDGER_CORRECT, Description: "Исправление расхождений кошельков с историей операций"},
+	{Name: PERMISSION_TRANSFERS_REVIEW, Description: "Разбор переводов, отклоненных правилами переводов"},
+}
+
+// EmployeePermissions права, которые получает EMPLOYEE_ROLE при первом создании.
+// Дальше набор меняется через API и при перезапуске не перезаписывается.
+var EmployeePermissions = []string{
+	PERMISSION_INFO_READ,
+	PERMISSION_COINS_SEND,
+	PERMISSION_MERCH_BUY,
+}
+
+// Role
+//
+// @Description Роль пользователя — именованный набор прав
+type Role struct {
+	Name        string `gorm:"type:varchar(100);primaryKey" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// Permission
+//
+// @Description Право на действие, например merch:write
+type Permission struct {
+	Name        string `gorm:"type:varchar(100);primaryKey" json:"name"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// RolePermission
+//
+// @Description Связь роли и права
+type RolePermission struct {
+	RoleName       string `gorm:"type:varchar(100);primaryKey"`
+	PermissionName string `gorm:"type:varchar(100);primaryKey;index"`
+}
@@ -5,13 +5,45 @@ import (
 	"time"
 )
 
-// Transaction
+const (
+	TRANSFER_CATEGORY_THANKS string = "thanks"
+	TRANSFER_CATEGORY_BET    string = "bet"
+	TRANSFER_CATEGORY_GIFT   string = "gift"
+
+	// MaxMemoLength максимальная длина сообщения к переводу в символах.
+	MaxMemoLength = 200
+
+	// TRANSACTION_KIND_TRANSFER перевод между сотрудниками.
+	TRANSACTION_KIND_TRANSFER string = "transfer"
+	// TRANSACTION_KIND_GRANT начисление из казны по решению админа.
+	TRANSACTION_KIND_GRANT string = "grant"
+	// TRANSACTION_KIND_DEBIT списание в казну по решению админа.
+	TRANSACTION_KIND_DEBIT string = "debit"
+	// TRANSACTION_KIND_EXPIRY сгорание монет, срок которых истек: от сотрудника казне, без админа.
+	TRANSACTION_KIND_EXPIRY string = "expiry"
+)
+
+// TransferCategories допустимые категории перевода. Категория необязательна.
+var TransferCategories = []string{
+	TRANSFER_CATEGORY_THANKS,
+	TRANSFER_CATEGORY_BET,
+	TRANSFER_CATEGORY_GIFT,
+}
+
+// Transaction перевод монет. Kind — вид операции: у начислений и списаний контрагент — казна,
+// AdminID — админ, принявший решение, Reason — его обоснование.
 //
 // @Description Структура транзакции
 type Transaction struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
-	FromUser  uuid.UUID `gorm:"type:uuid;not null"`
-	ToUser    uuid.UUID `gorm:"type:uuid;not null"`
-	Amount    uint      `gorm:"not null"`
-	CreatedAt time.Time `gorm:"precision:6"`
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	FromUser  uuid.UUID  `gorm:"type:uuid;not null;index:idx_transactions_from_user_created,priority:1"`
+	ToUser    uuid.UUID  `gorm:"type:uuid;not null;index:idx_transactions_to_user_created,priority:1"`
+	Amount    uint       `gorm:"not null"`
+	Memo      string     `gorm:"type:varchar(255)"`
+	Category  string     `gorm:"type:varchar(20);index"`
+	Kind      string     `gorm:"type:varchar(20);not null;default:'transfer';index"`
+	AdminID   *uuid.UUID `gorm:"type:uuid"`
+	Reason    string     `gorm:"type:varchar(255)"`
+	CreatedAt time.Time  `gorm:"precision:6;index:idx_transactions_from_user_created,priority:2;index:idx_transactions_to_user_created,priority:2"`
+	ChainLink
 }
@@ -8,8 +8,29 @@ import (
 const (
 	ADMIN_ROLE    string = "ADMIN_ROLE"
 	EMPLOYEE_ROLE string = "EMPLOYEE_ROLE"
+	// SYSTEM_ROLE роль служебных пользователей, например казны. Прав у нее нет, войти под
+	// такими пользователями нельзя.
+	SYSTEM_ROLE string = "SYSTEM_ROLE"
+
+	// TREASURY_USERNAME никнейм казны: от ее имени в истории переводов показываются начисления
+	// админов, ей же уходят списания.
+	TREASURY_USERNAME string = "treasury"
 )
 
+// TreasuryUserID ID служебного пользователя казны. Фиксирован, чтобы совпадать во всех окружениях.
+var TreasuryUserID = uuid.MustParse("00000000-0000-0000-0000-00000000c0de")
+
+// Treasury служебный пользователь казны. Пароля у него нет, кошелька тоже: монеты казны
+// учитываются на счете эмиссии журнала.
+func Treasury() User {
+	return User{
+		ID:       TreasuryUserID,
+		Username: TREASURY_USERNAME,
+		Email:    "treasury@system.invalid",
+		Role:     SYSTEM_ROLE,
+	}
+}
+
 // User
 //
 // @Description Структура user
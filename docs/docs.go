@@ -15,24 +15,47 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/admin/merch/new": {
-            "post": {
+        "/api/.well-known/jwks.json": {
+            "get": {
+                "description": "Возвращает публичные ключи RS256/EdDSA, которыми другие сервисы могут проверять токены Shop без общего секрета. Ключ выбирается по заголовку kid токена.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Публичные ключи JWT (JWKS)",
+                "responses": {
+                    "200": {
+                        "description": "Набор публичных ключей",
+                        "schema": {
+                            "$ref": "#/definitions/utils.JWKS"
+                        }
+                    },
+                    "500": {
+                        "description": "Ключи подписи не настроены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/allowance-runs/{id}": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Позволяет добавить новый мерч или изменить цену существующего мерча. Проверяет корректность данных и наличие мерча.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Возвращает запуск правила и все сделанные им начисления с ID переводов в истории.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Добавление или изменение цены мерча",
+                "summary": "Запуск правила регулярного начисления",
                 "parameters": [
                     {
                         "type": "string",
@@ -42,36 +65,34 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Тело запроса",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.MerchInfo"
-                        }
+                        "type": "string",
+                        "description": "ID запуска",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Мерч успешно добавлен или цена обновлена",
+                        "description": "Запуск",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handlers.AllowanceRunDetails"
                         }
                     },
                     "400": {
-                        "description": "Некорректное тело запроса, неверный тип или цена мерча",
+                        "description": "Некорректный ID запуска",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Мерч с таким именем уже существует",
+                        "description": "Запуск не найден",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка добавления нового мерча или обновления цены",
+                        "description": "Ошибка получения запуска",
                         "schema": {
                             "type": "string"
                         }
@@ -79,24 +100,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/admin/users": {
-            "post": {
+        "/api/admin/allowances": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Позволяет перевести монеты работнику по его никнейму, проверяя корректность данных и существование получателя.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Возвращает все правила регулярных начислений с временем следующего и последнего запуска и ошибкой последней попытки.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Admin"
                 ],
-                "summary": "Перевод монет работнику",
+                "summary": "Правила регулярных начислений",
                 "parameters": [
                     {
                         "type": "string",
@@ -104,38 +122,20 @@ const docTemplate = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
-                    },
-                    {
-                        "description": "Тело запроса",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.SendMoney"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Перевод монет успешен",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "400": {
-                        "description": "Некорректное тело запроса или неверное количество монет",
+                        "description": "Правила",
                         "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "Не найден работник или кошелек получателя",
-                        "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AllowanceRule"
+                            }
                         }
                     },
                     "500": {
-                        "description": "Ошибка обновления баланса получателя или фиксации транзакции",
+                        "description": "Ошибка получения правил",
                         "schema": {
                             "type": "string"
                         }
@@ -143,9 +143,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/auth": {
-            "post": {
-                "description": "Авторизует пользователя по email и паролю, создавая учетную запись автоматически, если пользователя нет в базе.",
+        "/api/admin/allowances/{name}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает правило с названием name или заменяет его параметры. По расписанию schedule (cron из пяти полей: минута, час, день месяца, месяц, день недели, или @monthly, @weekly, @daily, @hourly) в часовом поясе timezone (по умолчанию UTC) казна начисляет amount монет каждому сотруднику с кошельком и ролью из roles; пустой roles — всем сотрудникам. Начисления попадают в историю как переводы от казны с причиной reason. За каждый период правило срабатывает один раз, даже при нескольких экземплярах сервиса. Выключенное правило (enabled = false) не запускается, при включении следующий запуск считается от текущего момента.",
                 "consumes": [
                     "application/json"
                 ],
@@ -153,56 +158,50 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "Авторизация пользователя",
+                "summary": "Создание или изменение правила регулярного начисления",
                 "parameters": [
                     {
-                        "description": "Тело запроса",
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "monthly",
+                        "description": "Название правила",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Параметры правила",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.AuthRequest"
+                            "$ref": "#/definitions/allowances.RuleInput"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Возвращает JWT-токен",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "201": {
-                        "description": "Пользователь создан автоматически",
+                        "description": "Правило сохранено",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.AllowanceRule"
                         }
                     },
                     "400": {
-                        "description": "Некорректное тело запроса",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Неверный пароль",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "408": {
-                        "description": "Запрос отменен клиентом",
+                        "description": "Некорректные параметры правила",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Внутренняя ошибка сервера",
+                        "description": "Ошибка сохранения правила",
                         "schema": {
                             "type": "string"
                         }
@@ -210,19 +209,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/auth/logout": {
-            "post": {
-                "description": "Отзывает текущий токен авторизации и добавляет его в список отозванных токенов.",
-                "consumes": [
-                    "application/json"
+        "/api/admin/allowances/{name}/runs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Возвращает последние запуски правила, новые первыми: период, статус, число получателей и сумму. Неудачные попытки тоже показываются, с ошибкой.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "Выход из системы",
+                "summary": "Запуски правила регулярного начисления",
                 "parameters": [
                     {
                         "type": "string",
@@ -230,26 +231,46 @@ const docTemplate = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "monthly",
+                        "description": "Название правила",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько запусков вернуть (по умолчанию 50, максимум 200)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Выход выполнен успешно",
+                        "description": "Запуски",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AllowanceRun"
                             }
                         }
                     },
-                    "401": {
-                        "description": "Отсутствует токен авторизации / Некорректный формат токена",
+                    "400": {
+                        "description": "Некорректный limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Правило не найдено",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Не удалось отозвать токен",
+                        "description": "Ошибка получения запусков",
                         "schema": {
                             "type": "string"
                         }
@@ -257,24 +278,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/buy/{item}": {
+        "/api/admin/audit": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Позволяет пользователю купить товар, указав его имя. Проверяется наличие средств на кошельке и успешность покупки.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Возвращает записи журнала аудита от новых к старым с курсорной пагинацией по (createdAt, id). Фильтры по автору, действию, объекту, ID запроса и периоду. Записи содержат состояние объекта до и после изменения.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Employee"
+                    "Admin"
                 ],
-                "summary": "Покупка товара пользователем",
+                "summary": "Журнал аудита",
                 "parameters": [
                     {
                         "type": "string",
@@ -285,34 +303,76 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "example": "\"item_name\"",
-                        "description": "Название товара",
-                        "name": "item",
-                        "in": "path",
-                        "required": true
+                        "description": "ID пользователя, выполнившего действие",
+                        "name": "actorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Действие, например merch.price",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Тип объекта, например merch",
+                        "name": "targetType",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID объекта",
+                        "name": "targetId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID запроса из заголовка X-Request-ID",
+                        "name": "requestId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2026-01-01T00:00:00Z",
+                        "description": "Начало периода, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2026-02-01T00:00:00Z",
+                        "description": "Конец периода, RFC3339, не включительно",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из nextCursor",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Информация о балансе и купленном товаре",
+                        "description": "Страница журнала",
                         "schema": {
-                            "$ref": "#/definitions/handlers.InfoAfterBying"
+                            "$ref": "#/definitions/handlers.AuditPage"
                         }
                     },
                     "400": {
-                        "description": "Недостаточно средств на кошельке",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "Покупатель или товар не найдены",
+                        "description": "Некорректные параметры или курсор",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка сохранения в базе данных",
+                        "description": "Ошибка получения журнала",
                         "schema": {
                             "type": "string"
                         }
@@ -320,24 +380,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/info": {
+        "/api/admin/audit/verify": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Возвращает информацию о кошельке, инвентаре и истории транзакций для конкретного пользователя.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Проходит хеш-цепочки переводов, покупок и журнала аудита и пересчитывает хеши. Для каждой цепочки возвращает число записей, хеш последней записи и первый разрыв, если строки меняли или удаляли напрямую в БД.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Employee"
+                    "Admin"
                 ],
-                "summary": "Получение информации о кошельке, инвентаре и транзакциях пользователя",
+                "summary": "Проверка хеш-цепочек",
                 "parameters": [
                     {
                         "type": "string",
@@ -349,25 +406,13 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Информация о кошельке и транзакциях",
-                        "schema": {
-                            "$ref": "#/definitions/handlers.InfoMain"
-                        }
-                    },
-                    "400": {
-                        "description": "Некорректный запрос",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "404": {
-                        "description": "Не найден пользователь или его данные",
+                        "description": "Результат проверки",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/chain.Report"
                         }
                     },
                     "500": {
-                        "description": "Ошибка на сервере при получении данных",
+                        "description": "Ошибка проверки цепочек",
                         "schema": {
                             "type": "string"
                         }
@@ -375,37 +420,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/merch": {
+        "/api/admin/blocked-transfers": {
             "get": {
-                "description": "Возвращает список товаров из базы данных или кэша Redis.",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Возвращает переводы, которые нарушили правила переводов, от новых к старым, с нарушенными правилами. По умолчанию — только неразобранные.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Employee"
+                    "Admin"
+                ],
+                "summary": "Переводы, отклоненные правилами",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Статус: open или reviewed (по умолчанию open)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID отправителя",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество записей, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Получение списка мерча",
                 "responses": {
                     "200": {
-                        "description": "Список мерча",
+                        "description": "Отклоненные переводы",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Merch"
+                                "$ref": "#/definitions/models.BlockedTransfer"
                             }
                         }
                     },
-                    "404": {
-                        "description": "Мерч не найден",
+                    "400": {
+                        "description": "Некорректные параметры",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка при поиске мерча",
+                        "description": "Ошибка получения отклоненных переводов",
                         "schema": {
                             "type": "string"
                         }
@@ -413,28 +487,76 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/ping": {
-            "get": {
-                "description": "Возвращает \"pong\", если сервер работает корректно",
+        "/api/admin/blocked-transfers/{id}/review": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Отмечает отклоненный перевод разобранным с комментарием админа и пишет запись аудита. Перевод при этом не выполняется: если он правомерен, сотрудник повторяет его после снятия ограничения.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "Ping"
+                    "Admin"
+                ],
+                "summary": "Разбор отклоненного перевода",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID отклоненного перевода",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Комментарий",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReviewBlockedTransferRequest"
+                        }
+                    }
                 ],
-                "summary": "Проверка работоспособности сервера",
                 "responses": {
                     "200": {
-                        "description": "pong",
+                        "description": "Перевод разобран",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.BlockedTransfer"
                         }
                     },
                     "400": {
-                        "description": "Ошибка написания pong. Ошибка подключения",
+                        "description": "Некорректный запрос или не указан комментарий",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Отклоненный перевод не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Перевод уже разобран",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка разбора отклоненного перевода",
                         "schema": {
                             "type": "string"
                         }
@@ -442,24 +564,25 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/sendCoin": {
+        "/api/admin/grants/bulk": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Позволяет пользователю отправить монеты другому пользователю, указав его имя и количество монет для отправки.",
+                "description": "Принимает список начислений в CSV (колонки username или email, amount, reason с заголовком) или JSON (массив объектов с теми же полями) и сначала проверяет весь список. С dryRun=true возвращает только отчет проверки. Если в списке есть ошибки, ничего не начисляется и возвращается 422 с отчетом. При chunkSize = 0 все начисления применяются одной транзакцией (201), иначе создается задание, которое применяется в фоне порциями по chunkSize (202); прогресс — GET /api/admin/grants/bulk/{id}. Начисления попадают в историю сотрудников как переводы от казны.",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "text/csv"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Employee"
+                    "Admin"
                 ],
-                "summary": "Отправка монет от одного пользователя другому",
+                "summary": "Массовое начисление монет",
                 "parameters": [
                     {
                         "type": "string",
@@ -469,36 +592,81 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Тело запроса",
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только проверить список",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер порции фонового применения, от 1 до 1000; 0 — одной транзакцией (по умолчанию)",
+                        "name": "chunkSize",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Список начислений",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.TransactionsResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/grants.Row"
+                            }
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Транзакция успешно создана",
+                        "description": "Отчет проверки (dryRun)",
                         "schema": {
-                            "$ref": "#/definitions/models.Transaction"
+                            "$ref": "#/definitions/grants.Report"
+                        }
+                    },
+                    "201": {
+                        "description": "Начисления применены",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BulkGrantResult"
+                        }
+                    },
+                    "202": {
+                        "description": "Задание создано и применяется в фоне",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BulkGrantResult"
                         }
                     },
                     "400": {
-                        "description": "Неверный запрос - некорректный ввод, недостаточно монет или попытка отправки себе",
+                        "description": "Некорректный список или параметры",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Не найдено - пользователь или кошелек не найдены",
+                    "413": {
+                        "description": "Список слишком большой",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "Неподдерживаемый формат",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "422": {
+                        "description": "В списке есть ошибки, ничего не начислено",
+                        "schema": {
+                            "$ref": "#/definitions/grants.Report"
+                        }
+                    },
                     "500": {
-                        "description": "Внутренняя ошибка сервера - проблемы с транзакцией в базе данных",
+                        "description": "Ошибка массового начисления",
                         "schema": {
                             "type": "string"
                         }
@@ -506,174 +674,4073 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/users": {
+        "/api/admin/grants/bulk/{id}": {
             "get": {
-                "description": "Возвращает список сотрудников с их ID, именем пользователя и email из базы данных или кэша Redis.",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Возвращает задание массового начисления: статус, сколько начислений применено (granted из items, grantedAmount из totalAmount) и ошибку, если порция не применилась, а также еще не примененные начисления.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Employee"
+                    "Admin"
+                ],
+                "summary": "Прогресс массового начисления",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID задания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Получение списка сотрудников",
                 "responses": {
                     "200": {
-                        "description": "Список сотрудников",
+                        "description": "Задание",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.User"
-                            }
+                            "$ref": "#/definitions/handlers.BulkGrantProgress"
                         }
                     },
-                    "404": {
-                        "description": "Сотрудники не найдены",
+                    "400": {
+                        "description": "Некорректный ID задания",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "408": {
-                        "description": "Запрос отменен клиентом",
+                    "404": {
+                        "description": "Задание не найдено",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка при поиске сотрудников",
+                        "description": "Ошибка получения задания",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.AuthRequest": {
-            "description": "Структура для входа пользователя",
-            "type": "object",
-            "properties": {
-                "email": {
-                    "type": "string",
-                    "example": "user@example.com"
-                },
-                "password": {
-                    "type": "string",
-                    "example": "securepassword"
-                }
-            }
-        },
-        "handlers.InfoAfterBying": {
-            "type": "object",
-            "properties": {
-                "balance": {},
-                "item": {},
-                "nickname": {}
-            }
         },
-        "handlers.InfoMain": {
-            "type": "object",
-            "properties": {
-                "coinHistory": {
-                    "type": "object",
-                    "properties": {
-                        "received": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "properties": {
-                                    "amount": {
-                                        "type": "integer"
-                                    },
-                                    "fromUser": {
-                                        "type": "string"
-                                    }
-                                }
-                            }
-                        },
-                        "sent": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "properties": {
-                                    "amount": {
-                                        "type": "integer"
-                                    },
-                                    "toUser": {
-                                        "type": "string"
-                                    }
-                                }
-                            }
-                        }
+        "/api/admin/grants/bulk/{id}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
                     }
-                },
-                "coins": {
-                    "type": "integer"
-                },
-                "inventory": {
-                    "type": "array",
-                    "items": {
-                        "type": "object",
-                        "properties": {
-                            "quantity": {
-                                "type": "integer"
-                            },
-                            "type": {
-                                "type": "string"
-                            }
+                ],
+                "description": "Возвращает в очередь задание, остановленное ошибкой (status = failed). Уже примененные начисления не повторяются, обработка продолжится с первого неначисленного.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Продолжение массового начисления",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID задания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Задание возвращено в очередь",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkGrantJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID задания",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Задание не найдено",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Задание не остановлено ошибкой",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка продолжения задания",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
             }
         },
-        "handlers.MerchInfo": {
-            "type": "object",
-            "properties": {
-                "price": {
-                    "type": "integer"
-                },
-                "type": {
-                    "type": "string"
-                }
-            }
-        },
-        "handlers.SendMoney": {
-            "type": "object",
-            "properties": {
-                "coin": {
+        "/api/admin/invites": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает одноразовый код приглашения для регистрации. Код позволяет зарегистрироваться с любым доменом email. Если expiresInHours не задан, код бессрочный.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Выпуск кода приглашения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.InviteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Код приглашения",
+                        "schema": {
+                            "$ref": "#/definitions/models.InviteCode"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка создания кода приглашения",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/ledger/verify": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Проверяет, что все записи журнала сбалансированы и остатки кошельков совпадают с суммой проводок по счетам сотрудников.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Проверка журнала проводок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат проверки",
+                        "schema": {
+                            "$ref": "#/definitions/ledger.Report"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка проверки журнала",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/merch/low-stock": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает товары и варианты с учетом остатка, у которых остаток не больше порога lowStockThreshold мерча, начиная с самых дефицитных.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Отчет о низких остатках",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Товары с низким остатком",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.StockInfo"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения остатков",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/merch/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Позволяет добавить новый мерч или изменить цену существующего мерча. Проверяет корректность данных и наличие мерча.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Добавление или изменение цены мерча",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.MerchInfo"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Мерч успешно добавлен или цена обновлена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса, неверный тип или цена мерча",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Мерч с таким именем уже существует",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Название мерча совпадает с SKU варианта",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка добавления нового мерча или обновления цены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/merch/{item}/restock": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Добавляет quantity к остатку товара или варианта (по SKU) и при необходимости меняет порог низкого остатка мерча. Изменение записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Пополнение остатка мерча",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название товара или SKU варианта",
+                        "name": "item",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RestockRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Новый остаток",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StockInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса или у товара есть варианты и нужен SKU",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Товар не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка пополнения остатка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/merch/{item}/variants/{sku}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает вариант мерча с заданным SKU или заменяет атрибуты, цену и остаток существующего. SKU не может совпадать с названием мерча. Изменение записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Создание или изменение варианта мерча",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название мерча",
+                        "name": "item",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "SKU варианта",
+                        "name": "sku",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.VariantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Вариант сохранен",
+                        "schema": {
+                            "$ref": "#/definitions/models.MerchVariant"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный SKU, тело запроса или цена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Мерч не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "SKU занят другим мерчем или совпадает с названием мерча",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сохранения варианта",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/orders": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает заказы всех сотрудников от новых к старым с фильтром по статусу и сотруднику. Коды выдачи не показываются: их называет сотрудник.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Список заказов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Статус: placed, ready_for_pickup, fulfilled или cancelled",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID сотрудника",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из nextCursor",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница заказов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrdersPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный фильтр, limit или курсор",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения заказов",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/orders/{id}/status": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Переводы: placed → ready_for_pickup → fulfilled, отмена (cancelled) из placed и ready_for_pickup. Выдача требует код выдачи сотрудника, отмена — причину; при отмене монеты возвращаются на кошелек, а товары на остаток в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Смена статуса заказа",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID заказа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новый статус",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrderStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Статус изменен",
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос или не указана причина отмены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Неверный код выдачи",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Заказ не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Недопустимый переход статуса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения статуса заказа",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/purchases/{id}/refund": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает компенсирующую запись к покупке: монеты возвращаются на кошелек сотрудника, товар — на остаток, заказ покупки отменяется. Исходная покупка не удаляется. Все изменения и запись аудита — в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Возврат покупки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID покупки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина возврата",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReversalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Покупка возвращена",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reversal"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID или не указана причина",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Покупка не найдена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Покупка уже возвращена или у нее нет проводки в журнале",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка возврата",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/reconciliation/mismatches": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает расхождения, найденные сверками, от новых к старым. По умолчанию — только открытые.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Расхождения кошельков с историей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Статус: open, resolved или superseded (по умолчанию open)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество записей, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Расхождения",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.BalanceMismatch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные параметры",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения расхождений",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/reconciliation/mismatches/{id}/resolve": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Закрывает открытое расхождение после проверки админом. resolution=ledger признает остаток кошелька верным и пишет в журнал корректирующую запись CORRECTION на разницу; resolution=wallet возвращает кошельку остаток по истории. Если кошелек или журнал изменились после сверки, возвращается 409. Исправление и запись аудита — в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Исправление расхождения",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID расхождения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Способ исправления и причина",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ResolveMismatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Расхождение исправлено",
+                        "schema": {
+                            "$ref": "#/definitions/models.BalanceMismatch"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, способ исправления или не указана причина",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Расхождение не найдено",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Расхождение уже закрыто или устарело",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка исправления расхождения",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/reconciliation/runs": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Сравнивает остаток каждого кошелька с остатком по истории операций (стартовый баланс, начисления, полученные и отправленные переводы, покупки, возвраты) и сохраняет найденные расхождения. Открытые расхождения прошлых сверок помечаются устаревшими. Ничего не исправляет.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Сверка кошельков с историей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Итог сверки",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReconciliationResult"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сверки",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/roles": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает роли с их правами и список всех известных прав.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Список ролей",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Роли и права",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RolesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения ролей",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/roles/grant": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Выдает существующему пользователю роль из таблицы ролей (по умолчанию ADMIN_ROLE). Изменение записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Выдача роли",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Роль выдана",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь или роль не найдены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "У пользователя уже эта роль, это последний администратор или служебный пользователь",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения роли",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/roles/revoke": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает пользователю роль EMPLOYEE_ROLE. Снять роль с последнего администратора нельзя. Изменение записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Снятие роли",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleChangeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Роль снята",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Пользователь не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "У пользователя нет дополнительной роли, это последний администратор или служебный пользователь",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения роли",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/roles/{name}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает роль или полностью заменяет ее набор прав. Права ADMIN_ROLE не меняются. Изменение записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Создание или изменение роли",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название роли",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Роль сохранена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RoleInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное название, тело запроса или неизвестное право",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Права ADMIN_ROLE и SYSTEM_ROLE не меняются",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сохранения роли",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/top-ups/{id}/reverse": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Создает компенсирующую запись к начислению админа (ID записи журнала из ответа POST /api/admin/users): монеты списываются с кошелька сотрудника обратно на счет эмиссии. Если сотрудник уже потратил монеты, возвращается 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Отмена ошибочного начисления",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID записи журнала начисления",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина отмены",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReversalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Начисление отменено",
+                        "schema": {
+                            "$ref": "#/definitions/models.Reversal"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID или не указана причина",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Начисление не найдено",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Начисление уже отменено или у сотрудника недостаточно монет",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка отмены начисления",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает пользователей постранично (курсорная пагинация) с балансом кошелька и количеством покупок. Фильтры по роли и префиксам email и никнейма, сортировка по балансу или дате создания. Служебные пользователи (казна) не показываются.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Список пользователей с балансом",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "EMPLOYEE_ROLE",
+                        "description": "Роль",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Префикс email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Префикс никнейма",
+                        "name": "username",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Сортировка: createdAt (по умолчанию) или balance",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Направление: desc (по умолчанию) или asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из nextCursor",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница пользователей",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AdminUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные параметры или курсор",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения пользователей",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Позволяет перевести монеты работнику по его никнейму, проверяя корректность данных и существование получателя. Начисление попадает в историю получателя как перевод от казны (treasury) с ID админа и причиной.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Перевод монет работнику",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SendMoney"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Перевод монет успешен, в ответе ID начисления",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса, неверное количество монет или превышен максимальный баланс получателя",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Не найден работник или кошелек получателя",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Запрос с этим ключом идемпотентности еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности уже использован с другим запросом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка обновления баланса получателя или фиксации транзакции",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/auth": {
+            "post": {
+                "description": "Авторизует пользователя по email и паролю. Для неизвестного email возвращает 401; учетная запись создается автоматически, только если включен AUTH_AUTO_PROVISION.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Авторизация пользователя",
+                "parameters": [
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AuthRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Возвращает access-токен и refresh-токен",
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenPair"
+                        }
+                    },
+                    "201": {
+                        "description": "Пользователь создан автоматически (только с AUTH_AUTO_PROVISION), возвращает пару токенов",
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Неверный пароль или пользователь не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "408": {
+                        "description": "Запрос отменен клиентом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/auth/logout": {
+            "post": {
+                "description": "Отзывает текущий токен авторизации и добавляет его в список отозванных токенов. Если передан refresh-токен, отзывается вся его сессия.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Выход из системы",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Refresh-токен текущей сессии",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Выход выполнен успешно",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Отсутствует токен авторизации / Некорректный формат токена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Не удалось отозвать токен",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/auth/refresh": {
+            "post": {
+                "description": "Выдает новый access-токен и новый refresh-токен. Refresh-токен одноразовый: повторное использование отзывает всю сессию.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Обновление токенов",
+                "parameters": [
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RefreshRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Новая пара токенов",
+                        "schema": {
+                            "$ref": "#/definitions/utils.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Refresh-токен недействителен, истек или использован повторно",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/buy": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Покупает quantity штук товара по названию или SKU варианта. Остаток, стоимость всех штук и баланс проверяются в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Покупка товара пользователем",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Товар и количество",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BuyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Информация о балансе и купленном товаре",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.InfoAfterBying"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос, недостаточно средств на кошельке или у товара есть варианты и нужен SKU",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Покупатель или товар не найдены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Товара недостаточно или запрос с этим ключом идемпотентности еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности уже использован с другим запросом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сохранения в базе данных",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/buy/{item}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Устаревший способ покупки одной штуки через GET: покупку могут случайно вызвать предзагрузка ссылок и история браузера. Используйте POST /api/buy. Ответ содержит заголовки Deprecation и Sunset; маршрут отключается параметром BUY_GET_ENABLED=false.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Покупка одной штуки товара (устарело)",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"item_name\"",
+                        "description": "Название товара или SKU варианта",
+                        "name": "item",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Информация о балансе и купленном товаре",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.InfoAfterBying"
+                        }
+                    },
+                    "400": {
+                        "description": "Недостаточно средств на кошельке или у товара есть варианты и нужен SKU",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Покупатель или товар не найдены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Товар закончился или запрос с этим ключом идемпотентности еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности уже использован с другим запросом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сохранения в базе данных",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cart": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает строки корзины с текущими ценами и итоговой суммой. Корзина кэшируется в Redis на минуту.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Просмотр корзины",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Корзина",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartView"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения корзины",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cart/items": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Прибавляет quantity штук товара (название мерча или SKU варианта) к корзине.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Добавление товара в корзину",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartItemRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Корзина после изменения",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartView"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса, количество или нужен SKU варианта",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Товар не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Недостаточно товара на складе",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения корзины",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/cart/items/{item}": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Заменяет количество товара в корзине. Количество 0 удаляет строку.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Изменение количества товара в корзине",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название мерча или SKU варианта",
+                        "name": "item",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartQuantityRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Корзина после изменения",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartView"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса, количество или нужен SKU варианта",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Товар не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Недостаточно товара на складе",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения корзины",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Удаление товара из корзины",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название мерча или SKU варианта",
+                        "name": "item",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Корзина после изменения",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CartView"
+                        }
+                    },
+                    "404": {
+                        "description": "Товар не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка изменения корзины",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/checkout": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Покупает все строки корзины одной транзакцией: либо списываются остатки и монеты за все товары и создается заказ, либо ничего не меняется. Корзина после оформления очищается.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Cart"
+                ],
+                "summary": "Оформление заказа",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Заказ оформлен",
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    "400": {
+                        "description": "Корзина пуста, недостаточно монет или у товара появились варианты",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Товар из корзины больше не продается",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Товар закончился или запрос с этим ключом идемпотентности еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Ключ идемпотентности уже использован с другим запросом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка оформления заказа",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/info": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает информацию о кошельке, инвентаре и истории транзакций для конкретного пользователя. Начисления и списания админов показываются как переводы от казны или казне (treasury) с видом grant или debit, никнеймом админа в grantedBy и причиной, сгоревшие монеты — как переводы казне с видом expiry. В expiring — монеты, которые сгорят в ближайшие дни, если их не потратить.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Получение информации о кошельке, инвентаре и транзакциях пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Информация о кошельке и транзакциях",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.InfoMain"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный запрос",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Не найден пользователь или его данные",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка на сервере при получении данных",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/merch": {
+            "get": {
+                "description": "Возвращает список товаров вместе с вариантами (SKU, атрибуты, цена, остаток) из базы данных или кэша Redis.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Получение списка мерча",
+                "responses": {
+                    "200": {
+                        "description": "Список мерча",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Merch"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Мерч не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка при поиске мерча",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/orders": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает заказы текущего пользователя с кодами выдачи, от новых к старым, с курсорной пагинацией.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Orders"
+                ],
+                "summary": "Мои заказы",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Статус: placed, ready_for_pickup, fulfilled или cancelled",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из nextCursor",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница заказов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrdersPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный фильтр, limit или курсор",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения заказов",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/orders/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Сотрудник может отменить свой заказ, пока он не собран (статус placed). Монеты возвращаются на кошелек, товары — на остаток в одной транзакции.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Orders"
+                ],
+                "summary": "Отмена своего заказа",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID заказа",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Причина отмены",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OrderCancelRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Заказ отменен",
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректный ID заказа",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Заказ не найден",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Заказ уже собран, выдан или отменен",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка отмены заказа",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ping": {
+            "get": {
+                "description": "Возвращает \"pong\", если сервер работает корректно",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Ping"
+                ],
+                "summary": "Проверка работоспособности сервера",
+                "responses": {
+                    "200": {
+                        "description": "pong",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Ошибка написания pong. Ошибка подключения",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/register": {
+            "post": {
+                "description": "Создает учетную запись с автоматически сгенерированным никнеймом и стартовым балансом. Без кода приглашения регистрация доступна только для доменов из REGISTRATION_ALLOWED_DOMAINS; при REGISTRATION_INVITE_REQUIRED код приглашения обязателен.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Регистрация сотрудника",
+                "parameters": [
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Пользователь создан",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RegisterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса, email или пароль",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Домен не разрешен или код приглашения недействителен",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Пользователь с таким email уже существует",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sendCoin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Позволяет пользователю отправить монеты другому пользователю, указав его имя и количество монет для отправки. Необязательно можно добавить сообщение (до 200 символов) и категорию: thanks, bet или gift. Перевод проверяется правилами переводов (TRANSFER_* и NEW_ACCOUNT_TRANSFER_COOLDOWN): при нарушении монеты не списываются, возвращается 422 со списком нарушенных правил, а перевод сохраняется для разбора админом.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Отправка монет от одного пользователя другому",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Тело запроса",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TransactionsResponse"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Транзакция успешно создана",
+                        "schema": {
+                            "$ref": "#/definitions/models.Transaction"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный запрос - некорректный ввод, слишком длинное сообщение, неизвестная категория, недостаточно монет, превышен максимальный баланс получателя или попытка отправки себе",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Не найдено - пользователь или кошелек не найдены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Запрос с этим ключом идемпотентности еще выполняется",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Перевод нарушает правила переводов (лимиты, частота, новый аккаунт) или ключ идемпотентности уже использован с другим запросом (текстом)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TransferRejected"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера - проблемы с транзакцией в базе данных",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/transactions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Возвращает переводы текущего пользователя от новых к старым с курсорной пагинацией по (createdAt, id). Фильтры по направлению, контрагенту, категории, виду операции, сумме и периоду. Начисления и списания админов идут от казны или казне (treasury) с никнеймом админа в grantedBy и причиной.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "История переводов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer {token}",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Направление: in или out",
+                        "name": "direction",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Никнейм контрагента",
+                        "name": "counterparty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Категория: thanks, bet или gift",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Вид операции: transfer, grant (начисление админа), debit (списание админа) или expiry (сгорание монет)",
+                        "name": "kind",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальная сумма включительно",
+                        "name": "minAmount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальная сумма включительно",
+                        "name": "maxAmount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2026-01-01T00:00:00Z",
+                        "description": "Начало периода, RFC3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2026-02-01T00:00:00Z",
+                        "description": "Конец периода, RFC3339, не включительно",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы, от 1 до 200 (по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Курсор следующей страницы из nextCursor",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Страница истории",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TransactionsPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные параметры или курсор",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка получения истории",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users": {
+            "get": {
+                "description": "Возвращает список сотрудников с их ID, именем пользователя и email из базы данных или кэша Redis.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Employee"
+                ],
+                "summary": "Получение списка сотрудников",
+                "responses": {
+                    "200": {
+                        "description": "Список сотрудников",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.User"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Сотрудники не найдены",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "408": {
+                        "description": "Запрос отменен клиентом",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка при поиске сотрудников",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "allowances.RuleInput": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Ежемесячное начисление"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "EMPLOYEE_ROLE"
+                    ]
+                },
+                "schedule": {
+                    "type": "string",
+                    "example": "0 9 1 * *"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                }
+            }
+        },
+        "chain.Break": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "хеш записи не совпадает с ее содержимым"
+                },
+                "seq": {
+                    "type": "integer"
+                }
+            }
+        },
+        "chain.ChainReport": {
+            "type": "object",
+            "properties": {
+                "broken": {
+                    "$ref": "#/definitions/chain.Break"
+                },
+                "headHash": {
+                    "type": "string"
+                },
+                "records": {
+                    "type": "integer"
+                },
+                "table": {
+                    "type": "string"
+                },
+                "unchained": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "chain.Report": {
+            "type": "object",
+            "properties": {
+                "chains": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/chain.ChainReport"
+                    }
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "grants.Grant": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "grants.Report": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/grants.RowError"
+                    }
+                },
+                "grants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/grants.Grant"
+                    }
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "totalAmount": {
+                    "type": "integer"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "grants.Row": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "email": {
+                    "type": "string",
+                    "example": "ivan@example.com"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Квартальная премия"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "ivan"
+                }
+            }
+        },
+        "grants.RowError": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "пользователь не найден"
+                }
+            }
+        },
+        "handlers.Adjustment": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "purchase"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AdminUser": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "purchaseCount": {
+                    "type": "integer"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AdminUsersResponse": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.AdminUser"
+                    }
+                }
+            }
+        },
+        "handlers.AllowanceRunDetails": {
+            "type": "object",
+            "properties": {
+                "grants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.AllowanceRunGrant"
+                    }
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "run": {
+                    "$ref": "#/definitions/models.AllowanceRun"
+                }
+            }
+        },
+        "handlers.AllowanceRunGrant": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "transactionId": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "ivan"
+                }
+            }
+        },
+        "handlers.AuditPage": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditEvent"
+                    }
+                },
+                "nextCursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.AuthRequest": {
+            "description": "Структура для входа пользователя",
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "securepassword"
+                }
+            }
+        },
+        "handlers.BulkGrantProgress": {
+            "type": "object",
+            "properties": {
+                "job": {
+                    "$ref": "#/definitions/models.BulkGrantJob"
+                },
+                "pending": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BulkGrantItem"
+                    }
+                }
+            }
+        },
+        "handlers.BulkGrantResult": {
+            "type": "object",
+            "properties": {
+                "job": {
+                    "$ref": "#/definitions/models.BulkGrantJob"
+                },
+                "report": {
+                    "$ref": "#/definitions/grants.Report"
+                }
+            }
+        },
+        "handlers.BuyRequest": {
+            "type": "object",
+            "properties": {
+                "item": {
+                    "type": "string",
+                    "example": "hoody-black-m"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "handlers.CartItemRequest": {
+            "type": "object",
+            "properties": {
+                "item": {
+                    "type": "string",
+                    "example": "TS-L-BLACK"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "handlers.CartLine": {
+            "type": "object",
+            "properties": {
+                "item": {
+                    "type": "string"
+                },
+                "lineTotal": {
+                    "type": "integer"
+                },
+                "merch": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.CartQuantityRequest": {
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "handlers.CartView": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.CartLine"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ExpiringCoins": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 300
+                },
+                "expiresAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.InfoAfterBying": {
+            "type": "object",
+            "properties": {
+                "balance": {},
+                "item": {},
+                "nickname": {},
+                "orderId": {},
+                "pickupCode": {},
+                "purchaseId": {},
+                "quantity": {}
+            }
+        },
+        "handlers.InfoMain": {
+            "type": "object",
+            "properties": {
+                "coinHistory": {
+                    "type": "object",
+                    "properties": {
+                        "adjustments": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.Adjustment"
+                            }
+                        },
+                        "received": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "amount": {
+                                        "type": "integer"
+                                    },
+                                    "category": {
+                                        "type": "string"
+                                    },
+                                    "fromUser": {
+                                        "type": "string"
+                                    },
+                                    "grantedBy": {
+                                        "type": "string"
+                                    },
+                                    "kind": {
+                                        "type": "string",
+                                        "example": "transfer"
+                                    },
+                                    "memo": {
+                                        "type": "string"
+                                    },
+                                    "reason": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        },
+                        "sent": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "amount": {
+                                        "type": "integer"
+                                    },
+                                    "category": {
+                                        "type": "string"
+                                    },
+                                    "grantedBy": {
+                                        "type": "string"
+                                    },
+                                    "kind": {
+                                        "type": "string",
+                                        "example": "transfer"
+                                    },
+                                    "memo": {
+                                        "type": "string"
+                                    },
+                                    "reason": {
+                                        "type": "string"
+                                    },
+                                    "toUser": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                },
+                "coins": {
+                    "type": "integer"
+                },
+                "expiring": {
+                    "description": "Expiring монеты, которые сгорят в ближайшие EXPIRY_NOTICE_DAYS дней, по датам сгорания.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ExpiringCoins"
+                    }
+                },
+                "inventory": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "quantity": {
+                                "type": "integer"
+                            },
+                            "sku": {
+                                "type": "string"
+                            },
+                            "type": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "handlers.InviteRequest": {
+            "type": "object",
+            "properties": {
+                "expiresInHours": {
+                    "type": "integer",
+                    "example": 72
+                }
+            }
+        },
+        "handlers.MerchInfo": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.OrderCancelRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Передумал"
+                }
+            }
+        },
+        "handlers.OrderStatusRequest": {
+            "type": "object",
+            "properties": {
+                "pickupCode": {
+                    "type": "string",
+                    "example": "042917"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Товар поврежден"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ready_for_pickup"
+                }
+            }
+        },
+        "handlers.OrdersPage": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Order"
+                    }
+                }
+            }
+        },
+        "handlers.ReconciliationResult": {
+            "type": "object",
+            "properties": {
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BalanceMismatch"
+                    }
+                },
+                "run": {
+                    "$ref": "#/definitions/models.ReconciliationRun"
+                }
+            }
+        },
+        "handlers.RefreshRequest": {
+            "description": "Refresh-токен, полученный при входе или предыдущем обновлении",
+            "type": "object",
+            "properties": {
+                "refreshToken": {
+                    "type": "string",
+                    "example": "m2Qx...Zk"
+                }
+            }
+        },
+        "handlers.RegisterRequest": {
+            "description": "Email, пароль и необязательный код приглашения",
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "inviteCode": {
+                    "type": "string",
+                    "example": "k3Jd9sLq"
+                },
+                "password": {
+                    "type": "string",
+                    "example": "securepassword"
+                }
+            }
+        },
+        "handlers.RegisterResponse": {
+            "description": "Сгенерированный никнейм, стартовый баланс и пара токенов",
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "expiresIn": {
+                    "type": "integer"
+                },
+                "refreshToken": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ResolveMismatchRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Начисление до появления журнала, подтверждено бухгалтерией"
+                },
+                "resolution": {
+                    "type": "string",
+                    "example": "ledger"
+                }
+            }
+        },
+        "handlers.RestockRequest": {
+            "description": "Quantity добавляется к остатку. Для товара без учета остатка учет начинается с Quantity",
+            "type": "object",
+            "properties": {
+                "lowStockThreshold": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "quantity": {
+                    "type": "integer",
+                    "example": 40
+                }
+            }
+        },
+        "handlers.ReversalRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Начислено не тому сотруднику"
+                }
+            }
+        },
+        "handlers.ReviewBlockedTransferRequest": {
+            "type": "object",
+            "properties": {
+                "note": {
+                    "type": "string",
+                    "example": "Оплата общего подарка, согласовано с руководителем"
+                }
+            }
+        },
+        "handlers.RoleChangeRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "Новый офис-менеджер"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "ADMIN_ROLE"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "CoolTiger1234"
+                }
+            }
+        },
+        "handlers.RoleChangeResponse": {
+            "type": "object",
+            "properties": {
+                "role": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RoleInfo": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.RoleRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Менеджер каталога"
+                },
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "merch:write",
+                        "info:read"
+                    ]
+                }
+            }
+        },
+        "handlers.RolesResponse": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Permission"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.RoleInfo"
+                    }
+                }
+            }
+        },
+        "handlers.SendMoney": {
+            "type": "object",
+            "properties": {
+                "coin": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "Reason обоснование начисления, видно получателю в истории.",
+                    "type": "string",
+                    "example": "Победа в хакатоне"
+                },
+                "toUser": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.StockInfo": {
+            "type": "object",
+            "properties": {
+                "item": {
+                    "type": "string"
+                },
+                "lowStockThreshold": {
+                    "type": "integer"
+                },
+                "merch": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.TransactionItem": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "category": {
+                    "type": "string",
+                    "example": "thanks"
+                },
+                "counterparty": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "direction": {
+                    "type": "string",
+                    "example": "in"
+                },
+                "grantedBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "transfer"
+                },
+                "memo": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TransactionsPage": {
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "type": "string"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.TransactionItem"
+                    }
+                }
+            }
+        },
+        "handlers.TransactionsResponse": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "thanks"
+                },
+                "coin": {
+                    "type": "integer"
+                },
+                "memo": {
+                    "type": "string",
+                    "example": "Спасибо за помощь с релизом"
+                },
+                "toUser": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TransferRejected": {
+            "type": "object",
+            "properties": {
+                "blockedId": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Перевод нарушает правила переводов"
+                },
+                "violations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TransferViolation"
+                    }
+                }
+            }
+        },
+        "handlers.VariantRequest": {
+            "description": "Price пустой — действует цена мерча, Stock пустой — запас не ограничен",
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "color": "black",
+                        "size": "L"
+                    }
+                },
+                "price": {
+                    "type": "integer",
+                    "example": 90
+                },
+                "stock": {
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "ledger.Mismatch": {
+            "type": "object",
+            "properties": {
+                "ledgerBalance": {
+                    "type": "integer"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "walletCoin": {
+                    "type": "integer"
+                }
+            }
+        },
+        "ledger.Report": {
+            "type": "object",
+            "properties": {
+                "balanced": {
+                    "type": "boolean"
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ledger.Mismatch"
+                    }
+                },
+                "unbalancedEntries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AllowanceRule": {
+            "description": "Правило регулярного начисления: amount монет каждому сотруднику с кошельком и ролью из roles (пустой список — всем, кроме служебных) по расписанию schedule в формате cron (минута, час, день месяца, месяц, день недели) в часовом поясе timezone",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 500
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "lastRunAt": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "nextRunAt": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Ежемесячное начисление"
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "EMPLOYEE_ROLE"
+                    ]
+                },
+                "schedule": {
+                    "type": "string",
+                    "example": "0 9 1 * *"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Europe/Moscow"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AllowanceRun": {
+            "description": "Запуск правила регулярного начисления за период scheduledAt. Успешный запуск за период может быть только один, неудачные попытки тоже сохраняются",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "recipients": {
+                    "type": "integer"
+                },
+                "ruleId": {
+                    "type": "string"
+                },
+                "scheduledAt": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "completed"
+                },
+                "totalAmount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AuditEvent": {
+            "description": "Запись журнала аудита. ActorID пустой, если действие выполнено из консоли или системой. Before и After — состояние объекта до и после изменения, RequestID и IP пустые вне HTTP-запроса. Таблица только дополняется: изменение и удаление записей запрещены триггером, а хеш-цепочка (chainSeq, prevHash, hash) выявляет правки в обход триггера",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actorId": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "chainSeq": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "prevHash": {
+                    "type": "string"
+                },
+                "requestId": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "targetType": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BalanceMismatch": {
+            "description": "Расхождение остатка кошелька с историей операций. Breakdown — из чего сложился ожидаемый остаток: стартовый баланс, начисления, полученные и отправленные переводы, покупки, возвраты. Difference = walletCoin − expectedBalance",
+            "type": "object",
+            "properties": {
+                "breakdown": {
+                    "type": "object"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "difference": {
+                    "type": "integer"
+                },
+                "entryId": {
+                    "type": "string"
+                },
+                "expectedBalance": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "resolution": {
+                    "type": "string",
+                    "example": "ledger"
+                },
+                "resolvedAt": {
+                    "type": "string"
+                },
+                "resolvedBy": {
+                    "type": "string"
+                },
+                "runId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "walletCoin": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.BlockedTransfer": {
+            "description": "Перевод, отклоненный правилами переводов, для разбора админом. Монеты не списывались",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer",
+                    "example": 200
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "recipientId": {
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "reviewedBy": {
+                    "type": "string"
+                },
+                "senderId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "open"
+                },
+                "violations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TransferViolation"
+                    }
+                }
+            }
+        },
+        "models.BulkGrantItem": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "entryId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "jobId": {
+                    "type": "string"
+                },
+                "line": {
                     "type": "integer"
                 },
-                "toUser": {
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
+                },
+                "transactionId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "handlers.TransactionsResponse": {
+        "models.BulkGrantJob": {
+            "description": "Задание массового начисления. ChunkSize = 0 — все начисления применены одной транзакцией, иначе они применяются порциями в фоне. Прогресс — granted из items",
             "type": "object",
             "properties": {
-                "coin": {
+                "adminId": {
+                    "type": "string"
+                },
+                "chunkSize": {
                     "type": "integer"
                 },
-                "toUser": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "granted": {
+                    "type": "integer"
+                },
+                "grantedAmount": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
+                },
+                "totalAmount": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.InviteCode": {
+            "description": "Одноразовый код приглашения, выданный админом",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "usedAt": {
+                    "type": "string"
+                },
+                "usedBy": {
                     "type": "string"
                 }
             }
         },
         "models.Merch": {
-            "description": "Структура сделки",
+            "description": "Структура сделки. Stock пустой — запас не ограничен. Если у мерча есть варианты, покупается конкретный вариант по SKU",
             "type": "object",
             "properties": {
                 "id": {
                     "type": "string"
                 },
+                "lowStockThreshold": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
                 },
                 "price": {
                     "type": "integer"
+                },
+                "stock": {
+                    "type": "integer"
+                },
+                "variants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MerchVariant"
+                    }
+                }
+            }
+        },
+        "models.MerchVariant": {
+            "description": "Вариант мерча (размер, цвет). Price пустой — действует цена мерча, Stock пустой — запас не ограничен",
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Order": {
+            "description": "Заказ: оформленный из корзины или разовая покупка. PickupCode сотрудник называет при получении товара",
+            "type": "object",
+            "properties": {
+                "cancelReason": {
+                    "type": "string"
+                },
+                "cancelledAt": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "fulfilledAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OrderItem"
+                    }
+                },
+                "pickupCode": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.OrderItem": {
+            "description": "Строка заказа. Price — цена одной штуки на момент оформления",
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "item": {
+                    "type": "string"
+                },
+                "merchId": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "variantId": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Permission": {
+            "description": "Право на действие, например merch:write",
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReconciliationRun": {
+            "description": "Запуск сверки кошельков с историей операций",
+            "type": "object",
+            "properties": {
+                "actorId": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "mismatches": {
+                    "type": "integer"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "type": "string",
+                    "example": "schedule"
+                },
+                "unbalancedEntries": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "wallets": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Reversal": {
+            "description": "Компенсирующая запись: возврат покупки или отмена начисления админом. Исходные записи не удаляются. Amount — изменение баланса сотрудника: положительное при возврате, отрицательное при отмене начисления",
+            "type": "object",
+            "properties": {
+                "adminId": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "entryId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "purchase"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "targetId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
                 }
             }
         },
@@ -681,23 +4748,69 @@ const docTemplate = `{
             "description": "Структура транзакции",
             "type": "object",
             "properties": {
+                "adminID": {
+                    "type": "string"
+                },
                 "amount": {
                     "type": "integer"
                 },
+                "category": {
+                    "type": "string"
+                },
+                "chainSeq": {
+                    "type": "integer"
+                },
                 "createdAt": {
                     "type": "string"
                 },
                 "fromUser": {
                     "type": "string"
                 },
+                "hash": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "kind": {
+                    "type": "string"
+                },
+                "memo": {
+                    "type": "string"
+                },
+                "prevHash": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
                 "toUser": {
                     "type": "string"
                 }
             }
         },
+        "models.TransferViolation": {
+            "description": "Нарушенное правило переводов. Limit — предел правила, Used — сколько уже использовано к моменту перевода (монет, переводов или секунд с регистрации)",
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "message": {
+                    "type": "string",
+                    "example": "За сутки можно перевести не больше 1000 монет, уже переведено 900"
+                },
+                "rule": {
+                    "type": "string",
+                    "example": "daily_limit"
+                },
+                "used": {
+                    "type": "integer",
+                    "example": 900
+                }
+            }
+        },
         "models.User": {
             "description": "Структура user",
             "type": "object",
@@ -724,6 +4837,60 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "utils.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.JWKS": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.JWK"
+                    }
+                }
+            }
+        },
+        "utils.TokenPair": {
+            "type": "object",
+            "properties": {
+                "expiresIn": {
+                    "type": "integer"
+                },
+                "refreshToken": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,120 @@
+package expiry
+
+import (
+	"Shop/allowances"
+	"Shop/audit"
+	"Shop/database/models"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/treasury"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Reason причина сгорания в истории сотрудника.
+const Reason = "Истек срок действия монет"
+
+// ExpireUser списывает монеты сотрудника, срок которых истек к now, и возвращает их количество.
+// Сгорание записывается в историю переводом вида expiry казне. Вызывать внутри транзакции.
+func ExpireUser(tx *gorm.DB, userID uuid.UUID, now time.Time) (uint, error) {
+	coins, err := treasury.LockWallets(tx, []uuid.UUID{userID})
+	if err != nil {
+		return 0, err
+	}
+	before, ok := coins[userID]
+	if !ok {
+		return 0, ledger.ErrWalletNotFound
+	}
+	lots, err := ledger.ExpiredLots(tx, userID, now)
+	if err != nil || len(lots) == 0 {
+		return 0, err
+	}
+	var amount uint
+	for _, lot := range lots {
+		amount += lot.Remaining
+	}
+
+	transaction, err := treasury.Record(tx, models.TRANSACTION_KIND_EXPIRY, uuid.Nil, userID, amount, Reason)
+	if err != nil {
+		return 0, err
+	}
+	entry, err := ledger.Expire(tx, userID, lots, transaction.ID.String())
+	if err != nil {
+		return 0, err
+	}
+	err = audit.Write(tx, uuid.Nil, audit.Event{
+		Action:     models.AUDIT_COINS_EXPIRE,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Before:     map[string]interface{}{"coin": before},
+		After:      map[string]interface{}{"coin": before - amount},
+		Details:    fmt.Sprintf("сгорело %d монет из %d партий, перевод %s, запись журнала %s", amount, len(lots), transaction.ID, entry.ID),
+	})
+	return amount, err
+}
+
+// Run списывает сгоревшие к now монеты всех сотрудников, каждого — своей транзакцией. Повторный
+// или параллельный запуск (другой экземпляр сервиса) ничего не спишет дважды: партии обнуляются
+// в той же транзакции под блокировкой кошелька. Ошибка по одному сотруднику не мешает остальным.
+func Run(db *gorm.DB, now time.Time) ([]uuid.UUID, uint64, error) {
+	var userIDs []uuid.UUID
+	if err := db.Model(&models.CoinLot{}).
+		Distinct("user_id").
+		Where("remaining > 0 AND expires_at <= ?", now).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, 0, fmt.Errorf("поиск сгоревших монет: %w", err)
+	}
+
+	var expired []uuid.UUID
+	var total uint64
+	var firstErr error
+	for _, userID := range userIDs {
+		var amount uint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			amount, err = ExpireUser(tx, userID, now)
+			return err
+		})
+		if err != nil {
+			loging.Log.WithError(err).Errorf("Ошибка списания сгоревших монет сотрудника %s", userID)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if amount > 0 {
+			expired = append(expired, userID)
+			total += uint64(amount)
+		}
+	}
+	return expired, total, firstErr
+}
+
+// Schedule списывает сгоревшие монеты в моменты расписания schedule по UTC, пока не отменен ctx.
+// onExpired получает сотрудников, у которых сгорели монеты, например чтобы сбросить кэш.
+func Schedule(ctx context.Context, db *gorm.DB, schedule allowances.Cron, onExpired func([]uuid.UUID)) {
+	for {
+		next := schedule.Next(time.Now().UTC())
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			expired, total, err := Run(db.WithContext(ctx), now)
+			if err != nil {
+				loging.Log.WithError(err).Error("Ошибка списания сгоревших монет")
+			}
+			loging.Log.Infof("Списание сгоревших монет завершено: сотрудников %d, монет %d", len(expired), total)
+			if len(expired) > 0 && onExpired != nil {
+				onExpired(expired)
+			}
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package grants
+
+import (
+	"Shop/audit"
+	"Shop/config"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/treasury"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxRows сколько начислений можно загрузить одним списком.
+	MaxRows = 5000
+	// MaxAmount сколько монет можно начислить одному сотруднику одной строкой.
+	MaxAmount = 100000
+	// MaxChunkSize самая большая порция фонового применения.
+	MaxChunkSize = 1000
+)
+
+var (
+	ErrTooManyRows  = fmt.Errorf("в списке больше %d начислений", MaxRows)
+	ErrInvalidChunk = fmt.Errorf("размер порции должен быть от 0 до %d", MaxChunkSize)
+	ErrInvalidList  = errors.New("список начислений содержит ошибки")
+	ErrJobNotFound  = errors.New("задание не найдено")
+	ErrNotResumable = errors.New("продолжить можно только остановленное с ошибкой задание")
+)
+
+// RowError ошибка в строке списка.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message" example:"пользователь не найден"`
+}
+
+// Grant проверенное начисление: получатель найден и у него есть кошелек.
+type Grant struct {
+	Line     int       `json:"line"`
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Amount   uint      `json:"amount"`
+	Reason   string    `json:"reason"`
+}
+
+// Report итог проверки списка. Valid — в списке нет ни одной ошибки, только такой список
+// можно применить.
+type Report struct {
+	Valid       bool       `json:"valid"`
+	Rows        int        `json:"rows"`
+	TotalAmount int64      `json:"totalAmount"`
+	Errors      []RowError `json:"errors"`
+	Grants      []Grant    `json:"grants"`
+}
+
+func (r *Report) fail(line int, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, RowError{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate проверяет весь список, ничего не меняя: получатель задан одним способом (или никнейм
+// и email указывают на одного человека), существует, не служебный, имеет кошелек и встречается
+// в списке один раз, сумма от 1 до MaxAmount, причина указана.
+func Validate(db *gorm.DB, rows []Row) (Report, error) {
+	report := Report{Rows: len(rows), Errors: []RowError{}, Grants: []Grant{}}
+	if len(rows) > MaxRows {
+		return report, ErrTooManyRows
+	}
+
+	var usernames, emails []string
+	for _, row := range rows {
+		if row.Username != "" {
+			usernames = append(usernames, row.Username)
+		}
+		if row.Email != "" {
+			emails = append(emails, strings.ToLower(row.Email))
+		}
+	}
+	var users []models.User
+	if err := db.Select("id, username, email, role").
+		Where("username IN ? OR LOWER(email) IN ?", nonEmpty(usernames), nonEmpty(emails)).
+		Find(&users).Error; err != nil {
+		return report, fmt.Errorf("поиск получателей: %w", err)
+	}
+	byUsername := make(map[string]models.User, len(users))
+	byEmail := make(map[string]models.User, len(users))
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		byUsername[user.Username] = user
+		byEmail[strings.ToLower(user.Email)] = user
+		ids = append(ids, user.ID)
+	}
+	var wallets []models.Wallet
+	if len(ids) > 0 {
+		if err := db.Where("user_id IN ?", ids).Find(&wallets).Error; err != nil {
+			return report, fmt.Errorf("поиск кошельков: %w", err)
+		}
+	}
+	balances := make(map[uuid.UUID]uint, len(wallets))
+	for _, wallet := range wallets {
+		balances[wallet.UserID] = wallet.Coin
+	}
+	limit := config.MaxWalletBalance()
+
+	seen := map[uuid.UUID]int{}
+	for _, row := range rows {
+		errorsBefore := len(report.Errors)
+
+		var user models.User
+		var found bool
+		switch {
+		case row.Username == "" && row.Email == "":
+			report.fail(row.Line, "нужно указать username или email")
+		case row.Username != "" && row.Email != "":
+			byName, okName := byUsername[row.Username]
+			byMail, okMail := byEmail[strings.ToLower(row.Email)]
+			if okName && okMail && byName.ID != byMail.ID {
+				report.fail(row.Line, "username %s и email %s принадлежат разным пользователям", row.Username, row.Email)
+			} else {
+				user, found = byName, okName && okMail
+			}
+		case row.Username != "":
+			user, found = byUsername[row.Username]
+		default:
+			user, found = byEmail[strings.ToLower(row.Email)]
+		}
+		balance, withWallet := balances[user.ID]
+		if len(report.Errors) == errorsBefore {
+			switch {
+			case !found:
+				report.fail(row.Line, "пользователь не найден")
+			case user.Role == models.SYSTEM_ROLE:
+				report.fail(row.Line, "служебному пользователю %s начислять нельзя", user.Username)
+			case !withWallet:
+				report.fail(row.Line, "у пользователя %s нет кошелька", user.Username)
+			case seen[user.ID] != 0:
+				report.fail(row.Line, "пользователь %s уже есть в строке %d", user.Username, seen[user.ID])
+			default:
+				seen[user.ID] = row.Line
+			}
+		}
+
+		if row.Amount <= 0 || row.Amount > MaxAmount {
+			report.fail(row.Line, "сумма должна быть от 1 до %d", MaxAmount)
+		} else if withWallet && limit > 0 && int64(balance)+row.Amount > int64(limit) {
+			report.fail(row.Line, "баланс пользователя %s превысил бы %d монет", user.Username, limit)
+		}
+		if row.Reason == "" {
+			report.fail(row.Line, "нужно указать причину")
+		} else if len([]rune(row.Reason)) > 255 {
+			report.fail(row.Line, "причина должна быть не длиннее 255 символов")
+		}
+
+		if len(report.Errors) == errorsBefore {
+			report.TotalAmount += row.Amount
+			report.Grants = append(report.Grants, Grant{
+				Line:     row.Line,
+				UserID:   user.ID,
+				Username: user.Username,
+				Amount:   uint(row.Amount),
+				Reason:   row.Reason,
+			})
+		}
+	}
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}
+
+// nonEmpty подставляет заглушку вместо пустого списка: IN () в Postgres — синтаксическая ошибка.
+func nonEmpty(values []string) []string {
+	if len(values) == 0 {
+		return []string{""}
+	}
+	return values
+}
+
+// Create сохраняет задание по проверенному списку. При chunkSize = 0 все начисления применяются
+// в той же транзакции, и задание возвращается завершенным; иначе оно ждет фоновой обработки
+// порциями по chunkSize. Вызывать внутри транзакции.
+func Create(tx *gorm.DB, adminID uuid.UUID, report Report, chunkSize int) (models.BulkGrantJob, []uuid.UUID, error) {
+	job := models.BulkGrantJob{
+		AdminID:     adminID,
+		ChunkSize:   chunkSize,
+		Status:      models.BULK_GRANT_STATUS_PENDING,
+		Items:       len(report.Grants),
+		TotalAmount: report.TotalAmount,
+	}
+	if chunkSize < 0 || chunkSize > MaxChunkSize {
+		return job, nil, ErrInvalidChunk
+	}
+	if !report.Valid {
+		return job, nil, ErrInvalidList
+	}
+	if len(report.Grants) == 0 {
+		return job, nil, ErrEmptyList
+	}
+
+	if err := tx.Create(&job).Error; err != nil {
+		return job, nil, err
+	}
+	items := make([]models.BulkGrantItem, 0, len(report.Grants))
+	for _, grant := range report.Grants {
+		items = append(items, models.BulkGrantItem{
+			JobID:    job.ID,
+			Line:     grant.Line,
+			UserID:   grant.UserID,
+			Username: grant.Username,
+			Amount:   grant.Amount,
+			Reason:   grant.Reason,
+			Status:   models.BULK_GRANT_ITEM_PENDING,
+		})
+	}
+	if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+		return job, nil, err
+	}
+
+	var granted []uuid.UUID
+	if chunkSize == 0 {
+		var err error
+		if granted, err = apply(tx, &job, items); err != nil {
+			return job, nil, err
+		}
+	}
+
+	// Запись аудита — после начислений: блокировка цепочки аудита берется последней.
+	err := audit.Write(tx, adminID, audit.Event{
+		Action:     models.AUDIT_BULK_GRANT,
+		TargetType: "bulk_grant",
+		TargetID:   job.ID.String(),
+		After:      map[string]interface{}{"items": job.Items, "totalAmount": job.TotalAmount, "chunkSize": chunkSize, "status": job.Status},
+	})
+	return job, granted, err
+}
+
+// apply начисляет items и отмечает их в задании.
+func apply(tx *gorm.DB, job *models.BulkGrantJob, items []models.BulkGrantItem) ([]uuid.UUID, error) {
+	userIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		userIDs = append(userIDs, item.UserID)
+	}
+	coins, err := treasury.LockWallets(tx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		item := &items[i]
+		before, ok := coins[item.UserID]
+		if !ok {
+			return nil, fmt.Errorf("строка %d: у пользователя %s нет кошелька", item.Line, item.Username)
+		}
+		transaction, entry, err := treasury.Grant(tx, job.AdminID, item.UserID, item.Amount, item.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", item.Line, err)
+		}
+		item.Status = models.BULK_GRANT_ITEM_GRANTED
+		item.TransactionID = &transaction.ID
+		item.EntryID = &entry.ID
+		if err := tx.Model(item).Select("status", "transaction_id", "entry_id").Updates(item).Error; err != nil {
+			return nil, err
+		}
+		err = audit.Write(tx, job.AdminID, audit.Event{
+			Action:     models.AUDIT_COINS_MINT,
+			TargetType: "user",
+			TargetID:   item.UserID.String(),
+			Before:     map[string]interface{}{"coin": before},
+			After:      map[string]interface{}{"coin": before + item.Amount},
+			Details: fmt.Sprintf("массовое начисление %s, строка %d: %d монет пользователю %s, перевод %s, запись журнала %s: %s",
+				job.ID, item.Line, item.Amount, item.Username, transaction.ID, entry.ID, item.Reason),
+		})
+		if err != nil {
+			return nil, err
+		}
+		coins[item.UserID] = before + item.Amount
+		job.Granted++
+		job.GrantedAmount += int64(item.Amount)
+	}
+
+	var pending int64
+	if err := tx.Model(&models.BulkGrantItem{}).
+		Where("job_id = ? AND status = ?", job.ID, models.BULK_GRANT_ITEM_PENDING).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending == 0 {
+		now := time.Now()
+		job.Status = models.BULK_GRANT_STATUS_COMPLETED
+		job.FinishedAt = &now
+	}
+	job.Error = ""
+	err = tx.Model(job).Select("status", "granted", "granted_amount", "error", "finished_at").Updates(job).Error
+	return userIDs, err
+}
+
+// ProcessNext применяет следующую порцию одного из ожидающих заданий. Задание блокируется
+// с SKIP LOCKED, поэтому несколько экземпляров сервиса не обработают одну порцию дважды.
+// Возвращает false, если ждущих заданий нет. Если порция не применилась, задание
+// останавливается со статусом failed, а начисленное раньше остается.
+func ProcessNext(db *gorm.DB) (bool, []uuid.UUID, error) {
+	var job models.BulkGrantJob
+	var granted []uuid.UUID
+	var applyErr error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", models.BULK_GRANT_STATUS_PENDING).
+			Order("created_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		var items []models.BulkGrantItem
+		if err := tx.Where("job_id = ? AND status = ?", job.ID, models.BULK_GRANT_ITEM_PENDING).
+			Order("line").
+			Limit(job.ChunkSize).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			now := time.Now()
+			return tx.Model(&job).Updates(map[string]interface{}{"status": models.BULK_GRANT_STATUS_COMPLETED, "finished_at": now}).Error
+		}
+
+		// Порция применяется в точке сохранения: при ошибке откатывается только она,
+		// а отметка об остановке задания фиксируется.
+		applyErr = tx.Transaction(func(chunk *gorm.DB) error {
+			granted, err = apply(chunk, &job, items)
+			return err
+		})
+		if applyErr != nil {
+			granted = nil
+			return tx.Model(&job).Updates(map[string]interface{}{
+				"status": models.BULK_GRANT_STATUS_FAILED,
+				"error":  applyErr.Error(),
+			}).Error
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if applyErr != nil {
+		return true, nil, fmt.Errorf("задание %s: %w", job.ID, applyErr)
+	}
+	return true, granted, nil
+}
+
+// Resume возвращает остановленное задание в очередь: следующие порции начнутся с первой
+// неначисленной строки.
+func Resume(tx *gorm.DB, adminID, jobID uuid.UUID) (models.BulkGrantJob, error) {
+	var job models.BulkGrantJob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", jobID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return job, ErrJobNotFound
+	}
+	if err != nil {
+		return job, err
+	}
+	if job.Status != models.BULK_GRANT_STATUS_FAILED {
+		return job, ErrNotResumable
+	}
+
+	previousError := job.Error
+	job.Status = models.BULK_GRANT_STATUS_PENDING
+	job.Error = ""
+	if err := tx.Model(&job).Select("status", "error").Updates(&job).Error; err != nil {
+		return job, err
+	}
+	err = audit.Write(tx, adminID, audit.Event{
+		Action:     models.AUDIT_BULK_GRANT,
+		TargetType: "bulk_grant",
+		TargetID:   job.ID.String(),
+		Before:     map[string]interface{}{"status": models.BULK_GRANT_STATUS_FAILED, "error": previousError},
+		After:      map[string]interface{}{"status": job.Status, "granted": job.Granted, "items": job.Items},
+		Details:    "продолжение задания",
+	})
+	return job, err
+}
+
+var wake = make(chan struct{}, 1)
+
+// Notify будит фоновую обработку, не дожидаясь следующего опроса.
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run обрабатывает ожидающие задания, пока не отменен ctx: после Notify или раз в interval,
+// чтобы подхватить задания других экземпляров и прерванные перезапуском. onGranted получает
+// сотрудников, которым начислены монеты, например чтобы сбросить кэш.
+func Run(ctx context.Context, db *gorm.DB, interval time.Duration, onGranted func([]uuid.UUID)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for ctx.Err() == nil {
+			processed, granted, err := ProcessNext(db.WithContext(ctx))
+			if err != nil {
+				loging.Log.WithError(err).Error("Ошибка массового начисления")
+			}
+			if len(granted) > 0 && onGranted != nil {
+				onGranted(granted)
+			}
+			if !processed {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package grants
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Row строка загруженного списка начислений. Получатель задается никнеймом или email,
+// Line — номер строки в файле (в CSV с учетом заголовка, в JSON — номер элемента с единицы).
+type Row struct {
+	Line     int    `json:"line,omitempty"`
+	Username string `json:"username,omitempty" example:"ivan"`
+	Email    string `json:"email,omitempty" example:"ivan@example.com"`
+	Amount   int64  `json:"amount" example:"500"`
+	Reason   string `json:"reason" example:"Квартальная премия"`
+}
+
+var ErrEmptyList = errors.New("список начислений пуст")
+
+// ParseCSV читает CSV с заголовком. Обязательны колонки amount и reason и хотя бы одна
+// из username и email, порядок колонок любой.
+func ParseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyList
+	}
+	if err != nil {
+		return nil, fmt.Errorf("заголовок CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	_, hasUsername := columns["username"]
+	_, hasEmail := columns["email"]
+	if !hasUsername && !hasEmail {
+		return nil, errors.New("в заголовке CSV нужна колонка username или email")
+	}
+	for _, required := range []string{"amount", "reason"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("в заголовке CSV нет колонки %s", required)
+		}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := Row{
+			Line:     line,
+			Username: field(record, "username"),
+			Email:    field(record, "email"),
+			Reason:   field(record, "reason"),
+		}
+		// Некорректную сумму отметит проверка, чтобы отчет показал все ошибки сразу.
+		row.Amount, err = strconv.ParseInt(field(record, "amount"), 10, 64)
+		if err != nil {
+			row.Amount = 0
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyList
+	}
+	return rows, nil
+}
+
+// ParseJSON читает JSON-массив строк списка.
+func ParseJSON(r io.Reader) ([]Row, error) {
+	var rows []Row
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("JSON: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyList
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+		rows[i].Username = strings.TrimSpace(rows[i].Username)
+		rows[i].Email = strings.TrimSpace(rows[i].Email)
+		rows[i].Reason = strings.TrimSpace(rows[i].Reason)
+	}
+	return rows, nil
+}
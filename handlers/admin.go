@@ -1,37 +1,52 @@
 package handlers
 
 import (
+	"Shop/audit"
+	"Shop/config"
 	"Shop/database/migrations"
 	"Shop/database/models"
+	"Shop/ledger"
 	"Shop/loging"
+	"Shop/treasury"
 	"Shop/utils"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm/clause"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type SendMoney struct {
 	NickTaker string `json:"toUser"`
 	Coin      uint   `json:"coin"`
+	// Reason обоснование начисления, видно получателю в истории.
+	Reason string `json:"reason,omitempty" example:"Победа в хакатоне"`
 }
 
 // PutMoneyHandler Перевод монет работнику
 //
 // @Summary Перевод монет работнику
-// @Description Позволяет перевести монеты работнику по его никнейму, проверяя корректность данных и существование получателя.
+// @Description Позволяет перевести монеты работнику по его никнейму, проверяя корректность данных и существование получателя. Начисление попадает в историю получателя как перевод от казны (treasury) с ID админа и причиной.
 // @Tags Admin
 // @Accept  json
 // @Produce  json
 // @Param Authorization header string true "Bearer {token}"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
 // @Param request body SendMoney true "Тело запроса"
-// @Success 200 {object} string "Перевод монет успешен"
-// @Failure 400 {object} string "Некорректное тело запроса или неверное количество монет"
+// @Success 200 {object} string "Перевод монет успешен, в ответе ID начисления"
+// @Failure 400 {object} string "Некорректное тело запроса, неверное количество монет или превышен максимальный баланс получателя"
 // @Failure 404 {object} string "Не найден работник или кошелек получателя"
 // @Failure 500 {object} string "Ошибка обновления баланса получателя или фиксации транзакции"
+// @Failure 409 {object} string "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} string "Ключ идемпотентности уже использован с другим запросом"
 // @Router /api/admin/users [post]
 // @Security BearerAuth
 func PutMoneyHandler(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +64,13 @@ func PutMoneyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	input.Reason = strings.TrimSpace(input.Reason)
+	if len([]rune(input.Reason)) > 255 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Слишком длинная причина начисления")
+		http.Error(w, "Причина начисления должна быть не длиннее 255 символов", http.StatusBadRequest)
+		return
+	}
+
 	if input.Coin == 0 || input.Coin > 1000 {
 		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Количество монет должно быть в диапазоне от 1 до 1000 включительно")
 		http.Error(w, "Количество монет должно быть в диапазоне от 1 до 1000 включительно", http.StatusBadRequest)
@@ -56,9 +78,10 @@ func PutMoneyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var userTaker models.User
-	tx := migrations.DB.Begin()
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
 	defer func() {
-		if r := recover(); r != nil {
+		if !committed {
 			tx.Rollback()
 		}
 	}()
@@ -76,21 +99,47 @@ func PutMoneyHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Кошелек получателя не найден.", http.StatusNotFound)
 		return
 	}
-	walletTaker.Coin += input.Coin
 
-	if err := tx.WithContext(ctx).Save(&walletTaker).Error; err != nil {
+	transaction, entry, err := treasury.Grant(tx, userID, userTaker.ID, input.Coin, input.Reason)
+	if errors.Is(err, ledger.ErrBalanceLimit) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Баланс получателя превысил бы максимальный")
+		http.Error(w, fmt.Sprintf("На кошельке получателя не может быть больше %d монет.", config.MaxWalletBalance()), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка обновления баланса получателя")
 		http.Error(w, "Ошибка обновления баланса получателя.", http.StatusInternalServerError)
 		return
 	}
 
+	details := fmt.Sprintf("начислено %d монет пользователю %s, перевод %s, запись журнала %s", input.Coin, userTaker.Username, transaction.ID, entry.ID)
+	if input.Reason != "" {
+		details += ": " + input.Reason
+	}
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_COINS_MINT,
+		TargetType: "user",
+		TargetID:   userTaker.ID.String(),
+		Before:     map[string]interface{}{"coin": walletTaker.Coin},
+		After:      map[string]interface{}{"coin": walletTaker.Coin + input.Coin},
+		Details:    details,
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка обновления баланса получателя.", http.StatusInternalServerError)
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
 		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
 		return
 	}
-	http.Error(w, "Перевод монет успешен", http.StatusOK)
+	committed = true
+
+	invalidateCache(r.Context(), fmt.Sprintf("wallet:%s", userTaker.ID), fmt.Sprintf("received:%s", userTaker.ID))
+	// ID записи журнала нужен, чтобы отменить ошибочное начисление через /api/admin/top-ups/{id}/reverse.
+	http.Error(w, "Перевод монет успешен. ID начисления: "+entry.ID.String(), http.StatusOK)
 }
 
 type MerchInfo struct {
@@ -110,6 +159,7 @@ type MerchInfo struct {
 // @Success 200 {object} string "Мерч успешно добавлен или цена обновлена"
 // @Failure 400 {object} string "Некорректное тело запроса, неверный тип или цена мерча"
 // @Failure 404 {object} string "Мерч с таким именем уже существует"
+// @Failure 409 {object} string "Название мерча совпадает с SKU варианта"
 // @Failure 500 {object} string "Ошибка добавления нового мерча или обновления цены"
 // @Router /api/admin/merch/new [post]
 // @Security BearerAuth
@@ -145,31 +195,42 @@ func AddOrChangeMerchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx := migrations.DB.Begin()
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
 	defer func() {
-		if r := recover(); r != nil {
+		if !committed {
 			tx.Rollback()
 		}
 	}()
 
+	var sameSKU int64
+	if err := tx.Model(&models.MerchVariant{}).Where("sku = ?", input.Type).Count(&sameSKU).Error; err != nil || sameSKU > 0 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, err, startTime, "Название мерча совпадает с SKU варианта: "+input.Type)
+		http.Error(w, "Название мерча совпадает с SKU варианта", http.StatusConflict)
+		return
+	}
+
+	var message string
+	var event audit.Event
 	var merchExist models.Merch
-	if err := tx.WithContext(ctx).Where("name = ?", input.Type).First(&merchExist).Error; err != nil {
+	if err := tx.Where("name = ?", input.Type).First(&merchExist).Error; err != nil {
 		merch := models.Merch{
 			Name:  input.Type,
 			Price: input.Price,
 		}
 
-		if err := tx.WithContext(ctx).Create(&merch).Error; err != nil {
-			tx.Rollback()
+		if err := tx.Create(&merch).Error; err != nil {
 			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка добавления нового мерча")
 			http.Error(w, "Ошибка добавления нового мерча", http.StatusInternalServerError)
 			return
 		}
 
-		loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Был создан новый мерч: "+input.Type)
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("Был создан новый мерч: " + input.Type)); err != nil {
-			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusBadRequest, nil, startTime, "Ошибка записи в write header")
+		message = "Был создан новый мерч: " + input.Type
+		event = audit.Event{
+			Action:     models.AUDIT_MERCH_CREATE,
+			TargetType: "merch",
+			TargetID:   merch.ID.String(),
+			After:      map[string]interface{}{"name": merch.Name, "price": merch.Price},
 		}
 	} else {
 		if merchExist.Price == input.Price {
@@ -177,26 +238,128 @@ func AddOrChangeMerchHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Цена мерча совпадает с заданной", http.StatusBadRequest)
 			return
 		}
+		previousPrice := merchExist.Price
 		merchExist.Price = input.Price
 
-		if err := tx.WithContext(ctx).Save(&merchExist).Error; err != nil {
-			tx.Rollback()
+		if err := tx.Save(&merchExist).Error; err != nil {
 			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка обновления цены мерча")
 			http.Error(w, "Ошибка обновления цены мерча", http.StatusInternalServerError)
 			return
 		}
 
-		loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Цена мерча "+input.Type+" была обновлена")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("Цена мерча " + input.Type + " была обновлена")); err != nil {
-			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusBadRequest, nil, startTime, "Ошибка записи в write header")
+		message = "Цена мерча " + input.Type + " была обновлена"
+		event = audit.Event{
+			Action:     models.AUDIT_MERCH_PRICE,
+			TargetType: "merch",
+			TargetID:   merchExist.ID.String(),
+			Before:     map[string]interface{}{"name": merchExist.Name, "price": previousPrice},
+			After:      map[string]interface{}{"name": merchExist.Name, "price": merchExist.Price},
 		}
 	}
 
+	if err := audit.Write(tx, userID, event); err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка сохранения мерча", http.StatusInternalServerError)
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
 		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
 		return
 	}
+	committed = true
+
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, message)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(message)); err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusBadRequest, nil, startTime, "Ошибка записи в write header")
+	}
+}
+
+type InviteRequest struct {
+	ExpiresInHours uint `json:"expiresInHours" example:"72"`
+}
+
+// CreateInviteHandler выпуск кода приглашения
+//
+// @Summary Выпуск кода приглашения
+// @Description Создает одноразовый код приглашения для регистрации. Код позволяет зарегистрироваться с любым доменом email. Если expiresInHours не задан, код бессрочный.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param request body InviteRequest false "Тело запроса"
+// @Success 201 {object} models.InviteCode "Код приглашения"
+// @Failure 400 {object} string "Некорректное тело запроса"
+// @Failure 500 {object} string "Ошибка создания кода приглашения"
+// @Router /api/admin/invites [post]
+// @Security BearerAuth
+func CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var input InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка генерации кода приглашения")
+		http.Error(w, "Ошибка создания кода приглашения", http.StatusInternalServerError)
+		return
+	}
+
+	invite := models.InviteCode{
+		Code:      base64.RawURLEncoding.EncodeToString(buf),
+		CreatedBy: userID,
+	}
+	if input.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(input.ExpiresInHours) * time.Hour)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&invite).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка создания кода приглашения")
+		http.Error(w, "Ошибка создания кода приглашения", http.StatusInternalServerError)
+		return
+	}
+
+	// Сам код в журнал не пишется: его читают аудиторы, а код — одноразовый пропуск к регистрации.
+	err := audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_INVITE_CREATE,
+		TargetType: "invite_code",
+		TargetID:   invite.ID.String(),
+		After:      map[string]interface{}{"expiresAt": invite.ExpiresAt},
+		Details:    "выпущен код приглашения",
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка создания кода приглашения", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка создания кода приглашения", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormatWithStatus(w, r, http.StatusCreated, invite)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusCreated, nil, startTime, "Создан код приглашения")
 }
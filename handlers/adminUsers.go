@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// AdminUser строка списка пользователей для админа. PurchaseCount — число купленных штук,
+// включая товары из заказов, кроме отмененных и возвращенных.
+type AdminUser struct {
+	ID            uuid.UUID `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	Balance       int64     `json:"balance"`
+	PurchaseCount int64     `json:"purchaseCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// AdminUsersResponse страница списка пользователей. NextCursor пуст на последней странице.
+type AdminUsersResponse struct {
+	Users      []AdminUser `json:"users"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// userCursor позиция последней строки страницы. Sort и Order сохраняются, чтобы курсор
+// нельзя было применить к выборке с другой сортировкой.
+type userCursor struct {
+	Sort      string    `json:"s"`
+	Order     string    `json:"o"`
+	Balance   int64     `json:"b,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// parseLimit читает limit из запроса: по умолчанию defaultPageLimit, не больше maxPageLimit.
+func parseLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, errors.New("limit должен быть от 1 до " + strconv.Itoa(maxPageLimit))
+	}
+	return limit, nil
+}
+
+// likePrefix экранирует спецсимволы LIKE и добавляет % для поиска по префиксу.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// ListUsersHandler список пользователей для админа
+//
+// @Summary Список пользователей с балансом
+// @Description Возвращает пользователей постранично (курсорная пагинация) с балансом кошелька и количеством покупок. Фильтры по роли и префиксам email и никнейма, сортировка по балансу или дате создания. Служебные пользователи (казна) не показываются.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param role query string false "Роль" example(EMPLOYEE_ROLE)
+// @Param email query string false "Префикс email"
+// @Param username query string false "Префикс никнейма"
+// @Param sort query string false "Сортировка: createdAt (по умолчанию) или balance"
+// @Param order query string false "Направление: desc (по умолчанию) или asc"
+// @Param limit query int false "Размер страницы, от 1 до 200 (по умолчанию 50)"
+// @Param cursor query string false "Курсор следующей страницы из nextCursor"
+// @Success 200 {object} AdminUsersResponse "Страница пользователей"
+// @Failure 400 {object} string "Некорректные параметры или курсор"
+// @Failure 500 {object} string "Ошибка получения пользователей"
+// @Router /api/admin/users [get]
+// @Security BearerAuth
+func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "createdAt"
+	}
+	var sortColumn string
+	switch sort {
+	case "createdAt":
+		sortColumn = "users.created_at"
+	case "balance":
+		sortColumn = "COALESCE(wallets.coin, 0)"
+	default:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректная сортировка: "+sort)
+		http.Error(w, "sort должен быть createdAt или balance", http.StatusBadRequest)
+		return
+	}
+
+	order := strings.ToLower(query.Get("order"))
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректное направление сортировки: "+order)
+		http.Error(w, "order должен быть asc или desc", http.StatusBadRequest)
+		return
+	}
+
+	db := migrations.DB.WithContext(ctx).
+		Table("users").
+		Select("users.id, users.username, users.email, users.role, users.created_at, " +
+			"COALESCE(wallets.coin, 0) AS balance, " +
+			"(SELECT COALESCE(SUM(purchases.quantity), 0) FROM purchases WHERE purchases.user_id = users.id AND purchases.order_id IS NULL " +
+			"AND NOT EXISTS (SELECT 1 FROM reversals WHERE reversals.kind = '" + models.REVERSAL_PURCHASE + "' AND reversals.target_id = purchases.id)) + " +
+			"(SELECT COALESCE(SUM(order_items.quantity), 0) FROM order_items JOIN orders ON orders.id = order_items.order_id " +
+			"WHERE orders.user_id = users.id AND orders.status <> '" + models.ORDER_STATUS_CANCELLED + "') AS purchase_count").
+		Joins("LEFT JOIN wallets ON wallets.user_id = users.id")
+	db = db.Where("users.role <> ?", models.SYSTEM_ROLE)
+
+	if role := query.Get("role"); role != "" {
+		db = db.Where("users.role = ?", role)
+	}
+	if email := query.Get("email"); email != "" {
+		db = db.Where("LOWER(users.email) LIKE ?", likePrefix(strings.ToLower(email)))
+	}
+	if username := query.Get("username"); username != "" {
+		db = db.Where("users.username LIKE ?", likePrefix(username))
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		var cursor userCursor
+		if err := utils.DecodeCursor(raw, &cursor); err != nil || cursor.Sort != sort || cursor.Order != order {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный курсор")
+			http.Error(w, "Некорректный курсор", http.StatusBadRequest)
+			return
+		}
+		comparison := "<"
+		if order == "asc" {
+			comparison = ">"
+		}
+		var value interface{} = cursor.CreatedAt
+		if sort == "balance" {
+			value = cursor.Balance
+		}
+		db = db.Where("("+sortColumn+", users.id) "+comparison+" (?, ?)", value, cursor.ID)
+	}
+
+	var users []AdminUser
+	if err := db.Order(sortColumn + " " + order + ", users.id " + order).Limit(limit + 1).Scan(&users).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения пользователей")
+		http.Error(w, "Ошибка получения пользователей", http.StatusInternalServerError)
+		return
+	}
+
+	response := AdminUsersResponse{Users: users}
+	if response.Users == nil {
+		response.Users = []AdminUser{}
+	}
+	if len(users) > limit {
+		response.Users = users[:limit]
+		last := response.Users[limit-1]
+		response.NextCursor = utils.EncodeCursor(userCursor{
+			Sort:      sort,
+			Order:     order,
+			Balance:   last.Balance,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	utils.JSONFormat(w, r, response)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получена страница пользователей: "+strconv.Itoa(len(response.Users)))
+}
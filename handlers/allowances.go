@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"Shop/allowances"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AllowanceRunGrant начисление, сделанное запуском правила.
+type AllowanceRunGrant struct {
+	Username      string    `json:"username" example:"ivan"`
+	Amount        uint      `json:"amount" example:"500"`
+	TransactionID uuid.UUID `json:"transactionId"`
+}
+
+// AllowanceRunDetails запуск правила с начислениями.
+type AllowanceRunDetails struct {
+	Run    models.AllowanceRun `json:"run"`
+	Rule   string              `json:"rule" example:"monthly"`
+	Grants []AllowanceRunGrant `json:"grants"`
+}
+
+// allowanceErrorStatus HTTP-статус и сообщение для ошибки регулярных начислений.
+func allowanceErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, allowances.ErrRuleNotFound):
+		return http.StatusNotFound, "Правило не найдено"
+	case errors.Is(err, allowances.ErrInvalidSchedule), errors.Is(err, allowances.ErrInvalidAmount),
+		errors.Is(err, allowances.ErrReasonRequired), errors.Is(err, allowances.ErrInvalidZone),
+		errors.Is(err, allowances.ErrUnknownRole), errors.Is(err, allowances.ErrNeverFires):
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, "Ошибка сохранения правила"
+}
+
+// ListAllowancesHandler правила регулярных начислений
+//
+// @Summary Правила регулярных начислений
+// @Description Возвращает все правила регулярных начислений с временем следующего и последнего запуска и ошибкой последней попытки.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} models.AllowanceRule "Правила"
+// @Failure 500 {object} string "Ошибка получения правил"
+// @Router /api/admin/allowances [get]
+// @Security BearerAuth
+func ListAllowancesHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rules := []models.AllowanceRule{}
+	if err := migrations.DB.WithContext(ctx).Order("name").Find(&rules).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения правил начислений")
+		http.Error(w, "Ошибка получения правил", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, rules)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, fmt.Sprintf("Получено правил начислений: %d", len(rules)))
+}
+
+// PutAllowanceHandler создание или изменение правила регулярного начисления
+//
+// @Summary Создание или изменение правила регулярного начисления
+// @Description Создает правило с названием name или заменяет его параметры. По расписанию schedule (cron из пяти полей: минута, час, день месяца, месяц, день недели, или @monthly, @weekly, @daily, @hourly) в часовом поясе timezone (по умолчанию UTC) казна начисляет amount монет каждому сотруднику с кошельком и ролью из roles; пустой roles — всем сотрудникам. Начисления попадают в историю как переводы от казны с причиной reason. За каждый период правило срабатывает один раз, даже при нескольких экземплярах сервиса. Выключенное правило (enabled = false) не запускается, при включении следующий запуск считается от текущего момента.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param name path string true "Название правила" example(monthly)
+// @Param request body allowances.RuleInput true "Параметры правила"
+// @Success 200 {object} models.AllowanceRule "Правило сохранено"
+// @Failure 400 {object} string "Некорректные параметры правила"
+// @Failure 500 {object} string "Ошибка сохранения правила"
+// @Router /api/admin/allowances/{name} [put]
+// @Security BearerAuth
+func PutAllowanceHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	name := mux.Vars(r)["name"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !roleNamePattern.MatchString(name) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректное название правила: "+name)
+		http.Error(w, "Некорректное название правила", http.StatusBadRequest)
+		return
+	}
+
+	var input allowances.RuleInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	input.Reason = strings.TrimSpace(input.Reason)
+	input.Timezone = strings.TrimSpace(input.Timezone)
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	rule, err := allowances.Save(tx, userID, name, input, time.Now())
+	if err != nil {
+		status, message := allowanceErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка сохранения правила "+name)
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormat(w, r, rule)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Сохранено правило начисления "+name)
+}
+
+// AllowanceRunsHandler запуски правила регулярного начисления
+//
+// @Summary Запуски правила регулярного начисления
+// @Description Возвращает последние запуски правила, новые первыми: период, статус, число получателей и сумму. Неудачные попытки тоже показываются, с ошибкой.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param name path string true "Название правила" example(monthly)
+// @Param limit query int false "Сколько запусков вернуть (по умолчанию 50, максимум 200)"
+// @Success 200 {array} models.AllowanceRun "Запуски"
+// @Failure 400 {object} string "Некорректный limit"
+// @Failure 404 {object} string "Правило не найдено"
+// @Failure 500 {object} string "Ошибка получения запусков"
+// @Router /api/admin/allowances/{name}/runs [get]
+// @Security BearerAuth
+func AllowanceRunsHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	name := mux.Vars(r)["name"]
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	db := migrations.DB.WithContext(ctx)
+
+	var rule models.AllowanceRule
+	if err := db.Where("name = ?", name).First(&rule).Error; err != nil {
+		status, message := http.StatusInternalServerError, "Ошибка получения запусков"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status, message = allowanceErrorStatus(allowances.ErrRuleNotFound)
+		}
+		loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Ошибка получения правила "+name)
+		http.Error(w, message, status)
+		return
+	}
+
+	runs := []models.AllowanceRun{}
+	if err := db.Where("rule_id = ?", rule.ID).Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения запусков правила "+name)
+		http.Error(w, "Ошибка получения запусков", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, runs)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, fmt.Sprintf("Получено запусков правила %s: %d", name, len(runs)))
+}
+
+// AllowanceRunHandler запуск правила с начислениями
+//
+// @Summary Запуск правила регулярного начисления
+// @Description Возвращает запуск правила и все сделанные им начисления с ID переводов в истории.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID запуска"
+// @Success 200 {object} AllowanceRunDetails "Запуск"
+// @Failure 400 {object} string "Некорректный ID запуска"
+// @Failure 404 {object} string "Запуск не найден"
+// @Failure 500 {object} string "Ошибка получения запуска"
+// @Router /api/admin/allowance-runs/{id} [get]
+// @Security BearerAuth
+func AllowanceRunHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	runID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID запуска")
+		http.Error(w, "Некорректный ID запуска", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	db := migrations.DB.WithContext(ctx)
+
+	details := AllowanceRunDetails{Grants: []AllowanceRunGrant{}}
+	if err := db.Where("id = ?", runID).First(&details.Run).Error; err != nil {
+		status, message := http.StatusInternalServerError, "Ошибка получения запуска"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status, message = http.StatusNotFound, "Запуск не найден"
+		}
+		loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Ошибка получения запуска "+runID.String())
+		http.Error(w, message, status)
+		return
+	}
+	if err := db.Model(&models.AllowanceRule{}).Where("id = ?", details.Run.RuleID).Pluck("name", &details.Rule).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения правила запуска")
+		http.Error(w, "Ошибка получения запуска", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Table("allowance_grants").
+		Select("users.username, allowance_grants.amount, allowance_grants.transaction_id").
+		Joins("JOIN users ON users.id = allowance_grants.user_id").
+		Where("allowance_grants.run_id = ?", runID).
+		Order("users.username").
+		Scan(&details.Grants).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения начислений запуска")
+		http.Error(w, "Ошибка получения запуска", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, details)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime,
+		fmt.Sprintf("Получен запуск %s: начислений %d", runID, len(details.Grants)))
+}
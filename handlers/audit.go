@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditPage страница журнала аудита. NextCursor пуст на последней странице.
+type AuditPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+type auditCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// AuditHandler журнал аудита
+//
+// @Summary Журнал аудита
+// @Description Возвращает записи журнала аудита от новых к старым с курсорной пагинацией по (createdAt, id). Фильтры по автору, действию, объекту, ID запроса и периоду. Записи содержат состояние объекта до и после изменения.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param actorId query string false "ID пользователя, выполнившего действие"
+// @Param action query string false "Действие, например merch.price"
+// @Param targetType query string false "Тип объекта, например merch"
+// @Param targetId query string false "ID объекта"
+// @Param requestId query string false "ID запроса из заголовка X-Request-ID"
+// @Param from query string false "Начало периода, RFC3339" example(2026-01-01T00:00:00Z)
+// @Param to query string false "Конец периода, RFC3339, не включительно" example(2026-02-01T00:00:00Z)
+// @Param limit query int false "Размер страницы, от 1 до 200 (по умолчанию 50)"
+// @Param cursor query string false "Курсор следующей страницы из nextCursor"
+// @Success 200 {object} AuditPage "Страница журнала"
+// @Failure 400 {object} string "Некорректные параметры или курсор"
+// @Failure 500 {object} string "Ошибка получения журнала"
+// @Router /api/admin/audit [get]
+// @Security BearerAuth
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := migrations.DB.WithContext(ctx).Model(&models.AuditEvent{})
+
+	if raw := query.Get("actorId"); raw != "" {
+		actorID, err := uuid.Parse(raw)
+		if err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный actorId")
+			http.Error(w, "Некорректный actorId", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("actor_id = ?", actorID)
+	}
+	for param, column := range map[string]string{
+		"action":     "action",
+		"targetType": "target_type",
+		"targetId":   "target_id",
+		"requestId":  "request_id",
+	} {
+		if value := query.Get(param); value != "" {
+			db = db.Where(column+" = ?", value)
+		}
+	}
+
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный from")
+		http.Error(w, "from должен быть в формате RFC3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный to")
+		http.Error(w, "to должен быть в формате RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from != nil {
+		db = db.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		db = db.Where("created_at < ?", *to)
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		var cursor auditCursor
+		if err := utils.DecodeCursor(raw, &cursor); err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный курсор")
+			http.Error(w, "Некорректный курсор", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var events []models.AuditEvent
+	if err := db.Order("created_at DESC, id DESC").Limit(limit + 1).Find(&events).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения журнала аудита")
+		http.Error(w, "Ошибка получения журнала", http.StatusInternalServerError)
+		return
+	}
+
+	page := AuditPage{Events: events}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		page.NextCursor = utils.EncodeCursor(auditCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	utils.JSONFormat(w, r, page)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получена страница журнала аудита: "+strconv.Itoa(len(page.Events)))
+}
@@ -8,19 +8,16 @@ import (
 	"Shop/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"net/http"
 	"strings"
 	"time"
 )
 
-const (
-	ADMIN_EMAIL    string = "admin@admin"
-	ADMIN_PASSWORD string = "$2a$10$W6GQy6eZ6GjyrQzKZwjmn.wQ9WT4QZ7lpq3Kv6/G1Fdq16VHPcITm"
-)
-
 // AuthRequest представляет тело запроса для авторизации.
 //
 // @Description Структура для входа пользователя
@@ -32,15 +29,15 @@ type AuthRequest struct {
 // AuthHandler обрабатывает аутентификацию пользователя.
 //
 // @Summary Авторизация пользователя
-// @Description Авторизует пользователя по email и паролю, создавая учетную запись автоматически, если пользователя нет в базе.
+// @Description Авторизует пользователя по email и паролю. Для неизвестного email возвращает 401; учетная запись создается автоматически, только если включен AUTH_AUTO_PROVISION.
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param request body AuthRequest true "Тело запроса"
-// @Success 200 {object} map[string]string "Возвращает JWT-токен"
-// @Success 201 {string} string "Пользователь создан автоматически"
+// @Success 200 {object} utils.TokenPair "Возвращает access-токен и refresh-токен"
+// @Success 201 {object} utils.TokenPair "Пользователь создан автоматически (только с AUTH_AUTO_PROVISION), возвращает пару токенов"
 // @Failure 400 {string} string "Некорректное тело запроса"
-// @Failure 401 {string} string "Неверный пароль"
+// @Failure 401 {string} string "Неверный пароль или пользователь не найден"
 // @Failure 408 {string} string "Запрос отменен клиентом"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /api/auth [post]
@@ -57,7 +54,11 @@ func AuthHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Email хранится в нижнем регистре без пробелов (см. RegisterHandler), ищем так же.
+	input.Email = strings.ToLower(strings.TrimSpace(input.Email))
+
 	var user models.User
+	created := false
 	cacheKey := "users:" + input.Email
 
 	cacheResult, err := config.Rdb.Get(ctx, cacheKey).Result()
@@ -68,13 +69,6 @@ func AuthHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		tx := migrations.DB.Begin()
-		defer func() {
-			if r := recover(); r != nil {
-				tx.Rollback()
-			}
-		}()
-
 		select {
 		case <-ctx.Done():
 			loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusRequestTimeout, nil, startTime, "Запрос отменен клиентом")
@@ -83,48 +77,37 @@ func AuthHandler(w http.ResponseWriter, r *http.Request) {
 		default:
 		}
 
-		if err := tx.WithContext(ctx).Where("email = ?", input.Email).First(&user).Error; err != nil {
-			if input.Email == "" || input.Password == "" {
-				loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, nil, startTime, "Email пользователя обязателен при первой авторизации")
-				http.Error(w, "Email пользователя обязательно при первой авторизации", http.StatusBadRequest)
+		err := migrations.DB.WithContext(ctx).Where("email = ?", input.Email).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			loging.LogRequest(logrus.ErrorLevel, uuid.Nil, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска пользователя")
+			http.Error(w, "Внутренняя ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			if !config.AutoProvisionUsers() {
+				loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusUnauthorized, err, startTime, "Пользователь с email "+input.Email+" не найден")
+				http.Error(w, "Неверный email или пароль. Для создания аккаунта используйте /api/register", http.StatusUnauthorized)
 				return
 			}
 
-			hashedPassword, hashErr := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.MinCost)
-			if hashErr != nil {
-				loging.LogRequest(logrus.ErrorLevel, uuid.Nil, r, http.StatusInternalServerError, hashErr, startTime, "Не удалось зашифровать пароль")
-				http.Error(w, "Не удалось зашифровать пароль", http.StatusInternalServerError)
+			if input.Email == "" || input.Password == "" {
+				loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, nil, startTime, "Email пользователя обязателен при первой авторизации")
+				http.Error(w, "Email пользователя обязательно при первой авторизации", http.StatusBadRequest)
 				return
 			}
 
-			user = models.User{
-				ID:       uuid.New(),
-				Username: utils.GenerateUsername(),
-				Email:    input.Email,
-				Password: string(hashedPassword),
-			}
-
-			// Проверка на администратора
-			if user.Email == ADMIN_EMAIL && string(hashedPassword) == ADMIN_PASSWORD {
-				user.Role = models.ADMIN_ROLE
-			}
-
-			// Создание пользователя в базе данных
-			if err := tx.WithContext(ctx).Create(&user).Error; err != nil {
-				loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось создать пользователя")
+			createErr := migrations.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				var err error
+				user, _, err = createEmployee(tx, input.Email, input.Password)
+				return err
+			})
+			if createErr != nil {
+				loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, createErr, startTime, "Не удалось создать пользователя")
 				http.Error(w, "Не удалось создать пользователя", http.StatusInternalServerError)
 				return
 			}
 
-			if user.Role != models.ADMIN_ROLE {
-				if err := tx.WithContext(ctx).Create(&models.Wallet{UserID: user.ID, Coin: 1000}).Error; err != nil {
-					loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось создать кошелек пользователя с ником "+user.Username)
-					http.Error(w, "Не удалось создать кошелек пользователя с ником "+user.Username, http.StatusInternalServerError)
-					return
-				}
-			}
-
-			tx.Commit()
+			created = true
 			loging.LogRequest(logrus.InfoLevel, user.ID, r, http.StatusCreated, nil, startTime, "Пользователь создан автоматически")
 
 			userJson, _ := json.Marshal(user)
@@ -138,25 +121,30 @@ func AuthHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Email)
+	tokens, err := utils.IssueTokenPair(migrations.DB.WithContext(ctx), user)
 	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось создать JWT")
 		http.Error(w, "Не удалось создать JWT", http.StatusInternalServerError)
 		return
 	}
 
-	utils.JSONFormat(w, r, map[string]string{"token": token})
-	loging.LogRequest(logrus.InfoLevel, user.ID, r, http.StatusOK, nil, startTime, "Пользователь успешно аутентифицирован")
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.JSONFormatWithStatus(w, r, status, tokens)
+	loging.LogRequest(logrus.InfoLevel, user.ID, r, status, nil, startTime, "Пользователь успешно аутентифицирован")
 }
 
 // LogoutHandler выполняет выход пользователя из системы.
 //
 // @Summary Выход из системы
-// @Description Отзывает текущий токен авторизации и добавляет его в список отозванных токенов.
+// @Description Отзывает текущий токен авторизации и добавляет его в список отозванных токенов. Если передан refresh-токен, отзывается вся его сессия.
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param Authorization header string true "Bearer {token}"
+// @Param request body RefreshRequest false "Refresh-токен текущей сессии"
 // @Success 200 {object} map[string]string "Выход выполнен успешно"
 // @Failure 401 {string} string "Отсутствует токен авторизации / Некорректный формат токена"
 // @Failure 500 {string} string "Не удалось отозвать токен"
@@ -186,7 +174,68 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var input RefreshRequest
+	if r.Body != nil && json.NewDecoder(r.Body).Decode(&input) == nil && input.RefreshToken != "" {
+		if err := utils.RevokeRefreshToken(migrations.DB, input.RefreshToken); err != nil && !errors.Is(err, utils.ErrRefreshTokenInvalid) {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Не удалось отозвать refresh-токен")
+			http.Error(w, "Не удалось отозвать refresh-токен", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	utils.JSONFormat(w, r, map[string]string{"message": "Выход выполнен успешно"})
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Пользователь успешно вышел из системы")
 }
+
+// RefreshRequest тело запроса с refresh-токеном.
+//
+// @Description Refresh-токен, полученный при входе или предыдущем обновлении
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" example:"m2Qx...Zk"`
+}
+
+// RefreshHandler обменивает refresh-токен на новую пару токенов.
+//
+// @Summary Обновление токенов
+// @Description Выдает новый access-токен и новый refresh-токен. Refresh-токен одноразовый: повторное использование отзывает всю сессию.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body RefreshRequest true "Тело запроса"
+// @Success 200 {object} utils.TokenPair "Новая пара токенов"
+// @Failure 400 {string} string "Некорректное тело запроса"
+// @Failure 401 {string} string "Refresh-токен недействителен, истек или использован повторно"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /api/auth/refresh [post]
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var input RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.RefreshToken == "" {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := utils.RotateRefreshToken(migrations.DB.WithContext(ctx), input.RefreshToken)
+	switch {
+	case errors.Is(err, utils.ErrRefreshTokenReused):
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusUnauthorized, err, startTime, "Повторное использование refresh-токена, сессия отозвана")
+		http.Error(w, "Refresh-токен уже использован, сессия отозвана. Войдите заново", http.StatusUnauthorized)
+		return
+	case errors.Is(err, utils.ErrRefreshTokenInvalid), errors.Is(err, utils.ErrRefreshTokenExpired):
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusUnauthorized, err, startTime, "Refresh-токен недействителен")
+		http.Error(w, "Refresh-токен недействителен. Войдите заново", http.StatusUnauthorized)
+		return
+	case err != nil:
+		loging.LogRequest(logrus.ErrorLevel, uuid.Nil, r, http.StatusInternalServerError, err, startTime, "Не удалось обновить токены")
+		http.Error(w, "Не удалось обновить токены", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, tokens)
+	loging.LogRequest(logrus.InfoLevel, uuid.Nil, r, http.StatusOK, nil, startTime, "Токены обновлены")
+}
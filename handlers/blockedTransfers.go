@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/transfers"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReviewBlockedTransferRequest комментарий админа к отклоненному переводу.
+type ReviewBlockedTransferRequest struct {
+	Note string `json:"note" example:"Оплата общего подарка, согласовано с руководителем"`
+}
+
+// blockedTransferErrorStatus HTTP-статус и сообщение для ошибки разбора отклоненного перевода.
+func blockedTransferErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, transfers.ErrBlockedNotFound):
+		return http.StatusNotFound, "Отклоненный перевод не найден"
+	case errors.Is(err, transfers.ErrAlreadyReviewed):
+		return http.StatusConflict, "Отклоненный перевод уже разобран"
+	case errors.Is(err, transfers.ErrNoteRequired):
+		return http.StatusBadRequest, "Нужно указать комментарий до 255 символов"
+	}
+	return http.StatusInternalServerError, "Ошибка разбора отклоненного перевода"
+}
+
+// BlockedTransfersHandler список отклоненных переводов
+//
+// @Summary Переводы, отклоненные правилами
+// @Description Возвращает переводы, которые нарушили правила переводов, от новых к старым, с нарушенными правилами. По умолчанию — только неразобранные.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Статус: open или reviewed (по умолчанию open)"
+// @Param userId query string false "ID отправителя"
+// @Param limit query int false "Количество записей, от 1 до 200 (по умолчанию 50)"
+// @Success 200 {array} models.BlockedTransfer "Отклоненные переводы"
+// @Failure 400 {object} string "Некорректные параметры"
+// @Failure 500 {object} string "Ошибка получения отклоненных переводов"
+// @Router /api/admin/blocked-transfers [get]
+// @Security BearerAuth
+func BlockedTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.BLOCKED_TRANSFER_OPEN
+	case models.BLOCKED_TRANSFER_OPEN, models.BLOCKED_TRANSFER_REVIEWED:
+	default:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректный статус отклоненного перевода: "+status)
+		http.Error(w, "status должен быть open или reviewed", http.StatusBadRequest)
+		return
+	}
+
+	db := migrations.DB.WithContext(ctx).Where("status = ?", status)
+	if raw := r.URL.Query().Get("userId"); raw != "" {
+		senderID, err := uuid.Parse(raw)
+		if err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный userId")
+			http.Error(w, "Некорректный userId", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("sender_id = ?", senderID)
+	}
+
+	blocked := []models.BlockedTransfer{}
+	if err := db.Order("created_at DESC, id DESC").Limit(limit).Find(&blocked).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения отклоненных переводов")
+		http.Error(w, "Ошибка получения отклоненных переводов", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, blocked)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получены отклоненные переводы: "+strconv.Itoa(len(blocked)))
+}
+
+// ReviewBlockedTransferHandler разбор отклоненного перевода
+//
+// @Summary Разбор отклоненного перевода
+// @Description Отмечает отклоненный перевод разобранным с комментарием админа и пишет запись аудита. Перевод при этом не выполняется: если он правомерен, сотрудник повторяет его после снятия ограничения.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID отклоненного перевода"
+// @Param request body ReviewBlockedTransferRequest true "Комментарий"
+// @Success 200 {object} models.BlockedTransfer "Перевод разобран"
+// @Failure 400 {object} string "Некорректный запрос или не указан комментарий"
+// @Failure 404 {object} string "Отклоненный перевод не найден"
+// @Failure 409 {object} string "Перевод уже разобран"
+// @Failure 500 {object} string "Ошибка разбора отклоненного перевода"
+// @Router /api/admin/blocked-transfers/{id}/review [post]
+// @Security BearerAuth
+func ReviewBlockedTransferHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	blockedID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID отклоненного перевода")
+		http.Error(w, "Некорректный ID отклоненного перевода", http.StatusBadRequest)
+		return
+	}
+
+	var req ReviewBlockedTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный запрос")
+		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	blocked, err := transfers.Review(tx, userID, blockedID, strings.TrimSpace(req.Note))
+	if err != nil {
+		status, message := blockedTransferErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка разбора отклоненного перевода "+blockedID.String())
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormat(w, r, blocked)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Разобран отклоненный перевод "+blocked.ID.String())
+}
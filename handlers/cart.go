@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"Shop/config"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+// cartCacheTTL короткий: цены в закэшированной корзине могут отставать, при оформлении берутся актуальные.
+const cartCacheTTL = time.Minute
+
+// CartLine строка корзины с текущей ценой.
+type CartLine struct {
+	Item      string `json:"item"`
+	Merch     string `json:"merch"`
+	Quantity  uint   `json:"quantity"`
+	Price     uint   `json:"price"`
+	LineTotal uint   `json:"lineTotal"`
+}
+
+// CartView корзина сотрудника.
+type CartView struct {
+	Items []CartLine `json:"items"`
+	Total uint       `json:"total"`
+}
+
+// CartItemRequest тело запроса на добавление товара в корзину.
+type CartItemRequest struct {
+	Item     string `json:"item" example:"TS-L-BLACK"`
+	Quantity uint   `json:"quantity" example:"1"`
+}
+
+// CartQuantityRequest тело запроса на изменение количества.
+type CartQuantityRequest struct {
+	Quantity uint `json:"quantity" example:"2"`
+}
+
+var errCartQuantity = errors.New("cart quantity out of range")
+
+func cartCacheKey(userID uuid.UUID) string {
+	return fmt.Sprintf("cart:%s", userID)
+}
+
+func cartQuery(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Table("cart_items").
+		Select("cart_items.item, merches.name AS merch, cart_items.quantity, COALESCE(merch_variants.price, merches.price) AS price").
+		Joins("JOIN merches ON merches.id = cart_items.merch_id").
+		Joins("LEFT JOIN merch_variants ON merch_variants.id = cart_items.variant_id").
+		Where("cart_items.user_id = ?", userID).
+		Order("cart_items.item")
+}
+
+func newCartView(lines []CartLine) CartView {
+	view := CartView{Items: []CartLine{}}
+	for _, line := range lines {
+		line.LineTotal = line.Price * line.Quantity
+		view.Total += line.LineTotal
+		view.Items = append(view.Items, line)
+	}
+	return view
+}
+
+func invalidateCartCache(ctx context.Context, userID uuid.UUID) {
+	invalidateCache(ctx, cartCacheKey(userID))
+}
+
+// updateCart меняет количество товара в корзине: при add прибавляет quantity, иначе заменяет.
+// Нулевое итоговое количество удаляет строку.
+func updateCart(tx *gorm.DB, userID uuid.UUID, name string, quantity uint, add bool) error {
+	if quantity == 0 && !add {
+		// Удаление не проверяет каталог: товар могли убрать, а строка в корзине осталась.
+		return tx.Where("user_id = ? AND item = ?", userID, name).Delete(&models.CartItem{}).Error
+	}
+
+	item, err := inventory.Find(tx, name)
+	if err != nil {
+		return err
+	}
+
+	var line models.CartItem
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND item = ?", userID, item.Name()).
+		First(&line).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	total := quantity
+	if add && exists {
+		total += line.Quantity
+	}
+	if total > models.MaxCartQuantity {
+		return errCartQuantity
+	}
+	if total == 0 {
+		if exists {
+			return tx.Delete(&line).Error
+		}
+		return nil
+	}
+	if stock := item.Stock(); stock != nil && *stock < total {
+		return inventory.ErrSoldOut
+	}
+
+	if exists {
+		return tx.Model(&line).Update("quantity", total).Error
+	}
+	return tx.Create(&models.CartItem{
+		UserID:    userID,
+		Item:      item.Name(),
+		MerchID:   item.Merch.ID,
+		VariantID: item.VariantID(),
+		Quantity:  total,
+	}).Error
+}
+
+func respondCartUpdate(w http.ResponseWriter, r *http.Request, name string, quantity uint, add bool) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err := migrations.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return updateCart(tx, userID, name, quantity, add)
+	})
+	switch {
+	case errors.Is(err, inventory.ErrItemNotFound):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Товар "+name+" не найден")
+		http.Error(w, "Товар не найден", http.StatusNotFound)
+		return
+	case errors.Is(err, inventory.ErrVariantRequired):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "У товара "+name+" есть варианты, нужен SKU")
+		http.Error(w, "У товара есть варианты, укажите SKU варианта", http.StatusBadRequest)
+		return
+	case errors.Is(err, errCartQuantity):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Слишком большое количество товара в корзине")
+		http.Error(w, fmt.Sprintf("В корзине может быть не больше %d штук одного товара", models.MaxCartQuantity), http.StatusBadRequest)
+		return
+	case errors.Is(err, inventory.ErrSoldOut):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, err, startTime, "Недостаточно товара "+name)
+		http.Error(w, "Недостаточно товара на складе", http.StatusConflict)
+		return
+	case err != nil:
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка изменения корзины")
+		http.Error(w, "Ошибка изменения корзины", http.StatusInternalServerError)
+		return
+	}
+	invalidateCartCache(r.Context(), userID)
+
+	var lines []CartLine
+	if err := cartQuery(migrations.DB.WithContext(ctx), userID).Scan(&lines).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения корзины")
+		http.Error(w, "Ошибка получения корзины", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, newCartView(lines))
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Корзина изменена: "+name)
+}
+
+// GetCartHandler корзина сотрудника
+//
+// @Summary Просмотр корзины
+// @Description Возвращает строки корзины с текущими ценами и итоговой суммой. Корзина кэшируется в Redis на минуту.
+// @Tags Cart
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} CartView "Корзина"
+// @Failure 500 {object} string "Ошибка получения корзины"
+// @Router /api/cart [get]
+// @Security BearerAuth
+func GetCartHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var lines []CartLine
+	fromCache, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, cartCacheKey(userID),
+		cartQuery(migrations.DB.WithContext(ctx), userID), &lines, cartCacheTTL)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения корзины")
+		http.Error(w, "Ошибка получения корзины", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, newCartView(lines))
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Корзина загружена из "+GetSource(fromCache))
+}
+
+// AddToCartHandler добавление товара в корзину
+//
+// @Summary Добавление товара в корзину
+// @Description Прибавляет quantity штук товара (название мерча или SKU варианта) к корзине.
+// @Tags Cart
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param request body CartItemRequest true "Тело запроса"
+// @Success 200 {object} CartView "Корзина после изменения"
+// @Failure 400 {object} string "Некорректное тело запроса, количество или нужен SKU варианта"
+// @Failure 404 {object} string "Товар не найден"
+// @Failure 409 {object} string "Недостаточно товара на складе"
+// @Failure 500 {object} string "Ошибка изменения корзины"
+// @Router /api/cart/items [post]
+// @Security BearerAuth
+func AddToCartHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	var input CartItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Item == "" || input.Quantity == 0 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, time.Now(), "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	respondCartUpdate(w, r, input.Item, input.Quantity, true)
+}
+
+// SetCartQuantityHandler изменение количества товара в корзине
+//
+// @Summary Изменение количества товара в корзине
+// @Description Заменяет количество товара в корзине. Количество 0 удаляет строку.
+// @Tags Cart
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param item path string true "Название мерча или SKU варианта"
+// @Param request body CartQuantityRequest true "Тело запроса"
+// @Success 200 {object} CartView "Корзина после изменения"
+// @Failure 400 {object} string "Некорректное тело запроса, количество или нужен SKU варианта"
+// @Failure 404 {object} string "Товар не найден"
+// @Failure 409 {object} string "Недостаточно товара на складе"
+// @Failure 500 {object} string "Ошибка изменения корзины"
+// @Router /api/cart/items/{item} [put]
+// @Security BearerAuth
+func SetCartQuantityHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	var input CartQuantityRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, time.Now(), "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	respondCartUpdate(w, r, mux.Vars(r)["item"], input.Quantity, false)
+}
+
+// RemoveFromCartHandler удаление товара из корзины
+//
+// @Summary Удаление товара из корзины
+// @Tags Cart
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param item path string true "Название мерча или SKU варианта"
+// @Success 200 {object} CartView "Корзина после изменения"
+// @Failure 404 {object} string "Товар не найден"
+// @Failure 500 {object} string "Ошибка изменения корзины"
+// @Router /api/cart/items/{item} [delete]
+// @Security BearerAuth
+func RemoveFromCartHandler(w http.ResponseWriter, r *http.Request) {
+	respondCartUpdate(w, r, mux.Vars(r)["item"], 0, false)
+}
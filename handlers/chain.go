@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"Shop/chain"
+	"Shop/database/migrations"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// VerifyChainHandler проверка хеш-цепочек
+//
+// @Summary Проверка хеш-цепочек
+// @Description Проходит хеш-цепочки переводов, покупок и журнала аудита и пересчитывает хеши. Для каждой цепочки возвращает число записей, хеш последней записи и первый разрыв, если строки меняли или удаляли напрямую в БД.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} chain.Report "Результат проверки"
+// @Failure 500 {object} string "Ошибка проверки цепочек"
+// @Router /api/admin/audit/verify [get]
+// @Security BearerAuth
+func VerifyChainHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	report, err := chain.Verify(migrations.DB.WithContext(ctx))
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проверки хеш-цепочек")
+		http.Error(w, "Ошибка проверки цепочек", http.StatusInternalServerError)
+		return
+	}
+
+	level := logrus.InfoLevel
+	if !report.Valid {
+		level = logrus.WarnLevel
+	}
+	utils.JSONFormat(w, r, report)
+	loging.LogRequest(level, userID, r, http.StatusOK, nil, startTime, "Проверка хеш-цепочек выполнена")
+}
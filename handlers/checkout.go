@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"Shop/audit"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/orders"
+	"Shop/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// CheckoutHandler оформление заказа из корзины
+//
+// @Summary Оформление заказа
+// @Description Покупает все строки корзины одной транзакцией: либо списываются остатки и монеты за все товары и создается заказ, либо ничего не меняется. Корзина после оформления очищается.
+// @Tags Cart
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
+// @Success 201 {object} models.Order "Заказ оформлен"
+// @Failure 400 {object} string "Корзина пуста, недостаточно монет или у товара появились варианты"
+// @Failure 404 {object} string "Товар из корзины больше не продается"
+// @Failure 409 {object} string "Товар закончился или запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} string "Ключ идемпотентности уже использован с другим запросом"
+// @Failure 500 {object} string "Ошибка оформления заказа"
+// @Router /api/checkout [post]
+// @Security BearerAuth
+func CheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	// Строки берутся в порядке названий, чтобы параллельные оформления блокировали товары
+	// в одном порядке и не ловили взаимную блокировку.
+	var lines []models.CartItem
+	if err := tx.Where("user_id = ?", userID).Order("item").Find(&lines).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения корзины")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+	if len(lines) == 0 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Корзина пуста")
+		http.Error(w, "Корзина пуста", http.StatusBadRequest)
+		return
+	}
+
+	pickupCode, err := orders.NewPickupCode()
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка генерации кода выдачи")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+	order := models.Order{UserID: userID, Status: models.ORDER_STATUS_PLACED, PickupCode: pickupCode}
+	stockChanged := false
+	var lowStock []inventory.Item
+	for _, line := range lines {
+		item, err := inventory.Lookup(tx, line.Item)
+		switch {
+		case errors.Is(err, inventory.ErrItemNotFound):
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Товар из корзины не найден: "+line.Item)
+			http.Error(w, "Товар "+line.Item+" больше не продается", http.StatusNotFound)
+			return
+		case errors.Is(err, inventory.ErrVariantRequired):
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "У товара из корзины появились варианты: "+line.Item)
+			http.Error(w, "У товара "+line.Item+" появились варианты, выберите SKU", http.StatusBadRequest)
+			return
+		case err != nil:
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска товара")
+			http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+			return
+		}
+
+		if err := inventory.Take(tx, &item, line.Quantity); err != nil {
+			if errors.Is(err, inventory.ErrSoldOut) {
+				loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, err, startTime, "Недостаточно товара "+line.Item)
+				http.Error(w, "Недостаточно товара "+line.Item+" на складе", http.StatusConflict)
+				return
+			}
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка списания остатка товара")
+			http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+			return
+		}
+		if item.Stock() != nil {
+			stockChanged = true
+			if item.LowStock() {
+				lowStock = append(lowStock, item)
+			}
+		}
+
+		price := item.Price()
+		order.Total += price * line.Quantity
+		order.Items = append(order.Items, models.OrderItem{
+			MerchID:   item.Merch.ID,
+			VariantID: item.VariantID(),
+			Item:      item.Name(),
+			Quantity:  line.Quantity,
+			Price:     price,
+		})
+	}
+
+	if err := tx.Create(&order).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка создания заказа")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+
+	account, err := ledger.UserAccount(tx, userID)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета покупателя")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+	revenue, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_REVENUE)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета выручки магазина")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+	if _, err := ledger.Transfer(tx, account, revenue, order.Total, models.ENTRY_ORDER, order.ID.String()); err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Недостаточно средств для оформления заказа")
+			http.Error(w, fmt.Sprintf("Недостаточно монет: заказ стоит %d", order.Total), http.StatusBadRequest)
+			return
+		}
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проводки заказа")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.CartItem{}).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка очистки корзины")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_ORDER_CREATE,
+		TargetType: "order",
+		TargetID:   order.ID.String(),
+		After:      map[string]interface{}{"status": order.Status, "total": order.Total, "lines": len(order.Items)},
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка оформления заказа", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	invalidateCache(r.Context(), cartCacheKey(userID), fmt.Sprintf("wallet:%s", userID), fmt.Sprintf("inventory:%s", userID))
+	if stockChanged {
+		invalidateMerchCache(r.Context())
+	}
+	for _, item := range lowStock {
+		loging.Log.Warnf("Заканчивается товар %s: осталось %d", item.Name(), *item.Stock())
+	}
+
+	utils.JSONFormatWithStatus(w, r, http.StatusCreated, order)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusCreated, nil, startTime, "Оформлен заказ "+order.ID.String())
+}
@@ -1,20 +1,28 @@
 package handlers
 
 import (
+	"Shop/audit"
 	"Shop/config"
 	"Shop/database/migrations"
 	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/ledger"
 	"Shop/loging"
+	"Shop/orders"
+	"Shop/transfers"
 	"Shop/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"net/http"
 	"time"
+	"unicode/utf8"
 )
 
 type Employee struct {
@@ -79,28 +87,75 @@ func ShowEmployeesHandler(w http.ResponseWriter, r *http.Request) {
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Список сотрудников показан успешно с помощью "+data)
 }
 
+// ExpiringCoins монеты, которые сгорят в expiresAt, если их не потратить.
+type ExpiringCoins struct {
+	Amount    uint      `json:"amount" example:"300"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
 type InfoMain struct {
-	Coins     uint `json:"coins"`
+	Coins uint `json:"coins"`
+	// Expiring монеты, которые сгорят в ближайшие EXPIRY_NOTICE_DAYS дней, по датам сгорания.
+	Expiring  []ExpiringCoins `json:"expiring"`
 	Inventory []struct {
 		Type     string `json:"type"`
+		SKU      string `json:"sku,omitempty"`
 		Quantity int    `json:"quantity"`
 	} `json:"inventory"`
 	CoinHistory struct {
 		Received []struct {
-			FromUser string `json:"fromUser"`
-			Amount   uint   `json:"amount"`
+			FromUser  string `json:"fromUser"`
+			Amount    uint   `json:"amount"`
+			Memo      string `json:"memo,omitempty"`
+			Category  string `json:"category,omitempty"`
+			Kind      string `json:"kind" example:"transfer"`
+			GrantedBy string `json:"grantedBy,omitempty"`
+			Reason    string `json:"reason,omitempty"`
 		} `json:"received"`
 		Sent []struct {
-			ToUser string `json:"toUser"`
-			Amount uint   `json:"amount"`
+			ToUser    string `json:"toUser"`
+			Amount    uint   `json:"amount"`
+			Memo      string `json:"memo,omitempty"`
+			Category  string `json:"category,omitempty"`
+			Kind      string `json:"kind" example:"transfer"`
+			GrantedBy string `json:"grantedBy,omitempty"`
+			Reason    string `json:"reason,omitempty"`
 		} `json:"sent"`
+		Adjustments []Adjustment `json:"adjustments"`
 	} `json:"coinHistory"`
 }
 
+// ownedItems все купленные сотрудником товары: строки неотмененных заказов и невозвращенные
+// покупки, сделанные до появления заказов.
+func ownedItems(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Raw(`SELECT merch_id, variant_id, quantity FROM purchases WHERE user_id = ? AND order_id IS NULL
+			AND NOT EXISTS (SELECT 1 FROM reversals WHERE reversals.kind = ? AND reversals.target_id = purchases.id)
+		UNION ALL
+		SELECT order_items.merch_id, order_items.variant_id, order_items.quantity
+		FROM order_items JOIN orders ON orders.id = order_items.order_id
+		WHERE orders.user_id = ? AND orders.status <> ?`, userID, models.REVERSAL_PURCHASE, userID, models.ORDER_STATUS_CANCELLED)
+}
+
+// Adjustment возврат покупки или отмена начисления в истории сотрудника. Amount — изменение баланса.
+type Adjustment struct {
+	Kind      string    `json:"kind" example:"purchase"`
+	Amount    int64     `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func adjustmentsCacheKey(userID uuid.UUID) string {
+	return fmt.Sprintf("adjustments:%s", userID)
+}
+
+func expiringCacheKey(userID uuid.UUID) string {
+	return fmt.Sprintf("expiring:%s", userID)
+}
+
 // InformationHandler информация о пользователе
 //
 // @Summary Получение информации о кошельке, инвентаре и транзакциях пользователя
-// @Description Возвращает информацию о кошельке, инвентаре и истории транзакций для конкретного пользователя.
+// @Description Возвращает информацию о кошельке, инвентаре и истории транзакций для конкретного пользователя. Начисления и списания админов показываются как переводы от казны или казне (treasury) с видом grant или debit, никнеймом админа в grantedBy и причиной, сгоревшие монеты — как переводы казне с видом expiry. В expiring — монеты, которые сгорят в ближайшие дни, если их не потратить.
 // @Tags Employee
 // @Accept  json
 // @Produce  json
@@ -138,14 +193,15 @@ func InformationHandler(w http.ResponseWriter, r *http.Request) {
 
 	var inventory []struct {
 		Type     string `json:"type"`
+		SKU      string `json:"sku,omitempty"`
 		Quantity int    `json:"quantity"`
 	}
 	fromCacheInventory, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, inventoryCacheKey,
-		migrations.DB.Table("purchases").
-			Select("merches.name as type, COUNT(purchases.id) as quantity").
-			Joins("JOIN merches ON purchases.merch_id = merches.id").
-			Where("purchases.user_id = ?", userID).
-			Group("merches.id, merches.name"), &inventory, cacheTTL)
+		migrations.DB.Table("(?) AS owned", ownedItems(migrations.DB, userID)).
+			Select("merches.name as type, COALESCE(merch_variants.sku, '') as sku, SUM(owned.quantity) as quantity").
+			Joins("JOIN merches ON owned.merch_id = merches.id").
+			Joins("LEFT JOIN merch_variants ON owned.variant_id = merch_variants.id").
+			Group("merches.id, merches.name, merch_variants.id, merch_variants.sku"), &inventory, cacheTTL)
 	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при получении инвентаря")
 		http.Error(w, "Failed to retrieve inventory", http.StatusInternalServerError)
@@ -154,13 +210,20 @@ func InformationHandler(w http.ResponseWriter, r *http.Request) {
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Инвентарь загружен из "+GetSource(fromCacheInventory))
 
 	var received []struct {
-		FromUser string `json:"fromUser"`
-		Amount   uint   `json:"amount"`
+		FromUser  string `json:"fromUser"`
+		Amount    uint   `json:"amount"`
+		Memo      string `json:"memo,omitempty"`
+		Category  string `json:"category,omitempty"`
+		Kind      string `json:"kind" example:"transfer"`
+		GrantedBy string `json:"grantedBy,omitempty"`
+		Reason    string `json:"reason,omitempty"`
 	}
 	fromCacheReceived, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, receivedCacheKey,
 		migrations.DB.Table("transactions").
-			Select("users.username as from_user, transactions.amount").
+			Select("users.username as from_user, transactions.amount, transactions.memo, transactions.category, "+
+				"transactions.kind, COALESCE(admins.username, '') as granted_by, transactions.reason").
 			Joins("JOIN users ON transactions.from_user = users.id").
+			Joins("LEFT JOIN users AS admins ON transactions.admin_id = admins.id").
 			Where("transactions.to_user = ?", userID), &received, cacheTTL)
 	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при получении полученных транзакций")
@@ -170,13 +233,20 @@ func InformationHandler(w http.ResponseWriter, r *http.Request) {
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Отправленные транзакции загружены из "+GetSource(fromCacheReceived))
 
 	var sent []struct {
-		ToUser string `json:"toUser"`
-		Amount uint   `json:"amount"`
+		ToUser    string `json:"toUser"`
+		Amount    uint   `json:"amount"`
+		Memo      string `json:"memo,omitempty"`
+		Category  string `json:"category,omitempty"`
+		Kind      string `json:"kind" example:"transfer"`
+		GrantedBy string `json:"grantedBy,omitempty"`
+		Reason    string `json:"reason,omitempty"`
 	}
 	fromCacheSent, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, sentCacheKey,
 		migrations.DB.Table("transactions").
-			Select("users.username as to_user, transactions.amount").
+			Select("users.username as to_user, transactions.amount, transactions.memo, transactions.category, "+
+				"transactions.kind, COALESCE(admins.username, '') as granted_by, transactions.reason").
 			Joins("JOIN users ON transactions.to_user = users.id").
+			Joins("LEFT JOIN users AS admins ON transactions.admin_id = admins.id").
 			Where("transactions.from_user = ?", userID), &sent, cacheTTL)
 	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при получении отправленных транзакций")
@@ -186,23 +256,38 @@ func InformationHandler(w http.ResponseWriter, r *http.Request) {
 
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Отправленные транзакции загружены из "+GetSource(fromCacheSent))
 
-	response := InfoMain{
-		Coins:     wallet.Coin,
-		Inventory: inventory,
-		CoinHistory: struct {
-			Received []struct {
-				FromUser string `json:"fromUser"`
-				Amount   uint   `json:"amount"`
-			} `json:"received"`
-			Sent []struct {
-				ToUser string `json:"toUser"`
-				Amount uint   `json:"amount"`
-			} `json:"sent"`
-		}{
-			Received: received,
-			Sent:     sent,
-		},
+	var adjustments []Adjustment
+	fromCacheAdjustments, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, adjustmentsCacheKey(userID),
+		migrations.DB.Model(&models.Reversal{}).
+			Select("kind, amount, reason, created_at").
+			Where("user_id = ?", userID).
+			Order("created_at"), &adjustments, cacheTTL)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при получении возвратов и отмен начислений")
+		http.Error(w, "Failed to retrieve adjustments", http.StatusInternalServerError)
+		return
+	}
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Возвраты и отмены начислений загружены из "+GetSource(fromCacheAdjustments))
+
+	expiring := []ExpiringCoins{}
+	noticeUntil := time.Now().AddDate(0, 0, int(config.ExpiryNoticeDays()))
+	fromCacheExpiring, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, expiringCacheKey(userID),
+		migrations.DB.Model(&models.CoinLot{}).
+			Select("SUM(remaining) AS amount, expires_at").
+			Where("user_id = ? AND remaining > 0 AND expires_at <= ?", userID, noticeUntil).
+			Group("expires_at").
+			Order("expires_at"), &expiring, cacheTTL)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при получении сгорающих монет")
+		http.Error(w, "Failed to retrieve expiring coins", http.StatusInternalServerError)
+		return
 	}
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Сгорающие монеты загружены из "+GetSource(fromCacheExpiring))
+
+	response := InfoMain{Coins: wallet.Coin, Expiring: expiring, Inventory: inventory}
+	response.CoinHistory.Received = received
+	response.CoinHistory.Sent = sent
+	response.CoinHistory.Adjustments = adjustments
 
 	utils.JSONFormat(w, r, response)
 	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Информация показана успешно")
@@ -218,20 +303,41 @@ func GetSource(fromCache bool) string {
 type TransactionsResponse struct {
 	NickTaker string `json:"toUser"`
 	Coin      uint   `json:"coin"`
+	Memo      string `json:"memo,omitempty" example:"Спасибо за помощь с релизом"`
+	Category  string `json:"category,omitempty" example:"thanks"`
+}
+
+// TransferRejected ответ на перевод, нарушающий правила переводов. Перевод сохранен для разбора админом.
+type TransferRejected struct {
+	Error      string                     `json:"error" example:"Перевод нарушает правила переводов"`
+	BlockedID  uuid.UUID                  `json:"blockedId"`
+	Violations []models.TransferViolation `json:"violations"`
+}
+
+func validTransferCategory(category string) bool {
+	for _, candidate := range models.TransferCategories {
+		if category == candidate {
+			return true
+		}
+	}
+	return false
 }
 
 // SendCoinHandler Отправка монет
 // @Summary Отправка монет от одного пользователя другому
-// @Description Позволяет пользователю отправить монеты другому пользователю, указав его имя и количество монет для отправки.
+// @Description Позволяет пользователю отправить монеты другому пользователю, указав его имя и количество монет для отправки. Необязательно можно добавить сообщение (до 200 символов) и категорию: thanks, bet или gift. Перевод проверяется правилами переводов (TRANSFER_* и NEW_ACCOUNT_TRANSFER_COOLDOWN): при нарушении монеты не списываются, возвращается 422 со списком нарушенных правил, а перевод сохраняется для разбора админом.
 // @Tags Employee
 // @Accept  json
 // @Produce  json
 // @Param Authorization header string true "Bearer {token}"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
 // @Param request body TransactionsResponse true "Тело запроса"
 // @Success 200 {object} models.Transaction "Транзакция успешно создана"
-// @Failure 400 {object} string "Неверный запрос - некорректный ввод, недостаточно монет или попытка отправки себе"
+// @Failure 400 {object} string "Неверный запрос - некорректный ввод, слишком длинное сообщение, неизвестная категория, недостаточно монет, превышен максимальный баланс получателя или попытка отправки себе"
 // @Failure 404 {object} string "Не найдено - пользователь или кошелек не найдены"
 // @Failure 500 {object} string "Внутренняя ошибка сервера - проблемы с транзакцией в базе данных"
+// @Failure 409 {object} string "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} TransferRejected "Перевод нарушает правила переводов (лимиты, частота, новый аккаунт) или ключ идемпотентности уже использован с другим запросом (текстом)"
 // @Router /api/sendCoin [post]
 // @Security BearerAuth
 func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
@@ -255,7 +361,19 @@ func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx := migrations.DB.Begin()
+	input.Memo = utils.SanitizeMemo(input.Memo)
+	if utf8.RuneCountInString(input.Memo) > models.MaxMemoLength {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Слишком длинное сообщение к переводу")
+		http.Error(w, fmt.Sprintf("Сообщение к переводу должно быть не длиннее %d символов", models.MaxMemoLength), http.StatusBadRequest)
+		return
+	}
+	if input.Category != "" && !validTransferCategory(input.Category) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Неизвестная категория перевода: "+input.Category)
+		http.Error(w, "Неизвестная категория перевода", http.StatusBadRequest)
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
 	committed := false
 	defer func() {
 		if !committed {
@@ -294,23 +412,32 @@ func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userTaker.ID).First(&walletTaker).Error; err != nil {
-		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Кошелек получателя не найден")
-		http.Error(w, "Кошелек получателя не найден.", http.StatusNotFound)
+	request := transfers.Request{Sender: userSender, RecipientID: userTaker.ID, Amount: input.Coin, Now: time.Now()}
+	violations, err := transfers.Check(tx.WithContext(ctx), request)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проверки правил переводов")
+		http.Error(w, "Ошибка проверки правил переводов.", http.StatusInternalServerError)
 		return
 	}
-
-	walletSender.Coin -= input.Coin
-	walletTaker.Coin += input.Coin
-
-	if err := tx.WithContext(ctx).Save(&walletSender).Error; err != nil {
-		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка обновления баланса отправителя")
-		http.Error(w, "Ошибка обновления баланса отправителя.", http.StatusInternalServerError)
+	if len(violations) > 0 {
+		blocked, err := transfers.Block(migrations.DB.WithContext(ctx), request, violations)
+		if err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сохранения отклоненного перевода")
+			http.Error(w, "Ошибка проверки правил переводов.", http.StatusInternalServerError)
+			return
+		}
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusUnprocessableEntity, nil, startTime, "Перевод отклонен правилами переводов: "+blocked.ID.String())
+		utils.JSONFormatWithStatus(w, r, http.StatusUnprocessableEntity, TransferRejected{
+			Error:      "Перевод нарушает правила переводов",
+			BlockedID:  blocked.ID,
+			Violations: violations,
+		})
 		return
 	}
-	if err := tx.WithContext(ctx).Save(&walletTaker).Error; err != nil {
-		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка обновления баланса получателя")
-		http.Error(w, "Ошибка обновления баланса получателя.", http.StatusInternalServerError)
+
+	if err := tx.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userTaker.ID).First(&walletTaker).Error; err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Кошелек получателя не найден")
+		http.Error(w, "Кошелек получателя не найден.", http.StatusNotFound)
 		return
 	}
 
@@ -318,6 +445,9 @@ func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		FromUser: userSender.ID,
 		ToUser:   userTaker.ID,
 		Amount:   input.Coin,
+		Memo:     input.Memo,
+		Category: input.Category,
+		Kind:     models.TRANSACTION_KIND_TRANSFER,
 	}
 	if err := tx.WithContext(ctx).Create(&transaction).Error; err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка создания транзакции")
@@ -325,6 +455,49 @@ func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	accountSender, err := ledger.UserAccount(tx.WithContext(ctx), userSender.ID)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета отправителя")
+		http.Error(w, "Ошибка обновления баланса отправителя.", http.StatusInternalServerError)
+		return
+	}
+	accountTaker, err := ledger.UserAccount(tx.WithContext(ctx), userTaker.ID)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета получателя")
+		http.Error(w, "Ошибка обновления баланса получателя.", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := ledger.Transfer(tx.WithContext(ctx), accountSender, accountTaker, input.Coin, models.ENTRY_TRANSFER, transaction.ID.String()); err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Недостаточно монет на балансе")
+			http.Error(w, "Недостаточно монет на балансе.", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ledger.ErrBalanceLimit) {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Баланс получателя превысил бы максимальный")
+			http.Error(w, fmt.Sprintf("На кошельке получателя не может быть больше %d монет.", config.MaxWalletBalance()), http.StatusBadRequest)
+			return
+		}
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проводки перевода")
+		http.Error(w, "Ошибка обновления баланса.", http.StatusInternalServerError)
+		return
+	}
+
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_COINS_TRANSFER,
+		TargetType: "transaction",
+		TargetID:   transaction.ID.String(),
+		Before:     map[string]interface{}{"sender": walletSender.Coin, "recipient": walletTaker.Coin},
+		After:      map[string]interface{}{"sender": walletSender.Coin - input.Coin, "recipient": walletTaker.Coin + input.Coin},
+		Details:    fmt.Sprintf("перевод %d монет пользователю %s", input.Coin, userTaker.Username),
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка обновления баланса.", http.StatusInternalServerError)
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
 		http.Error(w, "Ошибка фиксации транзакции.", http.StatusInternalServerError)
@@ -336,36 +509,97 @@ func SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type InfoAfterBying struct {
-	Balance  interface{} `json:"balance"`
-	Item     interface{} `json:"item"`
-	Nickname interface{} `json:"nickname"`
+	Balance    interface{} `json:"balance"`
+	Item       interface{} `json:"item"`
+	Quantity   interface{} `json:"quantity"`
+	Nickname   interface{} `json:"nickname"`
+	OrderID    interface{} `json:"orderId"`
+	PurchaseID interface{} `json:"purchaseId"`
+	PickupCode interface{} `json:"pickupCode"`
+}
+
+// BuyRequest запрос на покупку: название товара или SKU варианта и количество.
+type BuyRequest struct {
+	Item     string `json:"item" example:"hoody-black-m"`
+	Quantity uint   `json:"quantity" example:"2"`
 }
 
 // BuyItemHandler Покупка товара
-// @Summary Покупка товара пользователем
-// @Description Позволяет пользователю купить товар, указав его имя. Проверяется наличие средств на кошельке и успешность покупки.
+// @Summary Покупка одной штуки товара (устарело)
+// @Description Устаревший способ покупки одной штуки через GET: покупку могут случайно вызвать предзагрузка ссылок и история браузера. Используйте POST /api/buy. Ответ содержит заголовки Deprecation и Sunset; маршрут отключается параметром BUY_GET_ENABLED=false.
 // @Tags Employee
 // @Accept  json
 // @Produce  json
 // @Param Authorization header string true "Bearer {token}"
-// @Param item path string true "Название товара" example("item_name")
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
+// @Param item path string true "Название товара или SKU варианта" example("item_name")
 // @Success 200 {object} InfoAfterBying "Информация о балансе и купленном товаре"
-// @Failure 400 {object} string "Недостаточно средств на кошельке"
+// @Failure 400 {object} string "Недостаточно средств на кошельке или у товара есть варианты и нужен SKU"
 // @Failure 404 {object} string "Покупатель или товар не найдены"
 // @Failure 500 {object} string "Ошибка сохранения в базе данных"
+// @Failure 409 {object} string "Товар закончился или запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} string "Ключ идемпотентности уже использован с другим запросом"
 // @Router /api/buy/{item} [get]
 // @Security BearerAuth
+// @Deprecated
 func BuyItemHandler(w http.ResponseWriter, r *http.Request) {
+	buyItem(w, r, time.Now(), mux.Vars(r)["item"], 1)
+}
+
+// PurchaseHandler Покупка товара
+// @Summary Покупка товара пользователем
+// @Description Покупает quantity штук товара по названию или SKU варианта. Остаток, стоимость всех штук и баланс проверяются в одной транзакции.
+// @Tags Employee
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
+// @Param request body BuyRequest true "Товар и количество"
+// @Success 200 {object} InfoAfterBying "Информация о балансе и купленном товаре"
+// @Failure 400 {object} string "Некорректный запрос, недостаточно средств на кошельке или у товара есть варианты и нужен SKU"
+// @Failure 404 {object} string "Покупатель или товар не найдены"
+// @Failure 500 {object} string "Ошибка сохранения в базе данных"
+// @Failure 409 {object} string "Товара недостаточно или запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} string "Ключ идемпотентности уже использован с другим запросом"
+// @Router /api/buy [post]
+// @Security BearerAuth
+func PurchaseHandler(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	var req BuyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if req.Item == "" {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Не указан товар")
+		http.Error(w, "Не указан товар", http.StatusBadRequest)
+		return
+	}
+	if req.Quantity == 0 || req.Quantity > models.MaxCartQuantity {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректное количество товара")
+		http.Error(w, fmt.Sprintf("Количество должно быть от 1 до %d", models.MaxCartQuantity), http.StatusBadRequest)
+		return
+	}
+
+	buyItem(w, r, startTime, req.Item, req.Quantity)
+}
+
+// buyItem покупает quantity штук товара itemName одной транзакцией.
+func buyItem(w http.ResponseWriter, r *http.Request, startTime time.Time, itemName string, quantity uint) {
 	userID := r.Context().Value(utils.UserIDKey).(uuid.UUID)
 
-	itemName := mux.Vars(r)["item"]
-	var merch models.Merch
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
 	var user models.User
 
-	tx := migrations.DB.Begin()
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
 	defer func() {
-		if r := recover(); r != nil {
+		if !committed {
 			tx.Rollback()
 		}
 	}()
@@ -375,29 +609,89 @@ func BuyItemHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Покупатель не найден в базе данных", http.StatusNotFound)
 		return
 	}
+
+	// Строка с остатком блокируется до конца транзакции, чтобы остаток нельзя было продать дважды.
+	item, err := inventory.Lookup(tx, itemName)
+	switch {
+	case errors.Is(err, inventory.ErrItemNotFound):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Запрошенная вещь не существует в базе данных")
+		http.Error(w, "Запрошенная вещь не существует в базе данных", http.StatusNotFound)
+		return
+	case errors.Is(err, inventory.ErrVariantRequired):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "У товара "+itemName+" есть варианты, нужен SKU")
+		http.Error(w, "У товара есть варианты, укажите SKU варианта", http.StatusBadRequest)
+		return
+	case err != nil:
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска товара")
+		http.Error(w, "Ошибка поиска товара", http.StatusInternalServerError)
+		return
+	}
+	price := item.Price() * quantity
+
+	// Кошелек блокируется после товара (порядок блокировок — см. inventory.Lookup), поэтому
+	// проверка баланса ниже видит актуальный остаток.
 	var wallet models.Wallet
-	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&wallet).Error; err != nil {
 		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Кошелька покупателя не существует в базе данных")
 		http.Error(w, "Кошелька покупателя не существует в базе данных", http.StatusNotFound)
 		return
 	}
 
-	if err := tx.Where("name = ?", itemName).First(&merch).Error; err != nil {
-		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Запрошенная вещь не существует в базе данных")
-		http.Error(w, "Запрошенная вещь не существует в базе данных", http.StatusNotFound)
+	if stock := item.Stock(); stock != nil && *stock == 0 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Товар "+itemName+" закончился")
+		http.Error(w, "Товар закончился", http.StatusConflict)
+		return
+	}
+	if stock := item.Stock(); stock != nil && *stock < quantity {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Недостаточно товара "+itemName)
+		http.Error(w, fmt.Sprintf("Недостаточно товара на складе: осталось %d", *stock), http.StatusConflict)
 		return
 	}
 
-	if wallet.Coin < merch.Price {
+	if wallet.Coin < price {
 		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Недостаточно средств на кошельке у пользователя.")
 		http.Error(w, "Недостаточно средств на кошельке у пользователя.", http.StatusBadRequest)
 		return
 	}
 
-	wallet.Coin -= merch.Price
+	if err := inventory.Take(tx, &item, quantity); err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка списания остатка товара")
+		http.Error(w, "Ошибка списания остатка товара", http.StatusInternalServerError)
+		return
+	}
+
+	// Разовая покупка оформляется заказом из одной строки, чтобы ее выдачу можно было отследить.
+	pickupCode, err := orders.NewPickupCode()
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка генерации кода выдачи")
+		http.Error(w, "Ошибка сохранения в истории заказа.", http.StatusInternalServerError)
+		return
+	}
+	order := models.Order{
+		UserID:     userID,
+		Status:     models.ORDER_STATUS_PLACED,
+		Total:      price,
+		PickupCode: pickupCode,
+		Items: []models.OrderItem{{
+			MerchID:   item.Merch.ID,
+			VariantID: item.VariantID(),
+			Item:      item.Name(),
+			Quantity:  quantity,
+			Price:     item.Price(),
+		}},
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка создания заказа")
+		http.Error(w, "Ошибка сохранения в истории заказа.", http.StatusInternalServerError)
+		return
+	}
+
 	var purchase = models.Purchase{
-		UserID:  userID,
-		MerchID: merch.ID,
+		UserID:    userID,
+		MerchID:   item.Merch.ID,
+		VariantID: item.VariantID(),
+		Quantity:  quantity,
+		OrderID:   &order.ID,
 	}
 
 	if err := tx.Save(&purchase).Error; err != nil {
@@ -405,21 +699,64 @@ func BuyItemHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Ошибка сохранения в истории заказа.", http.StatusInternalServerError)
 		return
 	}
-	if err := tx.Save(&wallet).Error; err != nil {
+
+	account, err := ledger.UserAccount(tx, userID)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета покупателя")
+		http.Error(w, "Ошибка сохранения кошелька у пользователя.", http.StatusInternalServerError)
+		return
+	}
+	revenue, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_REVENUE)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения счета выручки магазина")
+		http.Error(w, "Ошибка сохранения кошелька у пользователя.", http.StatusInternalServerError)
+		return
+	}
+	if _, err := ledger.Transfer(tx, account, revenue, price, models.ENTRY_PURCHASE, purchase.ID.String()); err != nil {
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Недостаточно средств на кошельке у пользователя.")
+			http.Error(w, "Недостаточно средств на кошельке у пользователя.", http.StatusBadRequest)
+			return
+		}
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сохранения кошелька у пользователя.")
 		http.Error(w, "Ошибка сохранения кошелька у пользователя.", http.StatusInternalServerError)
 		return
 	}
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_PURCHASE,
+		TargetType: "order",
+		TargetID:   order.ID.String(),
+		Before:     map[string]interface{}{"coin": wallet.Coin},
+		After:      map[string]interface{}{"coin": wallet.Coin - price},
+		Details:    fmt.Sprintf("покупка %s x%d за %d монет", item.Name(), quantity, price),
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка сохранения в истории заказа.", http.StatusInternalServerError)
+		return
+	}
+	wallet.Coin -= price
 
 	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
 		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
 		return
 	}
+	committed = true
+
+	if item.Stock() != nil {
+		invalidateMerchCache(r.Context())
+		if item.LowStock() {
+			loging.Log.Warnf("Заканчивается товар %s: осталось %d", item.Name(), *item.Stock())
+		}
+	}
 	utils.JSONFormat(w, r, InfoAfterBying{
-		Balance:  wallet.Coin,
-		Item:     itemName,
-		Nickname: user.Username,
+		Balance:    wallet.Coin,
+		Item:       itemName,
+		Quantity:   quantity,
+		Nickname:   user.Username,
+		OrderID:    order.ID,
+		PurchaseID: purchase.ID,
+		PickupCode: order.PickupCode,
 	})
 }
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/grants"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxBulkGrantBody предельный размер загружаемого списка начислений.
+const maxBulkGrantBody = 2 << 20
+
+// BulkGrantResult задание массового начисления и итог проверки списка, по которому оно создано.
+type BulkGrantResult struct {
+	Job    models.BulkGrantJob `json:"job"`
+	Report grants.Report       `json:"report"`
+}
+
+// BulkGrantProgress задание массового начисления с начислениями, которые еще не применены.
+type BulkGrantProgress struct {
+	Job     models.BulkGrantJob    `json:"job"`
+	Pending []models.BulkGrantItem `json:"pending"`
+}
+
+// InvalidateCoinHistory сбрасывает кэш кошелька, истории переводов и сгорающих монет сотрудников
+// после начислений и сгорания монет.
+func InvalidateCoinHistory(userIDs []uuid.UUID) {
+	keys := make([]string, 0, 4*len(userIDs))
+	for _, id := range userIDs {
+		keys = append(keys, fmt.Sprintf("wallet:%s", id), fmt.Sprintf("received:%s", id),
+			fmt.Sprintf("sent:%s", id), expiringCacheKey(id))
+	}
+	if len(keys) > 0 {
+		invalidateCache(context.Background(), keys...)
+	}
+}
+
+// bulkGrantErrorStatus HTTP-статус и сообщение для ошибки массового начисления.
+func bulkGrantErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, grants.ErrJobNotFound):
+		return http.StatusNotFound, "Задание не найдено"
+	case errors.Is(err, grants.ErrNotResumable):
+		return http.StatusConflict, "Продолжить можно только задание со статусом failed"
+	case errors.Is(err, grants.ErrTooManyRows), errors.Is(err, grants.ErrInvalidChunk), errors.Is(err, grants.ErrEmptyList):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, ledger.ErrBalanceLimit):
+		return http.StatusConflict, err.Error()
+	}
+	return http.StatusInternalServerError, "Ошибка массового начисления"
+}
+
+// parseRows читает список начислений в формате из Content-Type: text/csv или application/json.
+func parseRows(r *http.Request) ([]grants.Row, int, error) {
+	mediaType := "application/json"
+	if header := r.Header.Get("Content-Type"); header != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(header); err != nil {
+			return nil, http.StatusUnsupportedMediaType, err
+		}
+	}
+	var rows []grants.Row
+	var err error
+	switch mediaType {
+	case "text/csv":
+		rows, err = grants.ParseCSV(r.Body)
+	case "application/json":
+		rows, err = grants.ParseJSON(r.Body)
+	default:
+		return nil, http.StatusUnsupportedMediaType, errors.New("список принимается в text/csv или application/json")
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, http.StatusRequestEntityTooLarge, err
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return rows, http.StatusOK, nil
+}
+
+// BulkGrantHandler массовое начисление
+//
+// @Summary Массовое начисление монет
+// @Description Принимает список начислений в CSV (колонки username или email, amount, reason с заголовком) или JSON (массив объектов с теми же полями) и сначала проверяет весь список. С dryRun=true возвращает только отчет проверки. Если в списке есть ошибки, ничего не начисляется и возвращается 422 с отчетом. При chunkSize = 0 все начисления применяются одной транзакцией (201), иначе создается задание, которое применяется в фоне порциями по chunkSize (202); прогресс — GET /api/admin/grants/bulk/{id}. Начисления попадают в историю сотрудников как переводы от казны.
+// @Tags Admin
+// @Accept  json
+// @Accept  text/csv
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повторный запрос с тем же ключом вернет сохраненный ответ"
+// @Param dryRun query bool false "Только проверить список"
+// @Param chunkSize query int false "Размер порции фонового применения, от 1 до 1000; 0 — одной транзакцией (по умолчанию)"
+// @Param request body []grants.Row true "Список начислений"
+// @Success 200 {object} grants.Report "Отчет проверки (dryRun)"
+// @Success 201 {object} BulkGrantResult "Начисления применены"
+// @Success 202 {object} BulkGrantResult "Задание создано и применяется в фоне"
+// @Failure 400 {object} string "Некорректный список или параметры"
+// @Failure 413 {object} string "Список слишком большой"
+// @Failure 415 {object} string "Неподдерживаемый формат"
+// @Failure 422 {object} grants.Report "В списке есть ошибки, ничего не начислено"
+// @Failure 500 {object} string "Ошибка массового начисления"
+// @Router /api/admin/grants/bulk [post]
+// @Security BearerAuth
+func BulkGrantHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	dryRun := false
+	if value := query.Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный dryRun")
+			http.Error(w, "dryRun должен быть true или false", http.StatusBadRequest)
+			return
+		}
+	}
+	chunkSize := 0
+	if value := query.Get("chunkSize"); value != "" {
+		var err error
+		if chunkSize, err = strconv.Atoi(value); err != nil || chunkSize < 0 || chunkSize > grants.MaxChunkSize {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный chunkSize")
+			http.Error(w, grants.ErrInvalidChunk.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkGrantBody)
+	rows, status, err := parseRows(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Некорректный список начислений")
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	report, err := grants.Validate(migrations.DB.WithContext(ctx), rows)
+	if err != nil {
+		status, message := bulkGrantErrorStatus(err)
+		loging.LogRequest(logrus.ErrorLevel, userID, r, status, err, startTime, "Ошибка проверки списка начислений")
+		http.Error(w, message, status)
+		return
+	}
+	if dryRun {
+		utils.JSONFormat(w, r, report)
+		loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime,
+			fmt.Sprintf("Проверен список начислений: строк %d, ошибок %d", report.Rows, len(report.Errors)))
+		return
+	}
+	if !report.Valid {
+		utils.JSONFormatWithStatus(w, r, http.StatusUnprocessableEntity, report)
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusUnprocessableEntity, nil, startTime,
+			fmt.Sprintf("Список начислений не применен: ошибок %d", len(report.Errors)))
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	job, granted, err := grants.Create(tx, userID, report, chunkSize)
+	if err != nil {
+		status, message := bulkGrantErrorStatus(err)
+		loging.LogRequest(logrus.ErrorLevel, userID, r, status, err, startTime, "Ошибка массового начисления")
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	status = http.StatusCreated
+	if chunkSize > 0 {
+		status = http.StatusAccepted
+		grants.Notify()
+	}
+	InvalidateCoinHistory(granted)
+
+	utils.JSONFormatWithStatus(w, r, status, BulkGrantResult{Job: job, Report: report})
+	loging.LogRequest(logrus.InfoLevel, userID, r, status, nil, startTime,
+		fmt.Sprintf("Создано массовое начисление %s: начислений %d на %d монет", job.ID, job.Items, job.TotalAmount))
+}
+
+// BulkGrantJobHandler прогресс массового начисления
+//
+// @Summary Прогресс массового начисления
+// @Description Возвращает задание массового начисления: статус, сколько начислений применено (granted из items, grantedAmount из totalAmount) и ошибку, если порция не применилась, а также еще не примененные начисления.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID задания"
+// @Success 200 {object} BulkGrantProgress "Задание"
+// @Failure 400 {object} string "Некорректный ID задания"
+// @Failure 404 {object} string "Задание не найдено"
+// @Failure 500 {object} string "Ошибка получения задания"
+// @Router /api/admin/grants/bulk/{id} [get]
+// @Security BearerAuth
+func BulkGrantJobHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	jobID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID задания")
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	db := migrations.DB.WithContext(ctx)
+
+	progress := BulkGrantProgress{Pending: []models.BulkGrantItem{}}
+	if err := db.Where("id = ?", jobID).First(&progress.Job).Error; err != nil {
+		status, message := http.StatusInternalServerError, "Ошибка получения задания"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status, message = bulkGrantErrorStatus(grants.ErrJobNotFound)
+		}
+		loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Ошибка получения задания "+jobID.String())
+		http.Error(w, message, status)
+		return
+	}
+	if err := db.Where("job_id = ? AND status = ?", jobID, models.BULK_GRANT_ITEM_PENDING).
+		Order("line").
+		Find(&progress.Pending).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения начислений задания")
+		http.Error(w, "Ошибка получения задания", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, progress)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime,
+		fmt.Sprintf("Получено задание %s: %d из %d", jobID, progress.Job.Granted, progress.Job.Items))
+}
+
+// ResumeBulkGrantHandler продолжение массового начисления
+//
+// @Summary Продолжение массового начисления
+// @Description Возвращает в очередь задание, остановленное ошибкой (status = failed). Уже примененные начисления не повторяются, обработка продолжится с первого неначисленного.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID задания"
+// @Success 200 {object} models.BulkGrantJob "Задание возвращено в очередь"
+// @Failure 400 {object} string "Некорректный ID задания"
+// @Failure 404 {object} string "Задание не найдено"
+// @Failure 409 {object} string "Задание не остановлено ошибкой"
+// @Failure 500 {object} string "Ошибка продолжения задания"
+// @Router /api/admin/grants/bulk/{id}/resume [post]
+// @Security BearerAuth
+func ResumeBulkGrantHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	jobID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID задания")
+		http.Error(w, "Некорректный ID задания", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	job, err := grants.Resume(tx, userID, jobID)
+	if err != nil {
+		status, message := bulkGrantErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка продолжения задания "+jobID.String())
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+	grants.Notify()
+
+	utils.JSONFormat(w, r, job)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Задание "+job.ID.String()+" возвращено в очередь")
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"Shop/loging"
+	"Shop/utils"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// JWKSHandler отдает публичные ключи проверки JWT.
+//
+// @Summary Публичные ключи JWT (JWKS)
+// @Description Возвращает публичные ключи RS256/EdDSA, которыми другие сервисы могут проверять токены Shop без общего секрета. Ключ выбирается по заголовку kid токена.
+// @Tags Auth
+// @Produce  json
+// @Success 200 {object} utils.JWKS "Набор публичных ключей"
+// @Failure 500 {string} string "Ключи подписи не настроены"
+// @Router /api/.well-known/jwks.json [get]
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	jwks, err := utils.PublicJWKS()
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, uuid.Nil, r, http.StatusInternalServerError, err, startTime, "Ключи подписи не настроены")
+		http.Error(w, "Ключи подписи не настроены", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.JSONFormat(w, r, jwks)
+	loging.LogRequest(logrus.InfoLevel, uuid.Nil, r, http.StatusOK, nil, startTime, "Публичные ключи JWT отданы")
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// VerifyLedgerHandler сверка кошельков с журналом проводок
+//
+// @Summary Проверка журнала проводок
+// @Description Проверяет, что все записи журнала сбалансированы и остатки кошельков совпадают с суммой проводок по счетам сотрудников.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} ledger.Report "Результат проверки"
+// @Failure 500 {object} string "Ошибка проверки журнала"
+// @Router /api/admin/ledger/verify [get]
+// @Security BearerAuth
+func VerifyLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	report, err := ledger.Verify(migrations.DB.WithContext(ctx))
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проверки журнала проводок")
+		http.Error(w, "Ошибка проверки журнала проводок", http.StatusInternalServerError)
+		return
+	}
+
+	level := logrus.InfoLevel
+	if !report.Balanced {
+		level = logrus.WarnLevel
+	}
+	utils.JSONFormat(w, r, report)
+	loging.LogRequest(level, userID, r, http.StatusOK, nil, startTime, "Проверка журнала проводок выполнена")
+}
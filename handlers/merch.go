@@ -9,6 +9,7 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
@@ -16,7 +17,7 @@ import (
 // ShowMerchHandler возвращает список доступного мерча.
 //
 // @Summary Получение списка мерча
-// @Description Возвращает список товаров из базы данных или кэша Redis.
+// @Description Возвращает список товаров вместе с вариантами (SKU, атрибуты, цена, остаток) из базы данных или кэша Redis.
 // @Tags Employee
 // @Accept  json
 // @Produce  json
@@ -32,7 +33,7 @@ func ShowMerchHandler(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var merches []models.Merch
-	cacheKey := "merch:all"
+	cacheKey := merchCacheKey
 
 	select {
 	case <-ctx.Done():
@@ -42,7 +43,8 @@ func ShowMerchHandler(w http.ResponseWriter, r *http.Request) {
 	default:
 	}
 
-	fromCache, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, cacheKey, migrations.DB, &merches, 5*time.Minute)
+	fromCache, err := utils.GetOrSetCache(ctx, config.Rdb, migrations.DB, cacheKey,
+		migrations.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("sku") }), &merches, 5*time.Minute)
 	if err != nil {
 		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка при поиске мерча.")
 		http.Error(w, "Ошибка при поиске мерча", http.StatusInternalServerError)
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/orders"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// OrdersPage страница заказов. NextCursor пуст на последней странице.
+type OrdersPage struct {
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// OrderStatusRequest запрос на смену статуса заказа. PickupCode обязателен для выдачи, Reason — для отмены.
+type OrderStatusRequest struct {
+	Status     string `json:"status" example:"ready_for_pickup"`
+	PickupCode string `json:"pickupCode,omitempty" example:"042917"`
+	Reason     string `json:"reason,omitempty" example:"Товар поврежден"`
+}
+
+// OrderCancelRequest запрос сотрудника на отмену своего заказа.
+type OrderCancelRequest struct {
+	Reason string `json:"reason,omitempty" example:"Передумал"`
+}
+
+type orderCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func validOrderStatus(status string) bool {
+	switch status {
+	case models.ORDER_STATUS_PLACED, models.ORDER_STATUS_READY_FOR_PICKUP,
+		models.ORDER_STATUS_FULFILLED, models.ORDER_STATUS_CANCELLED:
+		return true
+	}
+	return false
+}
+
+// listOrders страница заказов от новых к старым со строками. db уже содержит фильтры.
+func listOrders(db *gorm.DB, r *http.Request) (OrdersPage, int, error) {
+	page := OrdersPage{Orders: []models.Order{}}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		return page, http.StatusBadRequest, err
+	}
+	query := r.URL.Query()
+	if status := query.Get("status"); status != "" {
+		if !validOrderStatus(status) {
+			return page, http.StatusBadRequest, errors.New("неизвестный статус заказа: " + status)
+		}
+		db = db.Where("status = ?", status)
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		var cursor orderCursor
+		if err := utils.DecodeCursor(raw, &cursor); err != nil {
+			return page, http.StatusBadRequest, errors.New("некорректный курсор")
+		}
+		db = db.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var list []models.Order
+	err = db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("item") }).
+		Order("created_at DESC, id DESC").Limit(limit + 1).Find(&list).Error
+	if err != nil {
+		return page, http.StatusInternalServerError, err
+	}
+	if len(list) > limit {
+		list = list[:limit]
+		last := list[limit-1]
+		page.NextCursor = utils.EncodeCursor(orderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	page.Orders = list
+	return page, http.StatusOK, nil
+}
+
+// advanceOrder блокирует заказ и меняет его статус в одной транзакции. allow, если задан,
+// проверяет уже заблокированный заказ перед сменой статуса.
+func advanceOrder(ctx context.Context, actorID, orderID uuid.UUID, allow func(models.Order) error, req OrderStatusRequest) (models.Order, error) {
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	order, err := orders.Lock(tx, orderID)
+	if err != nil {
+		return order, err
+	}
+	if allow != nil {
+		if err := allow(order); err != nil {
+			return order, err
+		}
+	}
+	stockChanged, err := orders.Advance(tx, actorID, &order, req.Status, req.PickupCode, req.Reason)
+	if err != nil {
+		return order, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return order, err
+	}
+	committed = true
+
+	if order.Status == models.ORDER_STATUS_CANCELLED {
+		invalidateCache(ctx, fmt.Sprintf("wallet:%s", order.UserID), fmt.Sprintf("inventory:%s", order.UserID))
+	}
+	if stockChanged {
+		invalidateMerchCache(ctx)
+	}
+	return order, nil
+}
+
+// orderErrorStatus HTTP-статус и сообщение для ошибки смены статуса заказа.
+func orderErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, orders.ErrOrderNotFound):
+		return http.StatusNotFound, "Заказ не найден"
+	case errors.Is(err, orders.ErrInvalidTransition):
+		return http.StatusConflict, "Недопустимый переход статуса заказа"
+	case errors.Is(err, orders.ErrWrongPickupCode):
+		return http.StatusForbidden, "Неверный код выдачи"
+	case errors.Is(err, orders.ErrReasonRequired):
+		return http.StatusBadRequest, "Нужно указать причину отмены"
+	}
+	return http.StatusInternalServerError, "Ошибка изменения статуса заказа"
+}
+
+// MyOrdersHandler заказы сотрудника
+//
+// @Summary Мои заказы
+// @Description Возвращает заказы текущего пользователя с кодами выдачи, от новых к старым, с курсорной пагинацией.
+// @Tags Orders
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Статус: placed, ready_for_pickup, fulfilled или cancelled"
+// @Param limit query int false "Размер страницы, от 1 до 200 (по умолчанию 50)"
+// @Param cursor query string false "Курсор следующей страницы из nextCursor"
+// @Success 200 {object} OrdersPage "Страница заказов"
+// @Failure 400 {object} string "Некорректный фильтр, limit или курсор"
+// @Failure 500 {object} string "Ошибка получения заказов"
+// @Router /api/orders [get]
+// @Security BearerAuth
+func MyOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	page, status, err := listOrders(migrations.DB.WithContext(ctx).Where("user_id = ?", userID), r)
+	if err != nil {
+		if status == http.StatusBadRequest {
+			loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Некорректный запрос заказов")
+			http.Error(w, err.Error(), status)
+			return
+		}
+		loging.LogRequest(logrus.ErrorLevel, userID, r, status, err, startTime, "Ошибка получения заказов")
+		http.Error(w, "Ошибка получения заказов", status)
+		return
+	}
+
+	utils.JSONFormat(w, r, page)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получены заказы пользователя")
+}
+
+// CancelMyOrderHandler отмена заказа сотрудником
+//
+// @Summary Отмена своего заказа
+// @Description Сотрудник может отменить свой заказ, пока он не собран (статус placed). Монеты возвращаются на кошелек, товары — на остаток в одной транзакции.
+// @Tags Orders
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID заказа"
+// @Param request body OrderCancelRequest false "Причина отмены"
+// @Success 200 {object} models.Order "Заказ отменен"
+// @Failure 400 {object} string "Некорректный ID заказа"
+// @Failure 404 {object} string "Заказ не найден"
+// @Failure 409 {object} string "Заказ уже собран, выдан или отменен"
+// @Failure 500 {object} string "Ошибка отмены заказа"
+// @Router /api/orders/{id}/cancel [post]
+// @Security BearerAuth
+func CancelMyOrderHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	orderID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID заказа")
+		http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+		return
+	}
+
+	var req OrderCancelRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный запрос")
+			http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "Отменен сотрудником"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Сотрудник отменяет только свой несобранный заказ: собранный уже отложен на выдачу.
+	allow := func(order models.Order) error {
+		if order.UserID != userID {
+			return orders.ErrOrderNotFound
+		}
+		if order.Status != models.ORDER_STATUS_PLACED {
+			return orders.ErrInvalidTransition
+		}
+		return nil
+	}
+	order, err := advanceOrder(ctx, userID, orderID, allow, OrderStatusRequest{
+		Status: models.ORDER_STATUS_CANCELLED,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		status, message := orderErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка отмены заказа "+orderID.String())
+		http.Error(w, message, status)
+		return
+	}
+
+	utils.JSONFormat(w, r, order)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Сотрудник отменил заказ "+orderID.String())
+}
+
+// AdminOrdersHandler заказы для выдачи
+//
+// @Summary Список заказов
+// @Description Возвращает заказы всех сотрудников от новых к старым с фильтром по статусу и сотруднику. Коды выдачи не показываются: их называет сотрудник.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Статус: placed, ready_for_pickup, fulfilled или cancelled"
+// @Param userId query string false "ID сотрудника"
+// @Param limit query int false "Размер страницы, от 1 до 200 (по умолчанию 50)"
+// @Param cursor query string false "Курсор следующей страницы из nextCursor"
+// @Success 200 {object} OrdersPage "Страница заказов"
+// @Failure 400 {object} string "Некорректный фильтр, limit или курсор"
+// @Failure 500 {object} string "Ошибка получения заказов"
+// @Router /api/admin/orders [get]
+// @Security BearerAuth
+func AdminOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	db := migrations.DB.WithContext(ctx)
+	if raw := r.URL.Query().Get("userId"); raw != "" {
+		ownerID, err := uuid.Parse(raw)
+		if err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный userId")
+			http.Error(w, "Некорректный userId", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("user_id = ?", ownerID)
+	}
+
+	page, status, err := listOrders(db, r)
+	if err != nil {
+		if status == http.StatusBadRequest {
+			loging.LogRequest(logrus.WarnLevel, userID, r, status, err, startTime, "Некорректный запрос заказов")
+			http.Error(w, err.Error(), status)
+			return
+		}
+		loging.LogRequest(logrus.ErrorLevel, userID, r, status, err, startTime, "Ошибка получения заказов")
+		http.Error(w, "Ошибка получения заказов", status)
+		return
+	}
+	for i := range page.Orders {
+		page.Orders[i].PickupCode = ""
+	}
+
+	utils.JSONFormat(w, r, page)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получен список заказов")
+}
+
+// UpdateOrderStatusHandler смена статуса заказа
+//
+// @Summary Смена статуса заказа
+// @Description Переводы: placed → ready_for_pickup → fulfilled, отмена (cancelled) из placed и ready_for_pickup. Выдача требует код выдачи сотрудника, отмена — причину; при отмене монеты возвращаются на кошелек, а товары на остаток в одной транзакции.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID заказа"
+// @Param request body OrderStatusRequest true "Новый статус"
+// @Success 200 {object} models.Order "Статус изменен"
+// @Failure 400 {object} string "Некорректный запрос или не указана причина отмены"
+// @Failure 403 {object} string "Неверный код выдачи"
+// @Failure 404 {object} string "Заказ не найден"
+// @Failure 409 {object} string "Недопустимый переход статуса"
+// @Failure 500 {object} string "Ошибка изменения статуса заказа"
+// @Router /api/admin/orders/{id}/status [post]
+// @Security BearerAuth
+func UpdateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	orderID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID заказа")
+		http.Error(w, "Некорректный ID заказа", http.StatusBadRequest)
+		return
+	}
+
+	var req OrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный запрос")
+		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+		return
+	}
+	if !validOrderStatus(req.Status) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Неизвестный статус заказа: "+req.Status)
+		http.Error(w, "Неизвестный статус заказа", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	order, err := advanceOrder(ctx, userID, orderID, nil, req)
+	if err != nil {
+		status, message := orderErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка изменения статуса заказа "+orderID.String())
+		http.Error(w, message, status)
+		return
+	}
+	order.PickupCode = ""
+
+	utils.JSONFormat(w, r, order)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Заказ "+orderID.String()+" переведен в статус "+order.Status)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/reconcile"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReconciliationResult итог сверки: запуск и найденные расхождения.
+type ReconciliationResult struct {
+	Run        models.ReconciliationRun `json:"run"`
+	Mismatches []models.BalanceMismatch `json:"mismatches"`
+}
+
+// ResolveMismatchRequest решение админа по расхождению.
+type ResolveMismatchRequest struct {
+	Resolution string `json:"resolution" example:"ledger"`
+	Reason     string `json:"reason" example:"Начисление до появления журнала, подтверждено бухгалтерией"`
+}
+
+// mismatchErrorStatus HTTP-статус и сообщение для ошибки закрытия расхождения.
+func mismatchErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, reconcile.ErrMismatchNotFound):
+		return http.StatusNotFound, "Расхождение не найдено"
+	case errors.Is(err, reconcile.ErrNotOpen):
+		return http.StatusConflict, "Расхождение уже закрыто"
+	case errors.Is(err, reconcile.ErrStale):
+		return http.StatusConflict, "Кошелек или журнал изменились после сверки, запустите сверку заново"
+	case errors.Is(err, reconcile.ErrNegativeBalance):
+		return http.StatusConflict, "Остаток по истории отрицательный, вернуть его кошельку нельзя"
+	case errors.Is(err, reconcile.ErrInvalidResolution):
+		return http.StatusBadRequest, "resolution должен быть ledger или wallet"
+	case errors.Is(err, reconcile.ErrReasonRequired):
+		return http.StatusBadRequest, "Нужно указать причину"
+	}
+	return http.StatusInternalServerError, "Ошибка исправления расхождения"
+}
+
+// RunReconciliationHandler запуск сверки
+//
+// @Summary Сверка кошельков с историей
+// @Description Сравнивает остаток каждого кошелька с остатком по истории операций (стартовый баланс, начисления, полученные и отправленные переводы, покупки, возвраты) и сохраняет найденные расхождения. Открытые расхождения прошлых сверок помечаются устаревшими. Ничего не исправляет.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 201 {object} ReconciliationResult "Итог сверки"
+// @Failure 500 {object} string "Ошибка сверки"
+// @Router /api/admin/reconciliation/runs [post]
+// @Security BearerAuth
+func RunReconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	run, mismatches, err := reconcile.Run(migrations.DB.WithContext(ctx), models.RECONCILIATION_TRIGGER_MANUAL, &userID)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сверки кошельков")
+		http.Error(w, "Ошибка сверки", http.StatusInternalServerError)
+		return
+	}
+	reconcile.LogRun(run, mismatches)
+
+	utils.JSONFormatWithStatus(w, r, http.StatusCreated, ReconciliationResult{Run: run, Mismatches: mismatches})
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusCreated, nil, startTime, "Сверка кошельков выполнена, расхождений: "+strconv.Itoa(run.Mismatches))
+}
+
+// MismatchesHandler список расхождений
+//
+// @Summary Расхождения кошельков с историей
+// @Description Возвращает расхождения, найденные сверками, от новых к старым. По умолчанию — только открытые.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param status query string false "Статус: open, resolved или superseded (по умолчанию open)"
+// @Param limit query int false "Количество записей, от 1 до 200 (по умолчанию 50)"
+// @Success 200 {array} models.BalanceMismatch "Расхождения"
+// @Failure 400 {object} string "Некорректные параметры"
+// @Failure 500 {object} string "Ошибка получения расхождений"
+// @Router /api/admin/reconciliation/mismatches [get]
+// @Security BearerAuth
+func MismatchesHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.MISMATCH_STATUS_OPEN
+	case models.MISMATCH_STATUS_OPEN, models.MISMATCH_STATUS_RESOLVED, models.MISMATCH_STATUS_SUPERSEDED:
+	default:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректный статус расхождения: "+status)
+		http.Error(w, "status должен быть open, resolved или superseded", http.StatusBadRequest)
+		return
+	}
+
+	mismatches := []models.BalanceMismatch{}
+	if err := migrations.DB.WithContext(ctx).
+		Where("status = ?", status).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&mismatches).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения расхождений")
+		http.Error(w, "Ошибка получения расхождений", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, mismatches)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получены расхождения: "+strconv.Itoa(len(mismatches)))
+}
+
+// ResolveMismatchHandler исправление расхождения
+//
+// @Summary Исправление расхождения
+// @Description Закрывает открытое расхождение после проверки админом. resolution=ledger признает остаток кошелька верным и пишет в журнал корректирующую запись CORRECTION на разницу; resolution=wallet возвращает кошельку остаток по истории. Если кошелек или журнал изменились после сверки, возвращается 409. Исправление и запись аудита — в одной транзакции.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID расхождения"
+// @Param request body ResolveMismatchRequest true "Способ исправления и причина"
+// @Success 200 {object} models.BalanceMismatch "Расхождение исправлено"
+// @Failure 400 {object} string "Некорректный запрос, способ исправления или не указана причина"
+// @Failure 404 {object} string "Расхождение не найдено"
+// @Failure 409 {object} string "Расхождение уже закрыто или устарело"
+// @Failure 500 {object} string "Ошибка исправления расхождения"
+// @Router /api/admin/reconciliation/mismatches/{id}/resolve [post]
+// @Security BearerAuth
+func ResolveMismatchHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	mismatchID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID расхождения")
+		http.Error(w, "Некорректный ID расхождения", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveMismatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный запрос")
+		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len([]rune(req.Reason)) > 255 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Слишком длинная причина")
+		http.Error(w, "Нужно указать причину до 255 символов", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	mismatch, err := reconcile.Resolve(tx, userID, mismatchID, req.Resolution, req.Reason)
+	if err != nil {
+		status, message := mismatchErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка исправления расхождения "+mismatchID.String())
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	invalidateCache(r.Context(), fmt.Sprintf("wallet:%s", mismatch.UserID))
+
+	utils.JSONFormat(w, r, mismatch)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Исправлено расхождение "+mismatch.ID.String()+" способом "+mismatch.Resolution)
+}
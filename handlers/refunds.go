@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/refunds"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ReversalRequest причина возврата или отмены начисления. Обязательна.
+type ReversalRequest struct {
+	Reason string `json:"reason" example:"Начислено не тому сотруднику"`
+}
+
+// reversalErrorStatus HTTP-статус и сообщение для ошибки возврата.
+func reversalErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, refunds.ErrNotFound):
+		return http.StatusNotFound, "Запись не найдена"
+	case errors.Is(err, refunds.ErrAlreadyReversed):
+		return http.StatusConflict, "Запись уже отменена"
+	case errors.Is(err, refunds.ErrNoLedgerEntry):
+		return http.StatusConflict, "У записи нет проводки в журнале, отменить ее нельзя"
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		return http.StatusConflict, "У сотрудника недостаточно монет для отмены начисления"
+	case errors.Is(err, refunds.ErrReasonRequired):
+		return http.StatusBadRequest, "Нужно указать причину"
+	}
+	return http.StatusInternalServerError, "Ошибка отмены записи"
+}
+
+// reverse общая часть обработчиков возврата: разбор запроса, транзакция и ответ.
+func reverse(w http.ResponseWriter, r *http.Request, apply func(tx *gorm.DB, adminID, targetID uuid.UUID, reason string) (models.Reversal, bool, error)) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	targetID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный ID записи")
+		http.Error(w, "Некорректный ID записи", http.StatusBadRequest)
+		return
+	}
+
+	var req ReversalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный запрос")
+		http.Error(w, "Некорректный запрос", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len([]rune(req.Reason)) > 255 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Не указана или слишком длинная причина")
+		http.Error(w, "Нужно указать причину до 255 символов", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	reversal, stockChanged, err := apply(tx, userID, targetID, req.Reason)
+	if err != nil {
+		status, message := reversalErrorStatus(err)
+		level := logrus.WarnLevel
+		if status == http.StatusInternalServerError {
+			level = logrus.ErrorLevel
+		}
+		loging.LogRequest(level, userID, r, status, err, startTime, "Ошибка отмены записи "+targetID.String())
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	invalidateCache(r.Context(),
+		fmt.Sprintf("wallet:%s", reversal.UserID),
+		fmt.Sprintf("inventory:%s", reversal.UserID),
+		fmt.Sprintf("sent:%s", reversal.UserID),
+		adjustmentsCacheKey(reversal.UserID))
+	if stockChanged {
+		invalidateMerchCache(r.Context())
+	}
+
+	utils.JSONFormatWithStatus(w, r, http.StatusCreated, reversal)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusCreated, nil, startTime, "Отменена запись "+reversal.Kind+" "+targetID.String())
+}
+
+// RefundPurchaseHandler возврат покупки
+//
+// @Summary Возврат покупки
+// @Description Создает компенсирующую запись к покупке: монеты возвращаются на кошелек сотрудника, товар — на остаток, заказ покупки отменяется. Исходная покупка не удаляется. Все изменения и запись аудита — в одной транзакции.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID покупки"
+// @Param request body ReversalRequest true "Причина возврата"
+// @Success 201 {object} models.Reversal "Покупка возвращена"
+// @Failure 400 {object} string "Некорректный ID или не указана причина"
+// @Failure 404 {object} string "Покупка не найдена"
+// @Failure 409 {object} string "Покупка уже возвращена или у нее нет проводки в журнале"
+// @Failure 500 {object} string "Ошибка возврата"
+// @Router /api/admin/purchases/{id}/refund [post]
+// @Security BearerAuth
+func RefundPurchaseHandler(w http.ResponseWriter, r *http.Request) {
+	reverse(w, r, refunds.RefundPurchase)
+}
+
+// ReverseTopUpHandler отмена начисления
+//
+// @Summary Отмена ошибочного начисления
+// @Description Создает компенсирующую запись к начислению админа (ID записи журнала из ответа POST /api/admin/users): монеты списываются с кошелька сотрудника обратно на счет эмиссии. Если сотрудник уже потратил монеты, возвращается 409.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param id path string true "ID записи журнала начисления"
+// @Param request body ReversalRequest true "Причина отмены"
+// @Success 201 {object} models.Reversal "Начисление отменено"
+// @Failure 400 {object} string "Некорректный ID или не указана причина"
+// @Failure 404 {object} string "Начисление не найдено"
+// @Failure 409 {object} string "Начисление уже отменено или у сотрудника недостаточно монет"
+// @Failure 500 {object} string "Ошибка отмены начисления"
+// @Router /api/admin/top-ups/{id}/reverse [post]
+// @Security BearerAuth
+func ReverseTopUpHandler(w http.ResponseWriter, r *http.Request) {
+	reverse(w, r, func(tx *gorm.DB, adminID, targetID uuid.UUID, reason string) (models.Reversal, bool, error) {
+		reversal, err := refunds.ReverseTopUp(tx, adminID, targetID, reason)
+		return reversal, false, err
+	})
+}
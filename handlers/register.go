@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"Shop/config"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const minPasswordLength = 6
+
+// RegisterRequest тело запроса на регистрацию.
+//
+// @Description Email, пароль и необязательный код приглашения
+type RegisterRequest struct {
+	Email      string `json:"email" example:"user@example.com"`
+	Password   string `json:"password" example:"securepassword"`
+	InviteCode string `json:"inviteCode,omitempty" example:"k3Jd9sLq"`
+}
+
+// RegisterResponse ответ на успешную регистрацию.
+//
+// @Description Сгенерированный никнейм, стартовый баланс и пара токенов
+type RegisterResponse struct {
+	Username string `json:"username"`
+	Balance  uint   `json:"balance"`
+	utils.TokenPair
+}
+
+// createEmployee создает сотрудника со сгенерированным никнеймом и кошельком со стартовым балансом.
+func createEmployee(tx *gorm.DB, email, password string) (models.User, models.Wallet, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, models.Wallet{}, err
+	}
+
+	user := models.User{
+		ID:       uuid.New(),
+		Username: utils.GenerateUsername(),
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     models.EMPLOYEE_ROLE,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return user, models.Wallet{}, err
+	}
+
+	wallet, err := ledger.OpenWallet(tx, user.ID, config.StartingBalance())
+	return user, wallet, err
+}
+
+var errInvalidEmail = errors.New("некорректный email")
+
+// normalizeEmail приводит email к виду, в котором он хранится и ищется при входе: нижний
+// регистр без пробелов. Адрес должен быть голым, без имени и угловых скобок.
+func normalizeEmail(raw string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return "", errInvalidEmail
+	}
+	return email, nil
+}
+
+func emailDomainAllowed(email string) bool {
+	allowed := config.AllowedEmailDomains()
+	if len(allowed) == 0 {
+		return true
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, candidate := range allowed {
+		if domain == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterHandler регистрирует нового сотрудника.
+//
+// @Summary Регистрация сотрудника
+// @Description Создает учетную запись с автоматически сгенерированным никнеймом и стартовым балансом. Без кода приглашения регистрация доступна только для доменов из REGISTRATION_ALLOWED_DOMAINS; при REGISTRATION_INVITE_REQUIRED код приглашения обязателен.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body RegisterRequest true "Тело запроса"
+// @Success 201 {object} RegisterResponse "Пользователь создан"
+// @Failure 400 {string} string "Некорректное тело запроса, email или пароль"
+// @Failure 403 {string} string "Домен не разрешен или код приглашения недействителен"
+// @Failure 409 {string} string "Пользователь с таким email уже существует"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /api/register [post]
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var input RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	email, err := normalizeEmail(input.Email)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, err, startTime, "Некорректный email")
+		http.Error(w, "Некорректный email", http.StatusBadRequest)
+		return
+	}
+	if len(input.Password) < minPasswordLength {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusBadRequest, nil, startTime, "Слишком короткий пароль")
+		http.Error(w, "Пароль должен быть не короче 6 символов", http.StatusBadRequest)
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	var invite models.InviteCode
+	hasInvite := input.InviteCode != ""
+	if hasInvite {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", input.InviteCode).First(&invite).Error
+		if err != nil || invite.UsedBy != nil || (invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt)) {
+			loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusForbidden, err, startTime, "Код приглашения недействителен")
+			http.Error(w, "Код приглашения недействителен", http.StatusForbidden)
+			return
+		}
+	} else if config.InviteRequired() {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusForbidden, nil, startTime, "Регистрация без кода приглашения запрещена")
+		http.Error(w, "Для регистрации нужен код приглашения", http.StatusForbidden)
+		return
+	}
+
+	if !hasInvite && !emailDomainAllowed(email) {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusForbidden, nil, startTime, "Регистрация с доменом email запрещена: "+email)
+		http.Error(w, "Регистрация с этим доменом email запрещена", http.StatusForbidden)
+		return
+	}
+
+	var existing models.User
+	if err := tx.Where("email = ?", email).First(&existing).Error; err == nil {
+		loging.LogRequest(logrus.WarnLevel, uuid.Nil, r, http.StatusConflict, nil, startTime, "Пользователь с таким email уже существует")
+		http.Error(w, "Пользователь с таким email уже существует", http.StatusConflict)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		loging.LogRequest(logrus.ErrorLevel, uuid.Nil, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска пользователя")
+		http.Error(w, "Не удалось создать пользователя", http.StatusInternalServerError)
+		return
+	}
+
+	user, wallet, err := createEmployee(tx, email, input.Password)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось создать пользователя")
+		http.Error(w, "Не удалось создать пользователя", http.StatusInternalServerError)
+		return
+	}
+
+	if hasInvite {
+		now := time.Now()
+		if err := tx.Model(&invite).Updates(models.InviteCode{UsedBy: &user.ID, UsedAt: &now}).Error; err != nil {
+			loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось погасить код приглашения")
+			http.Error(w, "Не удалось создать пользователя", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tokens, err := utils.IssueTokenPair(tx, user)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Не удалось создать JWT")
+		http.Error(w, "Не удалось создать JWT", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, user.ID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormatWithStatus(w, r, http.StatusCreated, RegisterResponse{
+		Username:  user.Username,
+		Balance:   wallet.Coin,
+		TokenPair: tokens,
+	})
+	loging.LogRequest(logrus.InfoLevel, user.ID, r, http.StatusCreated, nil, startTime, "Пользователь зарегистрирован: "+user.Username)
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"Shop/audit"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/ledger"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+type RoleChangeRequest struct {
+	Username string `json:"username" example:"CoolTiger1234"`
+	Role     string `json:"role,omitempty" example:"ADMIN_ROLE"`
+	Reason   string `json:"reason" example:"Новый офис-менеджер"`
+}
+
+type RoleChangeResponse struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// RoleRequest тело запроса на создание или изменение роли.
+type RoleRequest struct {
+	Description string   `json:"description" example:"Менеджер каталога"`
+	Permissions []string `json:"permissions" example:"merch:write,info:read"`
+}
+
+// RoleInfo роль вместе с ее правами.
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RolesResponse список ролей и всех известных прав.
+type RolesResponse struct {
+	Roles       []RoleInfo          `json:"roles"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+var roleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_:-]{1,100}$`)
+
+func rolePermissions(db *gorm.DB, role string) ([]string, error) {
+	permissions := []string{}
+	err := db.Model(&models.RolePermission{}).
+		Where("role_name = ?", role).
+		Order("permission_name").
+		Pluck("permission_name", &permissions).Error
+	return permissions, err
+}
+
+// roleChange событие аудита смены роли пользователя.
+func roleChange(action string, user models.User, from, to, reason string) audit.Event {
+	return audit.Event{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Before:     map[string]string{"username": user.Username, "role": from},
+		After:      map[string]string{"username": user.Username, "role": to},
+		Details:    reason,
+	}
+}
+
+// BootstrapAdmin создает первого администратора. Если администратор уже есть, ничего не меняет
+// и возвращает created = false. Если пользователь с таким email существует, ему выдается роль.
+// Email приводится к нижнему регистру, как при регистрации, иначе администратор не сможет войти.
+func BootstrapAdmin(db *gorm.DB, email, password string) (models.User, bool, error) {
+	var user models.User
+	created := false
+
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return user, false, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", models.ADMIN_ROLE).First(&user).Error; err == nil {
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		from := ""
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			from = user.Role
+			if err := tx.Model(&user).Update("role", models.ADMIN_ROLE).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if password == "" {
+				return errors.New("для нового администратора нужен пароль")
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				ID:       uuid.New(),
+				Username: utils.GenerateUsername(),
+				Email:    email,
+				Password: string(hashedPassword),
+				Role:     models.ADMIN_ROLE,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if _, err := ledger.OpenWallet(tx, user.ID, 0); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		created = true
+		return audit.Write(tx, uuid.Nil, roleChange(models.AUDIT_ADMIN_BOOTSTRAP, user, from, models.ADMIN_ROLE, "bootstrap"))
+	})
+
+	return user, created, err
+}
+
+// GrantRoleHandler выдача роли
+//
+// @Summary Выдача роли
+// @Description Выдает существующему пользователю роль из таблицы ролей (по умолчанию ADMIN_ROLE). Изменение записывается в журнал аудита.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param request body RoleChangeRequest true "Тело запроса"
+// @Success 200 {object} RoleChangeResponse "Роль выдана"
+// @Failure 400 {object} string "Некорректное тело запроса"
+// @Failure 404 {object} string "Пользователь или роль не найдены"
+// @Failure 409 {object} string "У пользователя уже эта роль, это последний администратор или служебный пользователь"
+// @Failure 500 {object} string "Ошибка изменения роли"
+// @Router /api/admin/roles/grant [post]
+// @Security BearerAuth
+func GrantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	changeRole(w, r, models.AUDIT_ROLE_GRANT)
+}
+
+// RevokeRoleHandler снятие роли
+//
+// @Summary Снятие роли
+// @Description Возвращает пользователю роль EMPLOYEE_ROLE. Снять роль с последнего администратора нельзя. Изменение записывается в журнал аудита.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param request body RoleChangeRequest true "Тело запроса"
+// @Success 200 {object} RoleChangeResponse "Роль снята"
+// @Failure 400 {object} string "Некорректное тело запроса"
+// @Failure 404 {object} string "Пользователь не найден"
+// @Failure 409 {object} string "У пользователя нет дополнительной роли, это последний администратор или служебный пользователь"
+// @Failure 500 {object} string "Ошибка изменения роли"
+// @Router /api/admin/roles/revoke [post]
+// @Security BearerAuth
+func RevokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	changeRole(w, r, models.AUDIT_ROLE_REVOKE)
+}
+
+func changeRole(w http.ResponseWriter, r *http.Request, action string) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var input RoleChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Username == "" {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	role := models.EMPLOYEE_ROLE
+	if action == models.AUDIT_ROLE_GRANT {
+		role = input.Role
+		if role == "" {
+			role = models.ADMIN_ROLE
+		}
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("name = ?", role).First(&models.Role{}).Error; err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Роль "+role+" не найдена")
+		http.Error(w, "Роль "+role+" не найдена", http.StatusNotFound)
+		return
+	}
+
+	var target models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("username = ?", input.Username).First(&target).Error; err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Пользователь "+input.Username+" не найден")
+		http.Error(w, "Пользователь "+input.Username+" не найден", http.StatusNotFound)
+		return
+	}
+
+	if target.Role == models.SYSTEM_ROLE {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Попытка изменить роль служебного пользователя "+target.Username)
+		http.Error(w, "Роль служебного пользователя не меняется", http.StatusConflict)
+		return
+	}
+
+	if target.Role == role {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Пользователь уже имеет роль "+role)
+		http.Error(w, "Пользователь уже имеет роль "+role, http.StatusConflict)
+		return
+	}
+
+	if target.Role == models.ADMIN_ROLE {
+		var admins []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("role = ?", models.ADMIN_ROLE).Find(&admins).Error; err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска администраторов")
+			http.Error(w, "Ошибка изменения роли", http.StatusInternalServerError)
+			return
+		}
+		if len(admins) <= 1 {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Попытка снять роль с последнего администратора")
+			http.Error(w, "Нельзя снять роль с последнего администратора", http.StatusConflict)
+			return
+		}
+	}
+
+	previousRole := target.Role
+	if err := tx.Model(&target).Update("role", role).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка изменения роли")
+		http.Error(w, "Ошибка изменения роли", http.StatusInternalServerError)
+		return
+	}
+
+	if err := audit.Write(tx, userID, roleChange(action, target, previousRole, role, input.Reason)); err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка изменения роли", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormat(w, r, RoleChangeResponse{Username: target.Username, Role: role})
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Роль пользователя "+target.Username+" изменена на "+role)
+}
+
+// ListRolesHandler список ролей
+//
+// @Summary Список ролей
+// @Description Возвращает роли с их правами и список всех известных прав.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} RolesResponse "Роли и права"
+// @Failure 500 {object} string "Ошибка получения ролей"
+// @Router /api/admin/roles [get]
+// @Security BearerAuth
+func ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	db := migrations.DB.WithContext(ctx)
+
+	var roles []models.Role
+	if err := db.Order("name").Find(&roles).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения ролей")
+		http.Error(w, "Ошибка получения ролей", http.StatusInternalServerError)
+		return
+	}
+
+	response := RolesResponse{Roles: make([]RoleInfo, 0, len(roles))}
+	for _, role := range roles {
+		permissions, err := rolePermissions(db, role.Name)
+		if err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения прав роли "+role.Name)
+			http.Error(w, "Ошибка получения ролей", http.StatusInternalServerError)
+			return
+		}
+		response.Roles = append(response.Roles, RoleInfo{Name: role.Name, Description: role.Description, Permissions: permissions})
+	}
+	if err := db.Order("name").Find(&response.Permissions).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения прав")
+		http.Error(w, "Ошибка получения ролей", http.StatusInternalServerError)
+		return
+	}
+
+	utils.JSONFormat(w, r, response)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Список ролей получен")
+}
+
+// PutRoleHandler создание или изменение роли
+//
+// @Summary Создание или изменение роли
+// @Description Создает роль или полностью заменяет ее набор прав. Права ADMIN_ROLE не меняются. Изменение записывается в журнал аудита.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param name path string true "Название роли"
+// @Param request body RoleRequest true "Тело запроса"
+// @Success 200 {object} RoleInfo "Роль сохранена"
+// @Failure 400 {object} string "Некорректное название, тело запроса или неизвестное право"
+// @Failure 409 {object} string "Права ADMIN_ROLE и SYSTEM_ROLE не меняются"
+// @Failure 500 {object} string "Ошибка сохранения роли"
+// @Router /api/admin/roles/{name} [put]
+// @Security BearerAuth
+func PutRoleHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	name := mux.Vars(r)["name"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !roleNamePattern.MatchString(name) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректное название роли: "+name)
+		http.Error(w, "Некорректное название роли", http.StatusBadRequest)
+		return
+	}
+	if name == models.ADMIN_ROLE {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Попытка изменить права ADMIN_ROLE")
+		http.Error(w, "Права ADMIN_ROLE не меняются", http.StatusConflict)
+		return
+	}
+	if name == models.SYSTEM_ROLE {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "Попытка выдать права SYSTEM_ROLE")
+		http.Error(w, "У SYSTEM_ROLE не может быть прав", http.StatusConflict)
+		return
+	}
+
+	var input RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	requested := make(map[string]bool, len(input.Permissions))
+	for _, permission := range input.Permissions {
+		requested[permission] = true
+	}
+	var known int64
+	if err := tx.Model(&models.Permission{}).Where("name IN ?", input.Permissions).Count(&known).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка проверки прав")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+	if int(known) != len(requested) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Неизвестное право в запросе")
+		http.Error(w, "Неизвестное право в запросе", http.StatusBadRequest)
+		return
+	}
+
+	before, err := rolePermissions(tx, name)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения прав роли")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+
+	role := models.Role{Name: name, Description: input.Description}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description"}),
+	}).Create(&role).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сохранения роли")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Where("role_name = ?", name).Delete(&models.RolePermission{}).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка удаления прав роли")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+	links := make([]models.RolePermission, 0, len(requested))
+	for permission := range requested {
+		links = append(links, models.RolePermission{RoleName: name, PermissionName: permission})
+	}
+	if len(links) > 0 {
+		if err := tx.Create(&links).Error; err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сохранения прав роли")
+			http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	after, err := rolePermissions(tx, name)
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения прав роли")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_ROLE_UPDATE,
+		TargetType: "role",
+		TargetID:   name,
+		Before:     map[string]interface{}{"permissions": before},
+		After:      map[string]interface{}{"permissions": after},
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка сохранения роли", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+
+	utils.JSONFormat(w, r, RoleInfo{Name: name, Description: input.Description, Permissions: after})
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Роль "+name+" сохранена")
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"Shop/audit"
+	"Shop/config"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const merchCacheKey = "merch:all"
+
+// RestockRequest тело запроса на пополнение остатка.
+//
+// @Description Quantity добавляется к остатку. Для товара без учета остатка учет начинается с Quantity
+type RestockRequest struct {
+	Quantity          uint  `json:"quantity" example:"40"`
+	LowStockThreshold *uint `json:"lowStockThreshold,omitempty" example:"5"`
+}
+
+// StockInfo остаток товара или варианта. Item — название мерча или SKU варианта,
+// Stock пустой — запас не ограничен.
+type StockInfo struct {
+	Item              string `json:"item"`
+	Merch             string `json:"merch"`
+	Stock             *uint  `json:"stock"`
+	LowStockThreshold uint   `json:"lowStockThreshold"`
+}
+
+func stockInfo(item inventory.Item) StockInfo {
+	return StockInfo{
+		Item:              item.Name(),
+		Merch:             item.Merch.Name,
+		Stock:             item.Stock(),
+		LowStockThreshold: item.Merch.LowStockThreshold,
+	}
+}
+
+// invalidateCache удаляет ключи кэша после изменения данных. Ошибка Redis не ломает запрос:
+// в худшем случае данные обновятся по истечении TTL.
+func invalidateCache(ctx context.Context, keys ...string) {
+	if config.Rdb == nil {
+		return
+	}
+	if err := config.Rdb.Del(ctx, keys...).Err(); err != nil {
+		loging.Log.WithError(err).Warn("Не удалось сбросить кэш")
+	}
+}
+
+// invalidateMerchCache сбрасывает кэш списка мерча после изменения остатков.
+func invalidateMerchCache(ctx context.Context) {
+	invalidateCache(ctx, merchCacheKey)
+}
+
+// RestockMerchHandler пополнение остатка мерча
+//
+// @Summary Пополнение остатка мерча
+// @Description Добавляет quantity к остатку товара или варианта (по SKU) и при необходимости меняет порог низкого остатка мерча. Изменение записывается в журнал аудита.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param item path string true "Название товара или SKU варианта"
+// @Param request body RestockRequest true "Тело запроса"
+// @Success 200 {object} StockInfo "Новый остаток"
+// @Failure 400 {object} string "Некорректное тело запроса или у товара есть варианты и нужен SKU"
+// @Failure 404 {object} string "Товар не найден"
+// @Failure 500 {object} string "Ошибка пополнения остатка"
+// @Router /api/admin/merch/{item}/restock [post]
+// @Security BearerAuth
+func RestockMerchHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	itemName := mux.Vars(r)["item"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var input RestockRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if input.Quantity == 0 && input.LowStockThreshold == nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Пустой запрос на пополнение")
+		http.Error(w, "Нужно указать quantity или lowStockThreshold", http.StatusBadRequest)
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	item, err := inventory.Lookup(tx, itemName)
+	switch {
+	case errors.Is(err, inventory.ErrItemNotFound):
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Товар "+itemName+" не найден")
+		http.Error(w, "Товар не найден", http.StatusNotFound)
+		return
+	case errors.Is(err, inventory.ErrVariantRequired) && input.Quantity > 0:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "У товара "+itemName+" есть варианты, нужен SKU")
+		http.Error(w, "Остаток пополняется по SKU варианта", http.StatusBadRequest)
+		return
+	case err != nil && !errors.Is(err, inventory.ErrVariantRequired):
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска товара")
+		http.Error(w, "Ошибка пополнения остатка", http.StatusInternalServerError)
+		return
+	}
+	before := stockInfo(item)
+
+	if input.Quantity > 0 {
+		stock := input.Quantity
+		if current := item.Stock(); current != nil {
+			stock += *current
+		}
+		var update *gorm.DB
+		if item.Variant != nil {
+			item.Variant.Stock = &stock
+			update = tx.Model(&models.MerchVariant{}).Where("id = ?", item.Variant.ID).Update("stock", stock)
+		} else {
+			item.Merch.Stock = &stock
+			update = tx.Model(&models.Merch{}).Where("id = ?", item.Merch.ID).Update("stock", stock)
+		}
+		if update.Error != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, update.Error, startTime, "Ошибка пополнения остатка")
+			http.Error(w, "Ошибка пополнения остатка", http.StatusInternalServerError)
+			return
+		}
+	}
+	if input.LowStockThreshold != nil {
+		item.Merch.LowStockThreshold = *input.LowStockThreshold
+		if err := tx.Model(&models.Merch{}).Where("id = ?", item.Merch.ID).Update("low_stock_threshold", item.Merch.LowStockThreshold).Error; err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка изменения порога остатка")
+			http.Error(w, "Ошибка пополнения остатка", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_MERCH_RESTOCK,
+		TargetType: "merch",
+		TargetID:   item.Merch.ID.String(),
+		Before:     before,
+		After:      stockInfo(item),
+		Details:    fmt.Sprintf("пополнение на %d", input.Quantity),
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка пополнения остатка", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+	invalidateMerchCache(r.Context())
+
+	utils.JSONFormat(w, r, stockInfo(item))
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Остаток товара "+itemName+" пополнен")
+}
+
+// LowStockHandler отчет о заканчивающемся мерче
+//
+// @Summary Отчет о низких остатках
+// @Description Возвращает товары и варианты с учетом остатка, у которых остаток не больше порога lowStockThreshold мерча, начиная с самых дефицитных.
+// @Tags Admin
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {array} StockInfo "Товары с низким остатком"
+// @Failure 500 {object} string "Ошибка получения остатков"
+// @Router /api/admin/merch/low-stock [get]
+// @Security BearerAuth
+func LowStockHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	db := migrations.DB.WithContext(ctx)
+
+	var merches []models.Merch
+	if err := db.
+		Where("stock IS NOT NULL AND stock <= low_stock_threshold").
+		Where("NOT EXISTS (SELECT 1 FROM merch_variants WHERE merch_variants.merch_id = merches.id)").
+		Find(&merches).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения остатков")
+		http.Error(w, "Ошибка получения остатков", http.StatusInternalServerError)
+		return
+	}
+
+	var variants []models.MerchVariant
+	if err := db.
+		Joins("JOIN merches ON merches.id = merch_variants.merch_id").
+		Where("merch_variants.stock IS NOT NULL AND merch_variants.stock <= merches.low_stock_threshold").
+		Find(&variants).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения остатков вариантов")
+		http.Error(w, "Ошибка получения остатков", http.StatusInternalServerError)
+		return
+	}
+
+	report := make([]StockInfo, 0, len(merches)+len(variants))
+	for _, merch := range merches {
+		report = append(report, stockInfo(inventory.Item{Merch: merch}))
+	}
+	for i := range variants {
+		var merch models.Merch
+		if err := db.Where("id = ?", variants[i].MerchID).First(&merch).Error; err != nil {
+			loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения мерча варианта")
+			http.Error(w, "Ошибка получения остатков", http.StatusInternalServerError)
+			return
+		}
+		report = append(report, stockInfo(inventory.Item{Merch: merch, Variant: &variants[i]}))
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if *report[i].Stock != *report[j].Stock {
+			return *report[i].Stock < *report[j].Stock
+		}
+		return report[i].Item < report[j].Item
+	})
+
+	utils.JSONFormat(w, r, report)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Отчет о низких остатках сформирован")
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DIRECTION_IN  string = "in"
+	DIRECTION_OUT string = "out"
+)
+
+// TransactionItem перевод в истории сотрудника.
+type TransactionItem struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Direction    string    `json:"direction" example:"in"`
+	Counterparty string    `json:"counterparty"`
+	Amount       uint      `json:"amount"`
+	Memo         string    `json:"memo,omitempty"`
+	Category     string    `json:"category,omitempty" example:"thanks"`
+	Kind         string    `json:"kind" example:"transfer"`
+	GrantedBy    string    `json:"grantedBy,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// TransactionsPage страница истории переводов. NextCursor пуст на последней странице.
+type TransactionsPage struct {
+	Transactions []TransactionItem `json:"transactions"`
+	NextCursor   string            `json:"nextCursor,omitempty"`
+}
+
+type transactionCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func parseUintParam(r *http.Request, name string) (*uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// TransactionsHandler история переводов сотрудника
+//
+// @Summary История переводов
+// @Description Возвращает переводы текущего пользователя от новых к старым с курсорной пагинацией по (createdAt, id). Фильтры по направлению, контрагенту, категории, виду операции, сумме и периоду. Начисления и списания админов идут от казны или казне (treasury) с никнеймом админа в grantedBy и причиной.
+// @Tags Employee
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param direction query string false "Направление: in или out"
+// @Param counterparty query string false "Никнейм контрагента"
+// @Param category query string false "Категория: thanks, bet или gift"
+// @Param kind query string false "Вид операции: transfer, grant (начисление админа), debit (списание админа) или expiry (сгорание монет)"
+// @Param minAmount query int false "Минимальная сумма включительно"
+// @Param maxAmount query int false "Максимальная сумма включительно"
+// @Param from query string false "Начало периода, RFC3339" example(2026-01-01T00:00:00Z)
+// @Param to query string false "Конец периода, RFC3339, не включительно" example(2026-02-01T00:00:00Z)
+// @Param limit query int false "Размер страницы, от 1 до 200 (по умолчанию 50)"
+// @Param cursor query string false "Курсор следующей страницы из nextCursor"
+// @Success 200 {object} TransactionsPage "Страница истории"
+// @Failure 400 {object} string "Некорректные параметры или курсор"
+// @Failure 500 {object} string "Ошибка получения истории"
+// @Router /api/transactions [get]
+// @Security BearerAuth
+func TransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	query := r.URL.Query()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный limit")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := migrations.DB.WithContext(ctx).
+		Table("transactions").
+		Select("transactions.id, transactions.created_at, transactions.amount, transactions.memo, transactions.category, "+
+			"transactions.kind, COALESCE(admins.username, '') AS granted_by, transactions.reason, "+
+			"CASE WHEN transactions.to_user = ? THEN ? ELSE ? END AS direction, "+
+			"users.username AS counterparty", userID, DIRECTION_IN, DIRECTION_OUT).
+		Joins("JOIN users ON users.id = CASE WHEN transactions.to_user = ? THEN transactions.from_user ELSE transactions.to_user END", userID).
+		Joins("LEFT JOIN users AS admins ON admins.id = transactions.admin_id")
+
+	switch direction := query.Get("direction"); direction {
+	case "":
+		db = db.Where("(transactions.from_user = ? OR transactions.to_user = ?)", userID, userID)
+	case DIRECTION_IN:
+		db = db.Where("transactions.to_user = ?", userID)
+	case DIRECTION_OUT:
+		db = db.Where("transactions.from_user = ?", userID)
+	default:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректное направление: "+direction)
+		http.Error(w, "direction должен быть in или out", http.StatusBadRequest)
+		return
+	}
+
+	if counterparty := query.Get("counterparty"); counterparty != "" {
+		db = db.Where("users.username = ?", counterparty)
+	}
+
+	if category := query.Get("category"); category != "" {
+		if !validTransferCategory(category) {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Неизвестная категория перевода: "+category)
+			http.Error(w, "Неизвестная категория перевода", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("transactions.category = ?", category)
+	}
+
+	switch kind := query.Get("kind"); kind {
+	case "":
+	case models.TRANSACTION_KIND_TRANSFER, models.TRANSACTION_KIND_GRANT, models.TRANSACTION_KIND_DEBIT, models.TRANSACTION_KIND_EXPIRY:
+		db = db.Where("transactions.kind = ?", kind)
+	default:
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Неизвестный вид операции: "+kind)
+		http.Error(w, "kind должен быть transfer, grant, debit или expiry", http.StatusBadRequest)
+		return
+	}
+
+	minAmount, err := parseUintParam(r, "minAmount")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный minAmount")
+		http.Error(w, "Некорректный minAmount", http.StatusBadRequest)
+		return
+	}
+	maxAmount, err := parseUintParam(r, "maxAmount")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный maxAmount")
+		http.Error(w, "Некорректный maxAmount", http.StatusBadRequest)
+		return
+	}
+	if minAmount != nil {
+		db = db.Where("transactions.amount >= ?", *minAmount)
+	}
+	if maxAmount != nil {
+		db = db.Where("transactions.amount <= ?", *maxAmount)
+	}
+
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный from")
+		http.Error(w, "from должен быть в формате RFC3339", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный to")
+		http.Error(w, "to должен быть в формате RFC3339", http.StatusBadRequest)
+		return
+	}
+	if from != nil {
+		db = db.Where("transactions.created_at >= ?", *from)
+	}
+	if to != nil {
+		db = db.Where("transactions.created_at < ?", *to)
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		var cursor transactionCursor
+		if err := utils.DecodeCursor(raw, &cursor); err != nil {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректный курсор")
+			http.Error(w, "Некорректный курсор", http.StatusBadRequest)
+			return
+		}
+		db = db.Where("(transactions.created_at, transactions.id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+
+	var items []TransactionItem
+	if err := db.Order("transactions.created_at DESC, transactions.id DESC").Limit(limit + 1).Scan(&items).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка получения истории переводов")
+		http.Error(w, "Ошибка получения истории", http.StatusInternalServerError)
+		return
+	}
+
+	page := TransactionsPage{Transactions: items}
+	if page.Transactions == nil {
+		page.Transactions = []TransactionItem{}
+	}
+	if len(items) > limit {
+		page.Transactions = items[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = utils.EncodeCursor(transactionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	utils.JSONFormat(w, r, page)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Получена страница истории переводов: "+strconv.Itoa(len(page.Transactions)))
+}
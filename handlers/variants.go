@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"Shop/audit"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/loging"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// VariantRequest тело запроса на создание или изменение варианта.
+//
+// @Description Price пустой — действует цена мерча, Stock пустой — запас не ограничен
+type VariantRequest struct {
+	Attributes map[string]string `json:"attributes" example:"size:L,color:black"`
+	Price      *uint             `json:"price,omitempty" example:"90"`
+	Stock      *uint             `json:"stock,omitempty" example:"10"`
+}
+
+// PutVariantHandler создание или изменение варианта мерча
+//
+// @Summary Создание или изменение варианта мерча
+// @Description Создает вариант мерча с заданным SKU или заменяет атрибуты, цену и остаток существующего. SKU не может совпадать с названием мерча. Изменение записывается в журнал аудита.
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer {token}"
+// @Param item path string true "Название мерча"
+// @Param sku path string true "SKU варианта"
+// @Param request body VariantRequest true "Тело запроса"
+// @Success 200 {object} models.MerchVariant "Вариант сохранен"
+// @Failure 400 {object} string "Некорректный SKU, тело запроса или цена"
+// @Failure 404 {object} string "Мерч не найден"
+// @Failure 409 {object} string "SKU занят другим мерчем или совпадает с названием мерча"
+// @Failure 500 {object} string "Ошибка сохранения варианта"
+// @Router /api/admin/merch/{item}/variants/{sku} [put]
+// @Security BearerAuth
+func PutVariantHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	userID, _ := r.Context().Value(utils.UserIDKey).(uuid.UUID)
+	vars := mux.Vars(r)
+	itemName, sku := vars["item"], vars["sku"]
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if !skuPattern.MatchString(sku) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Некорректный SKU: "+sku)
+		http.Error(w, "Некорректный SKU", http.StatusBadRequest)
+		return
+	}
+
+	var input VariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, err, startTime, "Некорректное тело запроса")
+		http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+		return
+	}
+	if input.Price != nil && (*input.Price == 0 || *input.Price > 1000) {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusBadRequest, nil, startTime, "Цена варианта должна быть в диапазоне от 1 до 1000 включительно")
+		http.Error(w, "Цена варианта должна быть в диапазоне от 1 до 1000 включительно", http.StatusBadRequest)
+		return
+	}
+
+	tx := migrations.DB.WithContext(ctx).Begin()
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	var merch models.Merch
+	if err := tx.Where("name = ?", itemName).First(&merch).Error; err != nil {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusNotFound, err, startTime, "Мерч "+itemName+" не найден")
+		http.Error(w, "Мерч не найден", http.StatusNotFound)
+		return
+	}
+
+	// По SKU и названию покупают через один и тот же путь /api/buy/{item}, поэтому они не должны пересекаться.
+	var sameName int64
+	if err := tx.Model(&models.Merch{}).Where("name = ?", sku).Count(&sameName).Error; err != nil || sameName > 0 {
+		loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, err, startTime, "SKU совпадает с названием мерча: "+sku)
+		http.Error(w, "SKU совпадает с названием мерча", http.StatusConflict)
+		return
+	}
+
+	var variant models.MerchVariant
+	var before *models.MerchVariant
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sku = ?", sku).First(&variant).Error
+	switch {
+	case err == nil:
+		if variant.MerchID != merch.ID {
+			loging.LogRequest(logrus.WarnLevel, userID, r, http.StatusConflict, nil, startTime, "SKU "+sku+" занят другим мерчем")
+			http.Error(w, "SKU занят другим мерчем", http.StatusConflict)
+			return
+		}
+		previous := variant
+		before = &previous
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		variant = models.MerchVariant{MerchID: merch.ID, SKU: sku}
+	default:
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка поиска варианта")
+		http.Error(w, "Ошибка сохранения варианта", http.StatusInternalServerError)
+		return
+	}
+
+	variant.Attributes = input.Attributes
+	variant.Price = input.Price
+	variant.Stock = input.Stock
+	if err := tx.Save(&variant).Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка сохранения варианта")
+		http.Error(w, "Ошибка сохранения варианта", http.StatusInternalServerError)
+		return
+	}
+
+	err = audit.Write(tx, userID, audit.Event{
+		Action:     models.AUDIT_MERCH_VARIANT,
+		TargetType: "merch_variant",
+		TargetID:   variant.ID.String(),
+		Before:     before,
+		After:      variant,
+		Details:    merch.Name,
+	})
+	if err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка записи в журнал аудита")
+		http.Error(w, "Ошибка сохранения варианта", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		loging.LogRequest(logrus.ErrorLevel, userID, r, http.StatusInternalServerError, err, startTime, "Ошибка фиксации транзакции")
+		http.Error(w, "Ошибка фиксации транзакции", http.StatusInternalServerError)
+		return
+	}
+	committed = true
+	invalidateMerchCache(r.Context())
+
+	utils.JSONFormat(w, r, variant)
+	loging.LogRequest(logrus.InfoLevel, userID, r, http.StatusOK, nil, startTime, "Вариант "+sku+" мерча "+merch.Name+" сохранен")
+}
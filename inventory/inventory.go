@@ -0,0 +1,152 @@
+package inventory
+
+import (
+	"Shop/database/models"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrItemNotFound    = errors.New("item not found")
+	ErrVariantRequired = errors.New("merch has variants, sku required")
+	ErrSoldOut         = errors.New("item sold out")
+)
+
+// Item товар, который можно купить: мерч без вариантов или конкретный вариант.
+type Item struct {
+	Merch   models.Merch
+	Variant *models.MerchVariant
+}
+
+// Name SKU варианта или название мерча.
+func (i Item) Name() string {
+	if i.Variant != nil {
+		return i.Variant.SKU
+	}
+	return i.Merch.Name
+}
+
+// Price цена одной штуки.
+func (i Item) Price() uint {
+	if i.Variant != nil {
+		return i.Variant.EffectivePrice(i.Merch)
+	}
+	return i.Merch.Price
+}
+
+// Stock остаток; nil — запас не ограничен.
+func (i Item) Stock() *uint {
+	if i.Variant != nil {
+		return i.Variant.Stock
+	}
+	return i.Merch.Stock
+}
+
+// VariantID идентификатор варианта или nil.
+func (i Item) VariantID() *uuid.UUID {
+	if i.Variant == nil {
+		return nil
+	}
+	return &i.Variant.ID
+}
+
+// LowStock опустился ли учитываемый остаток до порога мерча.
+func (i Item) LowStock() bool {
+	stock := i.Stock()
+	return stock != nil && *stock <= i.Merch.LowStockThreshold
+}
+
+// Lookup находит товар по SKU варианта или по названию мерча и блокирует строку с остатком
+// до конца транзакции. Мерч с вариантами по названию купить нельзя — нужен SKU: тогда вместе
+// с ErrVariantRequired возвращается сам мерч без варианта.
+//
+// Порядок блокировок: сначала строки товаров (Lookup, Take, Return), затем кошелек. Так блокирует
+// оформление заказа, и все операции, меняющие и остаток, и кошелек, должны следовать ему, иначе
+// параллельные покупка и отмена ловят взаимную блокировку.
+func Lookup(tx *gorm.DB, name string) (Item, error) {
+	return find(tx, name, true)
+}
+
+// Find как Lookup, но без блокировки: для проверок, после которых остаток не меняется.
+func Find(db *gorm.DB, name string) (Item, error) {
+	return find(db, name, false)
+}
+
+func find(tx *gorm.DB, name string, forUpdate bool) (Item, error) {
+	// Каждый запрос строится от tx заново, чтобы условия одного не попали в другой.
+	locked := func() *gorm.DB {
+		if forUpdate {
+			return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		return tx
+	}
+
+	var variant models.MerchVariant
+	err := locked().Where("sku = ?", name).First(&variant).Error
+	switch {
+	case err == nil:
+		var merch models.Merch
+		if err := tx.Where("id = ?", variant.MerchID).First(&merch).Error; err != nil {
+			return Item{}, err
+		}
+		return Item{Merch: merch, Variant: &variant}, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return Item{}, err
+	}
+
+	var merch models.Merch
+	if err := locked().Where("name = ?", name).First(&merch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Item{}, ErrItemNotFound
+		}
+		return Item{}, err
+	}
+
+	var variants int64
+	if err := tx.Model(&models.MerchVariant{}).Where("merch_id = ?", merch.ID).Count(&variants).Error; err != nil {
+		return Item{}, err
+	}
+	if variants > 0 {
+		return Item{Merch: merch}, ErrVariantRequired
+	}
+	return Item{Merch: merch}, nil
+}
+
+// Take списывает quantity штук с остатка товара, найденного через Lookup. Для товара без учета
+// остатка ничего не делает. Возвращает ErrSoldOut, если остатка не хватает.
+func Take(tx *gorm.DB, item *Item, quantity uint) error {
+	stock := item.Stock()
+	if stock == nil {
+		return nil
+	}
+	if *stock < quantity {
+		return ErrSoldOut
+	}
+
+	var model interface{} = &models.Merch{}
+	id := item.Merch.ID
+	if item.Variant != nil {
+		model = &models.MerchVariant{}
+		id = item.Variant.ID
+	}
+	if err := tx.Model(model).Where("id = ?", id).Update("stock", gorm.Expr("stock - ?", quantity)).Error; err != nil {
+		return err
+	}
+	*stock -= quantity
+	return nil
+}
+
+// Return возвращает quantity штук на остаток варианта или мерча, например при отмене заказа.
+// Если остаток не учитывается, ничего не меняется.
+func Return(tx *gorm.DB, merchID uuid.UUID, variantID *uuid.UUID, quantity uint) (bool, error) {
+	var model interface{} = &models.Merch{}
+	id := merchID
+	if variantID != nil {
+		model = &models.MerchVariant{}
+		id = *variantID
+	}
+	result := tx.Model(model).Where("id = ? AND stock IS NOT NULL", id).Update("stock", gorm.Expr("stock + ?", quantity))
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,267 @@
+package ledger
+
+import (
+	"Shop/config"
+	"Shop/database/models"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("недостаточно монет на балансе")
+	ErrWalletNotFound    = errors.New("кошелек не найден")
+	ErrUnbalancedEntry   = errors.New("сумма проводок записи не равна нулю")
+	ErrInvalidAmount     = errors.New("сумма перевода должна быть больше 0")
+	ErrBalanceLimit      = errors.New("баланс получателя превысил бы максимально допустимый")
+)
+
+// cappedKinds записи, после которых баланс получателя не может превысить MAX_WALLET_BALANCE.
+// Стартовый баланс, возвраты и отмены возвращают сотруднику его монеты и не ограничиваются.
+var cappedKinds = map[string]bool{
+	models.ENTRY_TRANSFER: true,
+	models.ENTRY_MINT:     true,
+}
+
+// Line одна сторона будущей проводки.
+type Line struct {
+	Account models.LedgerAccount
+	Amount  int64
+}
+
+// SystemAccount возвращает системный счет по коду, создавая его при первом обращении.
+func SystemAccount(tx *gorm.DB, code string) (models.LedgerAccount, error) {
+	account := models.LedgerAccount{Code: code, Type: models.LEDGER_ACCOUNT_SYSTEM}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return account, err
+	}
+	if err := tx.Where("code = ?", code).First(&account).Error; err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// UserAccount возвращает счет сотрудника. Если счета еще нет, а кошелек уже существует
+// (например, создан до появления журнала), остаток кошелька фиксируется записью OPENING,
+// чтобы проекция и журнал сходились.
+func UserAccount(tx *gorm.DB, userID uuid.UUID) (models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	if err := tx.Where("user_id = ?", userID).First(&account).Error; err == nil {
+		return account, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account, err
+	}
+
+	account = models.LedgerAccount{
+		Code:   "user:" + userID.String(),
+		Type:   models.LEDGER_ACCOUNT_USER,
+		UserID: &userID,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account)
+	if result.Error != nil {
+		return account, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Счет параллельно создал другой запрос, он же зафиксировал остаток.
+		err := tx.Where("user_id = ?", userID).First(&account).Error
+		return account, err
+	}
+
+	var wallet models.Wallet
+	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return account, nil
+		}
+		return account, err
+	}
+	if wallet.Coin == 0 {
+		return account, nil
+	}
+
+	opening, err := SystemAccount(tx, models.SYSTEM_ACCOUNT_OPENING)
+	if err != nil {
+		return account, err
+	}
+	_, err = post(tx, false, false, models.ENTRY_OPENING, wallet.ID.String(),
+		Line{Account: opening, Amount: -int64(wallet.Coin)},
+		Line{Account: account, Amount: int64(wallet.Coin)},
+	)
+	return account, err
+}
+
+// OpenWallet создает кошелек сотрудника с нулевым остатком и зачисляет стартовый баланс
+// проводкой со счета эмиссии.
+func OpenWallet(tx *gorm.DB, userID uuid.UUID, startBalance uint) (models.Wallet, error) {
+	var wallet models.Wallet
+	// Создание через map: у поля Coin есть default, и нулевое значение структуры было бы заменено на него.
+	if err := tx.Model(&models.Wallet{}).Create(map[string]interface{}{"user_id": userID, "coin": 0}).Error; err != nil {
+		return wallet, err
+	}
+
+	account, err := UserAccount(tx, userID)
+	if err != nil {
+		return wallet, err
+	}
+
+	if startBalance > 0 {
+		mint, err := SystemAccount(tx, models.SYSTEM_ACCOUNT_MINT)
+		if err != nil {
+			return wallet, err
+		}
+		if _, err := Transfer(tx, mint, account, startBalance, models.ENTRY_OPENING, userID.String()); err != nil {
+			return wallet, err
+		}
+	}
+
+	err = tx.Where("user_id = ?", userID).First(&wallet).Error
+	return wallet, err
+}
+
+// Transfer записывает перевод amount монет со счета from на счет to.
+func Transfer(tx *gorm.DB, from, to models.LedgerAccount, amount uint, kind, reference string) (models.JournalEntry, error) {
+	if amount == 0 {
+		return models.JournalEntry{}, ErrInvalidAmount
+	}
+	return Post(tx, kind, reference,
+		Line{Account: from, Amount: -int64(amount)},
+		Line{Account: to, Amount: int64(amount)},
+	)
+}
+
+// Post записывает сбалансированную запись журнала и обновляет кошельки сотрудников,
+// чьи счета в ней участвуют, и их партии монет. Должна вызываться внутри транзакции.
+func Post(tx *gorm.DB, kind, reference string, lines ...Line) (models.JournalEntry, error) {
+	return post(tx, true, true, kind, reference, lines...)
+}
+
+func post(tx *gorm.DB, applyToWallets, applyToLots bool, kind, reference string, lines ...Line) (models.JournalEntry, error) {
+	entry := models.JournalEntry{Kind: kind, Reference: reference}
+
+	if len(lines) < 2 {
+		return entry, ErrUnbalancedEntry
+	}
+	var sum int64
+	for _, line := range lines {
+		sum += line.Amount
+	}
+	if sum != 0 {
+		return entry, ErrUnbalancedEntry
+	}
+
+	for _, line := range lines {
+		entry.Postings = append(entry.Postings, models.Posting{AccountID: line.Account.ID, Amount: line.Amount})
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+
+	if !applyToWallets {
+		return entry, nil
+	}
+
+	limit := config.MaxWalletBalance()
+	for _, line := range lines {
+		if line.Account.Type != models.LEDGER_ACCOUNT_USER || line.Account.UserID == nil {
+			continue
+		}
+		// Условный UPDATE: баланс не может уйти в минус или выше предела даже при гонке запросов.
+		update := tx.Model(&models.Wallet{}).
+			Where("user_id = ? AND coin + ? >= 0", *line.Account.UserID, line.Amount)
+		if limit > 0 && line.Amount > 0 && cappedKinds[kind] {
+			update = update.Where("coin + ? <= ?", line.Amount, limit)
+		}
+		result := update.UpdateColumn("coin", gorm.Expr("coin + ?", line.Amount))
+		if result.Error != nil {
+			return entry, result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&models.Wallet{}).Where("user_id = ?", *line.Account.UserID).Count(&count).Error; err != nil {
+				return entry, err
+			}
+			if count == 0 {
+				return entry, ErrWalletNotFound
+			}
+			if line.Amount > 0 {
+				return entry, ErrBalanceLimit
+			}
+			return entry, ErrInsufficientFunds
+		}
+	}
+
+	if !applyToLots {
+		return entry, nil
+	}
+	return entry, trackLots(tx, entry, lines)
+}
+
+// Correct записывает корректирующую запись: amount монет на счет сотрудника (отрицательная
+// сумма — списание) со встречного счета корректировок. Кошелек не меняется: запись нужна, когда
+// остаток кошелька признан верным, а журнал — нет.
+func Correct(tx *gorm.DB, account models.LedgerAccount, amount int64, reference string) (models.JournalEntry, error) {
+	if amount == 0 {
+		return models.JournalEntry{}, ErrInvalidAmount
+	}
+	corrections, err := SystemAccount(tx, models.SYSTEM_ACCOUNT_CORRECTIONS)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	return post(tx, false, false, models.ENTRY_CORRECTION, reference,
+		Line{Account: corrections, Amount: -amount},
+		Line{Account: account, Amount: amount},
+	)
+}
+
+// Balance считает остаток счета по проводкам.
+func Balance(tx *gorm.DB, accountID uuid.UUID) (int64, error) {
+	var balance int64
+	err := tx.Model(&models.Posting{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("account_id = ?", accountID).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// Mismatch расхождение между кошельком и журналом.
+type Mismatch struct {
+	UserID        uuid.UUID `json:"userId"`
+	WalletCoin    int64     `json:"walletCoin"`
+	LedgerBalance int64     `json:"ledgerBalance"`
+}
+
+// Report результат проверки журнала.
+type Report struct {
+	Balanced          bool       `json:"balanced"`
+	UnbalancedEntries []string   `json:"unbalancedEntries"`
+	Mismatches        []Mismatch `json:"mismatches"`
+}
+
+// Verify проверяет, что каждая запись журнала сбалансирована, а остаток каждого кошелька
+// совпадает с суммой проводок по счету сотрудника. У кошелька без счета остаток по журналу
+// нулевой: ненулевой кошелек без счета — тоже расхождение.
+func Verify(tx *gorm.DB) (Report, error) {
+	report := Report{UnbalancedEntries: []string{}, Mismatches: []Mismatch{}}
+
+	if err := tx.Model(&models.Posting{}).
+		Select("entry_id").
+		Group("entry_id").
+		Having("SUM(amount) <> 0").
+		Scan(&report.UnbalancedEntries).Error; err != nil {
+		return report, fmt.Errorf("проверка записей журнала: %w", err)
+	}
+
+	if err := tx.Table("wallets").
+		Select("wallets.user_id, wallets.coin AS wallet_coin, COALESCE(SUM(postings.amount), 0) AS ledger_balance").
+		Joins("LEFT JOIN ledger_accounts ON ledger_accounts.user_id = wallets.user_id").
+		Joins("LEFT JOIN postings ON postings.account_id = ledger_accounts.id").
+		Group("wallets.user_id, wallets.coin").
+		Having("wallets.coin <> COALESCE(SUM(postings.amount), 0)").
+		Scan(&report.Mismatches).Error; err != nil {
+		return report, fmt.Errorf("сверка кошельков с журналом: %w", err)
+	}
+
+	report.Balanced = len(report.UnbalancedEntries) == 0 && len(report.Mismatches) == 0
+	return report, nil
+}
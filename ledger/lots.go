@@ -0,0 +1,242 @@
+package ledger
+
+import (
+	"Shop/config"
+	"Shop/database/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// portion монеты одной партии, ушедшие с кошелька при списании.
+type portion struct {
+	amount    uint
+	grantedAt time.Time
+	expiresAt *time.Time
+}
+
+// ExpiryFor до какого момента действуют монеты, начисленные в now: начало дня по UTC через
+// COIN_EXPIRY_MONTHS месяцев. nil — монеты не сгорают.
+func ExpiryFor(now time.Time) *time.Time {
+	months := config.CoinExpiryMonths()
+	if months == 0 {
+		return nil
+	}
+	now = now.UTC()
+	expiresAt := time.Date(now.Year(), now.Month()+time.Month(months), now.Day(), 0, 0, 0, 0, time.UTC)
+	return &expiresAt
+}
+
+// lockLots блокирует непотраченные партии сотрудника в порядке, в котором они тратятся.
+func lockLots(tx *gorm.DB, userID uuid.UUID) ([]models.CoinLot, error) {
+	var lots []models.CoinLot
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND remaining > 0", userID).
+		Order("expires_at NULLS LAST, granted_at, id").
+		Find(&lots).Error
+	return lots, err
+}
+
+// syncLots блокирует партии сотрудника и выравнивает их с балансом кошелька balance: монеты,
+// которых нет в партиях (баланс до учета партий, исправление сверки), становятся партией без
+// срока, а лишние остатки партий убираются по порядку трат.
+func syncLots(tx *gorm.DB, userID uuid.UUID, balance uint, now time.Time) ([]models.CoinLot, error) {
+	lots, err := lockLots(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var sum uint
+	for _, lot := range lots {
+		sum += lot.Remaining
+	}
+	switch {
+	case sum < balance:
+		lot := models.CoinLot{
+			UserID:    userID,
+			Amount:    balance - sum,
+			Remaining: balance - sum,
+			Source:    models.LOT_SOURCE_BALANCE,
+			GrantedAt: now,
+		}
+		if err := tx.Create(&lot).Error; err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	case sum > balance:
+		if _, err := consumeLots(tx, lots, sum-balance); err != nil {
+			return nil, err
+		}
+	}
+	return lots, nil
+}
+
+// SyncLots выравнивает партии сотрудника с текущим балансом кошелька. Нужна, когда баланс
+// изменен в обход Post (исправление сверки): иначе до следующего движения по кошельку
+// сгорающие монеты считались бы по старым партиям. Кошелек вызывающий блокирует заранее.
+func SyncLots(tx *gorm.DB, userID uuid.UUID) error {
+	var wallet models.Wallet
+	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		return err
+	}
+	_, err := syncLots(tx, userID, wallet.Coin, time.Now())
+	return err
+}
+
+// consumeLots тратит amount монет из партий lots по порядку и возвращает, из каких партий они ушли.
+func consumeLots(tx *gorm.DB, lots []models.CoinLot, amount uint) ([]portion, error) {
+	var portions []portion
+	for i := range lots {
+		if amount == 0 {
+			break
+		}
+		if lots[i].Remaining == 0 {
+			continue
+		}
+		take := min(lots[i].Remaining, amount)
+		lots[i].Remaining -= take
+		amount -= take
+		if err := tx.Model(&lots[i]).UpdateColumn("remaining", lots[i].Remaining).Error; err != nil {
+			return nil, err
+		}
+		portions = append(portions, portion{amount: take, grantedAt: lots[i].GrantedAt, expiresAt: lots[i].ExpiresAt})
+	}
+	if amount > 0 {
+		return nil, ErrInsufficientFunds
+	}
+	return portions, nil
+}
+
+// creditLots зачисляет amount монет новыми партиями. Сначала берутся монеты carried со своими
+// сроками (перевод от другого сотрудника), остальные получают срок от now.
+func creditLots(tx *gorm.DB, userID uuid.UUID, entry models.JournalEntry, amount uint, carried *[]portion, now time.Time) error {
+	var lots []models.CoinLot
+	for amount > 0 && len(*carried) > 0 {
+		next := &(*carried)[0]
+		take := min(next.amount, amount)
+		lots = append(lots, models.CoinLot{
+			UserID: userID, Amount: take, Remaining: take, Source: entry.Kind, EntryID: &entry.ID,
+			GrantedAt: next.grantedAt, ExpiresAt: next.expiresAt,
+		})
+		next.amount -= take
+		amount -= take
+		if next.amount == 0 {
+			*carried = (*carried)[1:]
+		}
+	}
+	if amount > 0 {
+		lots = append(lots, models.CoinLot{
+			UserID: userID, Amount: amount, Remaining: amount, Source: entry.Kind, EntryID: &entry.ID,
+			GrantedAt: now, ExpiresAt: ExpiryFor(now),
+		})
+	}
+	return tx.Create(&lots).Error
+}
+
+// trackLots ведет партии кошельков, которые изменила запись entry. Кошельки уже обновлены
+// и заблокированы. Списания тратят партии по порядку, зачисления создают новые; в переводе
+// между сотрудниками монеты переходят к получателю вместе со сроками.
+func trackLots(tx *gorm.DB, entry models.JournalEntry, lines []Line) error {
+	now := time.Now()
+	changes := map[uuid.UUID]int64{}
+	var userIDs []uuid.UUID
+	for _, line := range lines {
+		if line.Account.Type != models.LEDGER_ACCOUNT_USER || line.Account.UserID == nil {
+			continue
+		}
+		if _, ok := changes[*line.Account.UserID]; !ok {
+			userIDs = append(userIDs, *line.Account.UserID)
+		}
+		changes[*line.Account.UserID] += line.Amount
+	}
+
+	var wallets []models.Wallet
+	if err := tx.Where("user_id IN ?", userIDs).Find(&wallets).Error; err != nil {
+		return err
+	}
+	balances := make(map[uuid.UUID]int64, len(wallets))
+	for _, wallet := range wallets {
+		balances[wallet.UserID] = int64(wallet.Coin)
+	}
+
+	var carried []portion
+	for _, userID := range userIDs {
+		change := changes[userID]
+		if change >= 0 {
+			continue
+		}
+		lots, err := syncLots(tx, userID, uint(balances[userID]-change), now)
+		if err != nil {
+			return err
+		}
+		portions, err := consumeLots(tx, lots, uint(-change))
+		if err != nil {
+			return err
+		}
+		if entry.Kind == models.ENTRY_TRANSFER {
+			carried = append(carried, portions...)
+		}
+	}
+	for _, userID := range userIDs {
+		change := changes[userID]
+		if change <= 0 {
+			continue
+		}
+		if _, err := syncLots(tx, userID, uint(balances[userID]-change), now); err != nil {
+			return err
+		}
+		if err := creditLots(tx, userID, entry, uint(change), &carried, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpiredLots партии сотрудника, срок которых истек к now, заблокированные до конца транзакции.
+// Кошелек сотрудника вызывающий блокирует заранее.
+func ExpiredLots(tx *gorm.DB, userID uuid.UUID, now time.Time) ([]models.CoinLot, error) {
+	var wallet models.Wallet
+	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	lots, err := syncLots(tx, userID, wallet.Coin, now)
+	if err != nil {
+		return nil, err
+	}
+	var expired []models.CoinLot
+	for _, lot := range lots {
+		if lot.Remaining > 0 && lot.ExpiresAt != nil && !lot.ExpiresAt.After(now) {
+			expired = append(expired, lot)
+		}
+	}
+	return expired, nil
+}
+
+// Expire списывает остатки партий lots (из ExpiredLots) на счет сгоревших монет записью EXPIRY
+// со ссылкой reference.
+func Expire(tx *gorm.DB, userID uuid.UUID, lots []models.CoinLot, reference string) (models.JournalEntry, error) {
+	var amount uint
+	ids := make([]uuid.UUID, 0, len(lots))
+	for _, lot := range lots {
+		amount += lot.Remaining
+		ids = append(ids, lot.ID)
+	}
+	if amount == 0 {
+		return models.JournalEntry{}, ErrInvalidAmount
+	}
+	account, err := UserAccount(tx, userID)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	expired, err := SystemAccount(tx, models.SYSTEM_ACCOUNT_EXPIRED)
+	if err != nil {
+		return models.JournalEntry{}, err
+	}
+	if err := tx.Model(&models.CoinLot{}).Where("id IN ?", ids).UpdateColumn("remaining", 0).Error; err != nil {
+		return models.JournalEntry{}, err
+	}
+	return post(tx, true, false, models.ENTRY_EXPIRY, reference,
+		Line{Account: account, Amount: -int64(amount)},
+		Line{Account: expired, Amount: int64(amount)},
+	)
+}
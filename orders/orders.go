@@ -0,0 +1,144 @@
+package orders
+
+import (
+	"Shop/audit"
+	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/ledger"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("заказ не найден")
+	ErrInvalidTransition = errors.New("недопустимый переход статуса заказа")
+	ErrWrongPickupCode   = errors.New("неверный код выдачи")
+	ErrReasonRequired    = errors.New("нужно указать причину отмены")
+)
+
+const pickupCodeDigits = 6
+
+// NewPickupCode случайный цифровой код, который сотрудник называет при получении заказа.
+func NewPickupCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < pickupCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", pickupCodeDigits, n), nil
+}
+
+// Lock загружает заказ со строками и блокирует его до конца транзакции, чтобы два перехода
+// статуса (например, выдача и отмена) не выполнились одновременно.
+func Lock(tx *gorm.DB, id uuid.UUID) (models.Order, error) {
+	var order models.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return order, ErrOrderNotFound
+	}
+	if err != nil {
+		return order, err
+	}
+	err = tx.Where("order_id = ?", order.ID).Order("item").Find(&order.Items).Error
+	return order, err
+}
+
+// CanTransition разрешен ли переход из статуса from в статус to.
+func CanTransition(from, to string) bool {
+	for _, next := range models.OrderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Advance переводит заблокированный через Lock заказ в статус status и пишет событие аудита.
+// Выдача требует код выдачи. Отмена требует причину, возвращает монеты на кошелек проводкой
+// REFUND и товары на остаток — все в транзакции tx. Возвращает true, если изменились остатки.
+func Advance(tx *gorm.DB, actorID uuid.UUID, order *models.Order, status, pickupCode, reason string) (bool, error) {
+	if !CanTransition(order.Status, status) {
+		return false, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	stockChanged := false
+	switch status {
+	case models.ORDER_STATUS_FULFILLED:
+		if subtle.ConstantTimeCompare([]byte(pickupCode), []byte(order.PickupCode)) != 1 {
+			return false, ErrWrongPickupCode
+		}
+		updates["fulfilled_at"] = now
+		order.FulfilledAt = &now
+	case models.ORDER_STATUS_CANCELLED:
+		if reason == "" {
+			return false, ErrReasonRequired
+		}
+		changed, err := refund(tx, order)
+		if err != nil {
+			return false, err
+		}
+		stockChanged = changed
+		updates["cancelled_at"] = now
+		updates["cancel_reason"] = reason
+		order.CancelledAt = &now
+		order.CancelReason = reason
+	}
+
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	previous := order.Status
+	order.Status = status
+
+	err := audit.Write(tx, actorID, audit.Event{
+		Action:     models.AUDIT_ORDER_STATUS,
+		TargetType: "order",
+		TargetID:   order.ID.String(),
+		Before:     map[string]string{"status": previous},
+		After:      map[string]string{"status": status},
+		Details:    reason,
+	})
+	if err != nil {
+		return false, err
+	}
+	return stockChanged, nil
+}
+
+func refund(tx *gorm.DB, order *models.Order) (bool, error) {
+	// Остатки возвращаются до проводки, порядок блокировок — см. inventory.Lookup.
+	stockChanged := false
+	for _, item := range order.Items {
+		changed, err := inventory.Return(tx, item.MerchID, item.VariantID, item.Quantity)
+		if err != nil {
+			return false, err
+		}
+		stockChanged = stockChanged || changed
+	}
+
+	if order.Total > 0 {
+		account, err := ledger.UserAccount(tx, order.UserID)
+		if err != nil {
+			return false, err
+		}
+		revenue, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_REVENUE)
+		if err != nil {
+			return false, err
+		}
+		if _, err := ledger.Transfer(tx, revenue, account, order.Total, models.ENTRY_REFUND, order.ID.String()); err != nil {
+			return false, err
+		}
+	}
+	return stockChanged, nil
+}
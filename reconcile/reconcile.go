@@ -0,0 +1,302 @@
+package reconcile
+
+import (
+	"Shop/audit"
+	"Shop/database/models"
+	"Shop/ledger"
+	"Shop/loging"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMismatchNotFound  = errors.New("расхождение не найдено")
+	ErrNotOpen           = errors.New("расхождение уже закрыто")
+	ErrStale             = errors.New("остаток кошелька или журнал изменились после сверки")
+	ErrInvalidResolution = errors.New("неизвестный способ исправления")
+	ErrReasonRequired    = errors.New("нужно указать причину")
+	ErrNegativeBalance   = errors.New("остаток по истории отрицательный")
+)
+
+// Run сверяет остаток каждого кошелька с историей операций — суммой проводок по счету
+// сотрудника — и сохраняет запуск вместе с найденными расхождениями. Открытые расхождения
+// прошлых запусков помечаются устаревшими: актуальна только последняя сверка.
+func Run(db *gorm.DB, trigger string, actorID *uuid.UUID) (models.ReconciliationRun, []models.BalanceMismatch, error) {
+	run := models.ReconciliationRun{Trigger: trigger, ActorID: actorID, StartedAt: time.Now()}
+
+	report, err := ledger.Verify(db)
+	if err != nil {
+		return run, nil, err
+	}
+	if err := db.Table("wallets").Count(&run.Wallets).Error; err != nil {
+		return run, nil, fmt.Errorf("подсчет кошельков: %w", err)
+	}
+
+	mismatches := make([]models.BalanceMismatch, 0, len(report.Mismatches))
+	for _, found := range report.Mismatches {
+		breakdown, err := Breakdown(db, found.UserID)
+		if err != nil {
+			return run, nil, err
+		}
+		mismatches = append(mismatches, models.BalanceMismatch{
+			UserID:          found.UserID,
+			WalletCoin:      found.WalletCoin,
+			ExpectedBalance: found.LedgerBalance,
+			Difference:      found.WalletCoin - found.LedgerBalance,
+			Breakdown:       breakdown,
+			Status:          models.MISMATCH_STATUS_OPEN,
+		})
+	}
+	run.Mismatches = len(mismatches)
+	run.UnbalancedEntries = report.UnbalancedEntries
+	run.FinishedAt = time.Now()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.BalanceMismatch{}).
+			Where("status = ?", models.MISMATCH_STATUS_OPEN).
+			Update("status", models.MISMATCH_STATUS_SUPERSEDED).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		for i := range mismatches {
+			mismatches[i].RunID = run.ID
+		}
+		if len(mismatches) == 0 {
+			return nil
+		}
+		return tx.Create(&mismatches).Error
+	})
+	return run, mismatches, err
+}
+
+// Breakdown из чего сложился остаток сотрудника по истории: суммы проводок по видам операций.
+// Переводы разделены на полученные и отправленные.
+func Breakdown(db *gorm.DB, userID uuid.UUID) (map[string]int64, error) {
+	var rows []struct {
+		Kind     string
+		Incoming bool
+		Amount   int64
+	}
+	err := db.Table("postings").
+		Select("journal_entries.kind, postings.amount > 0 AS incoming, SUM(postings.amount) AS amount").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("ledger_accounts.user_id = ?", userID).
+		Group("journal_entries.kind, postings.amount > 0").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("история операций сотрудника %s: %w", userID, err)
+	}
+
+	breakdown := map[string]int64{}
+	for _, row := range rows {
+		breakdown[breakdownKey(row.Kind, row.Incoming)] += row.Amount
+	}
+	return breakdown, nil
+}
+
+func breakdownKey(kind string, incoming bool) string {
+	switch kind {
+	case models.ENTRY_OPENING:
+		return "startingBalance"
+	case models.ENTRY_MINT:
+		return "topUps"
+	case models.ENTRY_TRANSFER:
+		if incoming {
+			return "received"
+		}
+		return "sent"
+	case models.ENTRY_PURCHASE, models.ENTRY_ORDER:
+		return "purchases"
+	case models.ENTRY_REFUND:
+		return "refunds"
+	case models.ENTRY_REVERSAL:
+		return "reversals"
+	case models.ENTRY_CORRECTION:
+		return "corrections"
+	case models.ENTRY_EXPIRY:
+		return "expired"
+	}
+	return strings.ToLower(kind)
+}
+
+// Resolve закрывает открытое расхождение по решению админа. resolution ledger признает остаток
+// кошелька и пишет в журнал корректирующую запись на разницу, resolution wallet возвращает
+// кошельку остаток по истории. Если с момента сверки кошелек или журнал изменились,
+// возвращается ErrStale: расхождение нужно пересчитать новой сверкой.
+func Resolve(tx *gorm.DB, adminID, mismatchID uuid.UUID, resolution, reason string) (models.BalanceMismatch, error) {
+	var mismatch models.BalanceMismatch
+	if reason == "" {
+		return mismatch, ErrReasonRequired
+	}
+	if resolution != models.MISMATCH_RESOLUTION_LEDGER && resolution != models.MISMATCH_RESOLUTION_WALLET {
+		return mismatch, ErrInvalidResolution
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", mismatchID).First(&mismatch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return mismatch, ErrMismatchNotFound
+	}
+	if err != nil {
+		return mismatch, err
+	}
+	if mismatch.Status != models.MISMATCH_STATUS_OPEN {
+		return mismatch, ErrNotOpen
+	}
+
+	var wallet models.Wallet
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", mismatch.UserID).First(&wallet).Error; err != nil {
+		return mismatch, err
+	}
+	// Счет не создается заранее: ledger.UserAccount зафиксировал бы остаток кошелька записью
+	// OPENING, и расхождение исчезло бы без решения админа. Без счета остаток по журналу нулевой.
+	var account models.LedgerAccount
+	hasAccount := true
+	if err := tx.Where("user_id = ?", mismatch.UserID).First(&account).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		hasAccount = false
+	} else if err != nil {
+		return mismatch, err
+	}
+	expected := int64(0)
+	if hasAccount {
+		if expected, err = ledger.Balance(tx, account.ID); err != nil {
+			return mismatch, err
+		}
+	}
+	if int64(wallet.Coin)-expected != mismatch.Difference {
+		return mismatch, ErrStale
+	}
+
+	before := map[string]interface{}{"coin": wallet.Coin, "expectedBalance": expected}
+	after := map[string]interface{}{}
+	switch resolution {
+	case models.MISMATCH_RESOLUTION_LEDGER:
+		entry, err := correctLedger(tx, account, hasAccount, wallet, mismatch)
+		if err != nil {
+			return mismatch, err
+		}
+		mismatch.EntryID = &entry.ID
+		after["coin"], after["expectedBalance"] = wallet.Coin, int64(wallet.Coin)
+	case models.MISMATCH_RESOLUTION_WALLET:
+		if expected < 0 {
+			return mismatch, ErrNegativeBalance
+		}
+		if err := tx.Model(&models.Wallet{}).Where("user_id = ?", wallet.UserID).UpdateColumn("coin", expected).Error; err != nil {
+			return mismatch, err
+		}
+		after["coin"], after["expectedBalance"] = expected, expected
+	}
+	if err := ledger.SyncLots(tx, wallet.UserID); err != nil {
+		return mismatch, err
+	}
+
+	now := time.Now()
+	mismatch.Status = models.MISMATCH_STATUS_RESOLVED
+	mismatch.Resolution = resolution
+	mismatch.ResolvedBy = &adminID
+	mismatch.Reason = reason
+	mismatch.ResolvedAt = &now
+	if err := tx.Save(&mismatch).Error; err != nil {
+		return mismatch, err
+	}
+
+	err = audit.Write(tx, adminID, audit.Event{
+		Action:     models.AUDIT_BALANCE_CORRECTION,
+		TargetType: "user",
+		TargetID:   mismatch.UserID.String(),
+		Before:     before,
+		After:      after,
+		Details:    fmt.Sprintf("расхождение %s, способ %s: %s", mismatch.ID, resolution, reason),
+	})
+	return mismatch, err
+}
+
+// correctLedger признает остаток кошелька в журнале. Если счета у сотрудника нет, его открытие
+// само фиксирует остаток записью OPENING, она и становится исправлением.
+func correctLedger(tx *gorm.DB, account models.LedgerAccount, hasAccount bool, wallet models.Wallet, mismatch models.BalanceMismatch) (models.JournalEntry, error) {
+	if hasAccount {
+		return ledger.Correct(tx, account, mismatch.Difference, mismatch.ID.String())
+	}
+	var entry models.JournalEntry
+	if _, err := ledger.UserAccount(tx, mismatch.UserID); err != nil {
+		return entry, err
+	}
+	err := tx.Where("kind = ? AND reference = ?", models.ENTRY_OPENING, wallet.ID.String()).
+		Order("created_at DESC").
+		First(&entry).Error
+	return entry, err
+}
+
+// Schedule запускает сверку каждые interval, пока не отменен ctx. Ошибки и расхождения пишутся
+// в лог, закрывать расхождения по-прежнему должен админ.
+func Schedule(ctx context.Context, db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run, mismatches, ran, err := RunScheduled(db.WithContext(ctx), interval)
+			if err != nil {
+				loging.Log.WithError(err).Error("Ошибка плановой сверки кошельков")
+				continue
+			}
+			if ran {
+				LogRun(run, mismatches)
+			}
+		}
+	}
+}
+
+const scheduleLockKey = "reconcile:schedule"
+
+// RunScheduled выполняет плановую сверку, если за последние полинтервала ее не сделал другой
+// экземпляр сервера. Экземпляры договариваются advisory-блокировкой: пока один сверяет,
+// остальные пропускают тик, а после видят свежий запуск. Иначе каждый экземпляр помечал бы
+// расхождения устаревшими, и админ, закрывающий расхождение, получал бы ErrNotOpen.
+// ran = false, если сверка пропущена.
+func RunScheduled(db *gorm.DB, interval time.Duration) (run models.ReconciliationRun, mismatches []models.BalanceMismatch, ran bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", scheduleLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		var recent int64
+		if err := tx.Model(&models.ReconciliationRun{}).
+			Where("trigger = ? AND started_at > ?", models.RECONCILIATION_TRIGGER_SCHEDULE, time.Now().Add(-interval/2)).
+			Count(&recent).Error; err != nil {
+			return fmt.Errorf("последняя плановая сверка: %w", err)
+		}
+		if recent > 0 {
+			return nil
+		}
+		ran = true
+		run, mismatches, err = Run(tx, models.RECONCILIATION_TRIGGER_SCHEDULE, nil)
+		return err
+	})
+	return run, mismatches, ran, err
+}
+
+// LogRun пишет итог сверки в лог: каждое расхождение — предупреждением.
+func LogRun(run models.ReconciliationRun, mismatches []models.BalanceMismatch) {
+	for _, mismatch := range mismatches {
+		loging.Log.Warnf("Расхождение кошелька %s: в кошельке %d, по истории %d (%+v)",
+			mismatch.UserID, mismatch.WalletCoin, mismatch.ExpectedBalance, mismatch.Breakdown)
+	}
+	if len(run.UnbalancedEntries) > 0 {
+		loging.Log.Warnf("Несбалансированные записи журнала: %s", strings.Join(run.UnbalancedEntries, ", "))
+	}
+	loging.Log.Infof("Сверка кошельков завершена: проверено %d, расхождений %d", run.Wallets, run.Mismatches)
+}
@@ -0,0 +1,207 @@
+package refunds
+
+import (
+	"Shop/audit"
+	"Shop/database/models"
+	"Shop/inventory"
+	"Shop/ledger"
+	"Shop/treasury"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("запись для отмены не найдена")
+	ErrAlreadyReversed = errors.New("запись уже отменена")
+	ErrNoLedgerEntry   = errors.New("у записи нет проводки в журнале")
+	ErrReasonRequired  = errors.New("нужно указать причину")
+)
+
+// RefundPurchase возвращает покупку: монеты с выручки магазина на кошелек сотрудника, товар — на
+// остаток. Заказ покупки, если он есть, отменяется даже после выдачи: возврат означает, что товар
+// вернули. Возвращает компенсирующую запись и признак изменения остатков.
+func RefundPurchase(tx *gorm.DB, adminID, purchaseID uuid.UUID, reason string) (models.Reversal, bool, error) {
+	reversal := models.Reversal{Kind: models.REVERSAL_PURCHASE, TargetID: purchaseID, AdminID: adminID, Reason: reason}
+	if reason == "" {
+		return reversal, false, ErrReasonRequired
+	}
+
+	var purchase models.Purchase
+	if err := lock(tx).Where("id = ?", purchaseID).First(&purchase).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return reversal, false, ErrNotFound
+		}
+		return reversal, false, err
+	}
+	reversal.UserID = purchase.UserID
+	if err := ensureNotReversed(tx, reversal); err != nil {
+		return reversal, false, err
+	}
+
+	if purchase.OrderID != nil {
+		var order models.Order
+		if err := lock(tx).Where("id = ?", *purchase.OrderID).First(&order).Error; err != nil {
+			return reversal, false, err
+		}
+		if order.Status == models.ORDER_STATUS_CANCELLED {
+			return reversal, false, ErrAlreadyReversed
+		}
+		now := time.Now()
+		err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"status":        models.ORDER_STATUS_CANCELLED,
+			"cancelled_at":  now,
+			"cancel_reason": reason,
+		}).Error
+		if err != nil {
+			return reversal, false, err
+		}
+	}
+
+	paid, err := userPosting(tx, models.ENTRY_PURCHASE, purchase.ID.String(), purchase.UserID)
+	if err != nil {
+		return reversal, false, err
+	}
+
+	// Остаток возвращается до проводки, порядок блокировок — см. inventory.Lookup.
+	stockChanged, err := inventory.Return(tx, purchase.MerchID, purchase.VariantID, purchase.Quantity)
+	if err != nil {
+		return reversal, false, err
+	}
+	account, err := ledger.UserAccount(tx, purchase.UserID)
+	if err != nil {
+		return reversal, false, err
+	}
+	revenue, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_REVENUE)
+	if err != nil {
+		return reversal, false, err
+	}
+	entry, err := ledger.Transfer(tx, revenue, account, uint(-paid), models.ENTRY_REVERSAL, purchase.ID.String())
+	if err != nil {
+		return reversal, false, err
+	}
+
+	reversal.Amount = -paid
+	reversal.EntryID = entry.ID
+	if err := save(tx, &reversal, "purchase"); err != nil {
+		return reversal, false, err
+	}
+	return reversal, stockChanged, nil
+}
+
+// ReverseTopUp отменяет ошибочное начисление админа (запись журнала MINT): списывает те же монеты
+// с кошелька сотрудника обратно на счет эмиссии и показывает списание в его истории.
+// Если сотрудник уже потратил монеты, возвращается ledger.ErrInsufficientFunds.
+func ReverseTopUp(tx *gorm.DB, adminID, entryID uuid.UUID, reason string) (models.Reversal, error) {
+	reversal := models.Reversal{Kind: models.REVERSAL_TOP_UP, TargetID: entryID, AdminID: adminID, Reason: reason}
+	if reason == "" {
+		return reversal, ErrReasonRequired
+	}
+
+	var original models.JournalEntry
+	if err := lock(tx).Where("id = ? AND kind = ?", entryID, models.ENTRY_MINT).First(&original).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return reversal, ErrNotFound
+		}
+		return reversal, err
+	}
+
+	var credit struct {
+		UserID uuid.UUID
+		Amount int64
+	}
+	err := tx.Table("postings").
+		Select("ledger_accounts.user_id, postings.amount").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("postings.entry_id = ? AND ledger_accounts.type = ?", entryID, models.LEDGER_ACCOUNT_USER).
+		Scan(&credit).Error
+	if err != nil {
+		return reversal, err
+	}
+	if credit.Amount <= 0 {
+		return reversal, ErrNoLedgerEntry
+	}
+	reversal.UserID = credit.UserID
+	if err := ensureNotReversed(tx, reversal); err != nil {
+		return reversal, err
+	}
+
+	account, err := ledger.UserAccount(tx, credit.UserID)
+	if err != nil {
+		return reversal, err
+	}
+	mint, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_MINT)
+	if err != nil {
+		return reversal, err
+	}
+	entry, err := ledger.Transfer(tx, account, mint, uint(credit.Amount), models.ENTRY_REVERSAL, entryID.String())
+	if err != nil {
+		return reversal, err
+	}
+	// Начисление видно в истории сотрудника как перевод от казны, поэтому и отмена показывается
+	// там же — списанием в казну. Запись после проводки: кошелек уже заблокирован, порядок
+	// блокировок — см. treasury.LockWallets.
+	if _, err := treasury.Record(tx, models.TRANSACTION_KIND_DEBIT, adminID, credit.UserID, uint(credit.Amount), reason); err != nil {
+		return reversal, err
+	}
+
+	reversal.Amount = -credit.Amount
+	reversal.EntryID = entry.ID
+	if err := save(tx, &reversal, "journal_entry"); err != nil {
+		return reversal, err
+	}
+	return reversal, nil
+}
+
+func lock(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+func ensureNotReversed(tx *gorm.DB, reversal models.Reversal) error {
+	var count int64
+	err := tx.Model(&models.Reversal{}).Where("kind = ? AND target_id = ?", reversal.Kind, reversal.TargetID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadyReversed
+	}
+	return nil
+}
+
+// userPosting сумма проводки по счету сотрудника в записи журнала kind с заданной ссылкой.
+func userPosting(tx *gorm.DB, kind, reference string, userID uuid.UUID) (int64, error) {
+	var amounts []int64
+	err := tx.Table("postings").
+		Joins("JOIN journal_entries ON journal_entries.id = postings.entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("journal_entries.kind = ? AND journal_entries.reference = ? AND ledger_accounts.user_id = ?", kind, reference, userID).
+		Pluck("postings.amount", &amounts).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(amounts) == 0 || amounts[0] >= 0 {
+		return 0, ErrNoLedgerEntry
+	}
+	return amounts[0], nil
+}
+
+// save сохраняет компенсирующую запись и событие аудита в той же транзакции.
+func save(tx *gorm.DB, reversal *models.Reversal, targetType string) error {
+	if err := tx.Create(reversal).Error; err != nil {
+		return err
+	}
+	action := models.AUDIT_PURCHASE_REFUND
+	if reversal.Kind == models.REVERSAL_TOP_UP {
+		action = models.AUDIT_TOP_UP_REVERSAL
+	}
+	return audit.Write(tx, reversal.AdminID, audit.Event{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   reversal.TargetID.String(),
+		After:      map[string]interface{}{"userId": reversal.UserID, "amount": reversal.Amount, "entryId": reversal.EntryID},
+		Details:    reversal.Reason,
+	})
+}
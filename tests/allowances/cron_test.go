@@ -0,0 +1,60 @@
+package allowances_test
+
+import (
+	"Shop/allowances"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := allowances.ParseCron(spec)
+		assert.ErrorIs(t, err, allowances.ErrInvalidSchedule, spec)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	after := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"0 9 1 * *", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2024, time.January, 31, 10, 40, 0, 0, time.UTC)},
+		{"0 12 * * 1-5", time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 * 5", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 9,18 31 1,3 *", time.Date(2024, time.January, 31, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		cron, err := allowances.ParseCron(c.spec)
+		assert.NoError(t, err, c.spec)
+		assert.Equal(t, c.want, cron.Next(after), c.spec)
+	}
+}
+
+func TestCronNext_StrictlyAfter(t *testing.T) {
+	cron, _ := allowances.ParseCron("0 9 1 * *")
+	at := time.Date(2024, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC), cron.Next(at), "Момент запуска не повторяется")
+}
+
+func TestCronNext_Timezone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	cron, _ := allowances.ParseCron("0 9 1 * *")
+
+	next := cron.Next(time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC).In(moscow))
+	assert.Equal(t, time.Date(2024, time.February, 1, 6, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestCronNext_Never(t *testing.T) {
+	cron, err := allowances.ParseCron("0 0 31 2 *")
+	assert.NoError(t, err)
+	assert.True(t, cron.Next(time.Now()).IsZero(), "31 февраля не бывает")
+}
@@ -0,0 +1,152 @@
+package chain_test
+
+import (
+	"Shop/audit"
+	"Shop/chain"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("POSTGRES_HOST", "localhost")
+	os.Setenv("POSTGRES_USERNAME", "testuser")
+	os.Setenv("POSTGRES_PASSWORD", "testpassword")
+	os.Setenv("POSTGRES_DATABASE", "testdb")
+	os.Setenv("POSTGRES_PORT", "5433")
+	migrations.InitDB()
+	os.Exit(m.Run())
+}
+
+func SetupTestDB() {
+	if migrations.DB == nil {
+		log.Fatal("Database connection is not initialized")
+	}
+	migrations.DB.Exec("DELETE FROM transactions")
+	migrations.DB.Exec("DELETE FROM purchases")
+	migrations.DB.Exec("TRUNCATE audit_events")
+}
+
+func chainReport(t *testing.T, table string) chain.ChainReport {
+	report, err := chain.Verify(migrations.DB)
+	assert.NoError(t, err)
+	for _, c := range report.Chains {
+		if c.Table == table {
+			return c
+		}
+	}
+	t.Fatalf("Нет отчета по цепочке %s", table)
+	return chain.ChainReport{}
+}
+
+func createTransactions(t *testing.T, amounts ...uint) []models.Transaction {
+	var transactions []models.Transaction
+	for _, amount := range amounts {
+		transaction := models.Transaction{FromUser: uuid.New(), ToUser: uuid.New(), Amount: amount, Memo: "спасибо"}
+		assert.NoError(t, migrations.DB.Create(&transaction).Error)
+		transactions = append(transactions, transaction)
+	}
+	return transactions
+}
+
+func TestVerify_ValidChains(t *testing.T) {
+	SetupTestDB()
+
+	transactions := createTransactions(t, 10, 20, 30)
+	variantID := uuid.New()
+	assert.NoError(t, migrations.DB.Create(&models.Purchase{UserID: uuid.New(), MerchID: uuid.New(), VariantID: &variantID, Quantity: 2}).Error)
+	assert.NoError(t, audit.Write(migrations.DB, uuid.New(), audit.Event{
+		Action: models.AUDIT_MERCH_PRICE, TargetType: "merch", TargetID: uuid.NewString(),
+		Before: map[string]interface{}{"price": 10}, After: map[string]interface{}{"price": 15},
+	}))
+
+	report, err := chain.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+
+	transactionsChain := chainReport(t, "transactions")
+	assert.Equal(t, int64(3), transactionsChain.Records)
+	assert.Equal(t, transactions[2].Hash, transactionsChain.HeadHash)
+	assert.Equal(t, transactions[1].Hash, transactions[2].PrevHash)
+	assert.Equal(t, int64(1), chainReport(t, "purchases").Records)
+	assert.Equal(t, int64(1), chainReport(t, "audit_events").Records)
+}
+
+func TestVerify_EditedTransaction(t *testing.T) {
+	SetupTestDB()
+	transactions := createTransactions(t, 10, 20, 30)
+
+	migrations.DB.Exec("UPDATE transactions SET amount = 2000 WHERE id = ?", transactions[1].ID)
+
+	report := chainReport(t, "transactions")
+	assert.False(t, report.Valid)
+	if assert.NotNil(t, report.Broken) {
+		assert.Equal(t, int64(2), report.Broken.Seq)
+		assert.Equal(t, transactions[1].ID.String(), report.Broken.ID)
+	}
+}
+
+func TestVerify_DeletedPurchase(t *testing.T) {
+	SetupTestDB()
+	var purchases []models.Purchase
+	for i := 0; i < 3; i++ {
+		purchase := models.Purchase{UserID: uuid.New(), MerchID: uuid.New(), Quantity: 1}
+		assert.NoError(t, migrations.DB.Create(&purchase).Error)
+		purchases = append(purchases, purchase)
+	}
+
+	migrations.DB.Exec("DELETE FROM purchases WHERE id = ?", purchases[1].ID)
+
+	report := chainReport(t, "purchases")
+	assert.False(t, report.Valid)
+	if assert.NotNil(t, report.Broken) {
+		assert.Equal(t, int64(2), report.Broken.Seq)
+		assert.Equal(t, purchases[2].ID.String(), report.Broken.ID)
+	}
+}
+
+func TestVerify_EditedAuditEventBypassingTrigger(t *testing.T) {
+	SetupTestDB()
+	assert.NoError(t, audit.Record(migrations.DB, uuid.New(), models.AUDIT_COINS_MINT, "user", uuid.NewString(), "начислено 10 монет"))
+
+	migrations.DB.Exec("ALTER TABLE audit_events DISABLE TRIGGER audit_events_append_only")
+	migrations.DB.Exec("UPDATE audit_events SET details = 'начислено 1000 монет'")
+	migrations.DB.Exec("ALTER TABLE audit_events ENABLE TRIGGER audit_events_append_only")
+
+	report := chainReport(t, "audit_events")
+	assert.False(t, report.Valid)
+	assert.NotNil(t, report.Broken)
+}
+
+func TestBackfill_ChainsRowsCreatedBeforeChain(t *testing.T) {
+	SetupTestDB()
+	// Записи, созданные до появления цепочек: звена нет ни у одной.
+	for i := 0; i < 3; i++ {
+		migrations.DB.Exec("INSERT INTO transactions (from_user, to_user, amount) VALUES (?, ?, 5)", uuid.New(), uuid.New())
+	}
+
+	assert.NoError(t, chain.Backfill(migrations.DB))
+
+	report := chainReport(t, "transactions")
+	assert.True(t, report.Valid)
+	assert.Equal(t, int64(3), report.Records)
+}
+
+func TestBackfill_LeavesRowsInsertedIntoStartedChain(t *testing.T) {
+	SetupTestDB()
+	createTransactions(t, 10)
+
+	migrations.DB.Exec("INSERT INTO transactions (from_user, to_user, amount) VALUES (?, ?, 5)", uuid.New(), uuid.New())
+
+	// Перезапуск сервера не должен включать вставку в обход приложения в цепочку.
+	assert.NoError(t, chain.Backfill(migrations.DB))
+
+	report := chainReport(t, "transactions")
+	assert.False(t, report.Valid)
+	assert.Equal(t, int64(1), report.Unchained)
+	assert.Equal(t, int64(1), report.Records)
+}
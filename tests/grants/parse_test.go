@@ -0,0 +1,50 @@
+package grants_test
+
+import (
+	"Shop/grants"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	rows, err := grants.ParseCSV(strings.NewReader("\ufeffEmail,Amount,Reason\n" +
+		"ivan@example.com, 500, Квартальная премия\n" +
+		"petr@example.com,abc,\"Премия, за релиз\"\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []grants.Row{
+		{Line: 2, Email: "ivan@example.com", Amount: 500, Reason: "Квартальная премия"},
+		{Line: 3, Email: "petr@example.com", Amount: 0, Reason: "Премия, за релиз"},
+	}, rows)
+}
+
+func TestParseCSV_InvalidHeader(t *testing.T) {
+	_, err := grants.ParseCSV(strings.NewReader("amount,reason\n100,Премия\n"))
+	assert.Error(t, err, "Нужна колонка username или email")
+
+	_, err = grants.ParseCSV(strings.NewReader("username,amount\nivan,100\n"))
+	assert.Error(t, err, "Нужна колонка reason")
+
+	_, err = grants.ParseCSV(strings.NewReader("username,amount,reason\n"))
+	assert.ErrorIs(t, err, grants.ErrEmptyList)
+
+	_, err = grants.ParseCSV(strings.NewReader("username,amount,reason\nivan,100\n"))
+	assert.Error(t, err, "Число колонок в строке не совпадает с заголовком")
+}
+
+func TestParseJSON(t *testing.T) {
+	rows, err := grants.ParseJSON(strings.NewReader(`[{"username": " ivan ", "amount": 100, "reason": "Премия"}, {"email": "petr@example.com", "amount": -5, "reason": ""}]`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []grants.Row{
+		{Line: 1, Username: "ivan", Amount: 100, Reason: "Премия"},
+		{Line: 2, Email: "petr@example.com", Amount: -5},
+	}, rows)
+
+	_, err = grants.ParseJSON(strings.NewReader(`[]`))
+	assert.ErrorIs(t, err, grants.ErrEmptyList)
+
+	_, err = grants.ParseJSON(strings.NewReader(`{"username": "ivan"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func listUsers(t *testing.T, query string) (int, handlers.AdminUsersResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/users?"+query, nil)
+	w := httptest.NewRecorder()
+	handlers.ListUsersHandler(w, req)
+
+	var response handlers.AdminUsersResponse
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	}
+	return w.Code, response
+}
+
+func seedListedUsers(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		user := models.User{
+			ID:        uuid.New(),
+			Username:  fmt.Sprintf("worker%d", i),
+			Email:     fmt.Sprintf("worker%d@example.com", i),
+			Password:  "x",
+			Role:      models.EMPLOYEE_ROLE,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		assert.NoError(t, migrations.DB.Create(&user).Error)
+		assert.NoError(t, migrations.DB.Create(&models.Wallet{UserID: user.ID, Coin: uint(100 * (i + 1))}).Error)
+	}
+
+	admin := models.User{ID: uuid.New(), Username: "boss", Email: "boss@corp.com", Password: "x", Role: models.ADMIN_ROLE}
+	assert.NoError(t, migrations.DB.Create(&admin).Error)
+}
+
+func TestListUsersHandler_CursorPagination(t *testing.T) {
+	SetupTestDB()
+	seedListedUsers(t)
+
+	seen := map[uuid.UUID]bool{}
+	cursor := ""
+	var balances []int64
+	for page := 0; page < 10; page++ {
+		code, response := listUsers(t, "role=EMPLOYEE_ROLE&sort=balance&order=asc&limit=2&cursor="+cursor)
+		assert.Equal(t, http.StatusOK, code)
+		for _, user := range response.Users {
+			assert.False(t, seen[user.ID], "Пользователь не должен повторяться на разных страницах")
+			seen[user.ID] = true
+			balances = append(balances, user.Balance)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		cursor = response.NextCursor
+	}
+
+	assert.Equal(t, []int64{100, 200, 300, 400, 500}, balances)
+}
+
+func TestListUsersHandler_FiltersAndPurchaseCount(t *testing.T) {
+	SetupTestDB()
+	seedListedUsers(t)
+
+	var worker models.User
+	migrations.DB.First(&worker, "username = ?", "worker3")
+	merch := models.Merch{ID: uuid.New(), Name: "cup", Price: 20}
+	migrations.DB.Create(&merch)
+	migrations.DB.Create(&models.Purchase{ID: uuid.New(), UserID: worker.ID, MerchID: merch.ID})
+	migrations.DB.Create(&models.Purchase{ID: uuid.New(), UserID: worker.ID, MerchID: merch.ID})
+
+	code, response := listUsers(t, "username=worker3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Users, 1)
+	assert.Equal(t, int64(2), response.Users[0].PurchaseCount)
+	assert.Equal(t, int64(400), response.Users[0].Balance)
+
+	code, response = listUsers(t, "email=BOSS@")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Users, 1)
+	assert.Equal(t, models.ADMIN_ROLE, response.Users[0].Role)
+	assert.Equal(t, int64(0), response.Users[0].Balance)
+
+	code, response = listUsers(t, "sort=createdAt&order=desc&role=EMPLOYEE_ROLE&limit=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "worker4", response.Users[0].Username)
+	assert.NotEmpty(t, response.NextCursor)
+}
+
+func TestListUsersHandler_InvalidParams(t *testing.T) {
+	code, _ := listUsers(t, "sort=name")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = listUsers(t, "limit=1000")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = listUsers(t, "cursor=not-a-cursor")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
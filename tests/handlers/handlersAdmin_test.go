@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -322,3 +323,54 @@ func TestAddOrChangeMerchHandler_MerchAlreadyExists(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	assert.Contains(t, w.Body.String(), "Цена мерча совпадает с заданной")
 }
+
+func TestPutMoneyHandler_GrantInHistory(t *testing.T) {
+	SetupTestDB()
+
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com", Role: models.ADMIN_ROLE}
+	migrations.DB.Create(&admin)
+	worker := models.User{ID: uuid.New(), Username: "worker", Email: "worker@example.com"}
+	migrations.DB.Create(&worker)
+	migrations.DB.Create(&models.Wallet{UserID: worker.ID, Coin: 500})
+
+	body, _ := json.Marshal(handlers.SendMoney{NickTaker: "worker", Coin: 100, Reason: "Победа в хакатоне"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, admin.ID))
+	w := httptest.NewRecorder()
+	handlers.PutMoneyHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var transaction models.Transaction
+	assert.NoError(t, migrations.DB.First(&transaction, "to_user = ?", worker.ID).Error)
+	assert.Equal(t, models.TRANSACTION_KIND_GRANT, transaction.Kind)
+	assert.Equal(t, models.TreasuryUserID, transaction.FromUser)
+	assert.Equal(t, admin.ID, *transaction.AdminID)
+	assert.Equal(t, "Победа в хакатоне", transaction.Reason)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, worker.ID))
+	w = httptest.NewRecorder()
+	handlers.InformationHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var info handlers.InfoMain
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+	assert.Equal(t, uint(600), info.Coins)
+	if assert.Len(t, info.CoinHistory.Received, 1) {
+		received := info.CoinHistory.Received[0]
+		assert.Equal(t, models.TREASURY_USERNAME, received.FromUser)
+		assert.Equal(t, uint(100), received.Amount)
+		assert.Equal(t, models.TRANSACTION_KIND_GRANT, received.Kind)
+		assert.Equal(t, "admin", received.GrantedBy)
+		assert.Equal(t, "Победа в хакатоне", received.Reason)
+	}
+}
+
+func TestPutMoneyHandler_ReasonTooLong(t *testing.T) {
+	body, _ := json.Marshal(handlers.SendMoney{NickTaker: "worker", Coin: 100, Reason: strings.Repeat("я", 256)})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users", bytes.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.PutMoneyHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"Shop/allowances"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func putAllowance(adminID uuid.UUID, name, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/allowances/"+name, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, adminID))
+	w := httptest.NewRecorder()
+	handlers.PutAllowanceHandler(w, req)
+	return w
+}
+
+func TestPutAllowanceHandler_Validation(t *testing.T) {
+	SetupTestDB()
+	adminID := uuid.New()
+
+	cases := map[string]string{
+		"bad schedule": `{"amount": 100, "schedule": "0 9 * *", "reason": "Премия", "enabled": true}`,
+		"zero amount":  `{"amount": 0, "schedule": "@monthly", "reason": "Премия", "enabled": true}`,
+		"no reason":    `{"amount": 100, "schedule": "@monthly", "enabled": true}`,
+		"bad timezone": `{"amount": 100, "schedule": "@monthly", "timezone": "Mars/Olympus", "reason": "Премия", "enabled": true}`,
+		"unknown role": `{"amount": 100, "roles": ["INTERN_ROLE"], "schedule": "@monthly", "reason": "Премия", "enabled": true}`,
+		"system role":  `{"amount": 100, "roles": ["SYSTEM_ROLE"], "schedule": "@monthly", "reason": "Премия", "enabled": true}`,
+	}
+	for name, body := range cases {
+		w := putAllowance(adminID, "monthly", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+
+	w := putAllowance(adminID, "bad name!", `{"amount": 100, "schedule": "@monthly", "reason": "Премия"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var count int64
+	migrations.DB.Model(&models.AllowanceRule{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestAllowance_RunsOncePerPeriod(t *testing.T) {
+	SetupTestDB()
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com", Role: models.ADMIN_ROLE}
+	migrations.DB.Create(&admin)
+	ivan := createEmployee(t, "ivan", 100)
+	petr := createEmployee(t, "petr", 0)
+	adminWallet := models.Wallet{UserID: admin.ID, Coin: 0}
+	migrations.DB.Create(&adminWallet)
+
+	w := putAllowance(admin.ID, "monthly", `{"amount": 500, "roles": ["EMPLOYEE_ROLE"], "schedule": "0 9 1 * *", "timezone": "Europe/Moscow", "reason": "Ежемесячное начисление", "enabled": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rule models.AllowanceRule
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&rule))
+	assert.NotNil(t, rule.NextRunAt)
+	assert.Equal(t, 6, rule.NextRunAt.UTC().Hour(), "9:00 по Москве")
+
+	runs, granted, err := allowances.RunDue(migrations.DB, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, runs, "Срок еще не наступил")
+	assert.Empty(t, granted)
+
+	due := rule.NextRunAt.Add(time.Minute)
+	runs, granted, err = allowances.RunDue(migrations.DB, due)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, models.ALLOWANCE_RUN_COMPLETED, runs[0].Status)
+	assert.Equal(t, 2, runs[0].Recipients)
+	assert.Equal(t, int64(1000), runs[0].TotalAmount)
+	run := runs[0]
+	assert.ElementsMatch(t, []uuid.UUID{ivan.ID, petr.ID}, granted)
+	assert.Equal(t, uint(600), walletCoin(t, ivan.ID))
+	assert.Equal(t, uint(500), walletCoin(t, petr.ID))
+	assert.Equal(t, uint(0), walletCoin(t, admin.ID), "Админ не в аудитории правила")
+
+	runs, _, err = allowances.RunDue(migrations.DB, due)
+	assert.NoError(t, err)
+	assert.Empty(t, runs, "За период правило срабатывает один раз")
+	assert.Equal(t, uint(600), walletCoin(t, ivan.ID))
+
+	var transaction models.Transaction
+	assert.NoError(t, migrations.DB.Where("to_user = ?", petr.ID).First(&transaction).Error)
+	assert.Equal(t, models.TRANSACTION_KIND_GRANT, transaction.Kind)
+	assert.Equal(t, models.TreasuryUserID, transaction.FromUser)
+	assert.Equal(t, "Ежемесячное начисление", transaction.Reason)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/allowance-runs/"+run.ID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": run.ID.String()})
+	w = httptest.NewRecorder()
+	handlers.AllowanceRunHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var details handlers.AllowanceRunDetails
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&details))
+	assert.Equal(t, "monthly", details.Rule)
+	assert.Len(t, details.Grants, 2)
+	assert.Equal(t, "ivan", details.Grants[0].Username)
+
+	assert.NoError(t, migrations.DB.First(&rule, "name = ?", "monthly").Error)
+	assert.True(t, rule.NextRunAt.After(due), "Следующий запуск сдвинут на следующий период")
+}
+
+func TestAllowance_DisabledRuleDoesNotRun(t *testing.T) {
+	SetupTestDB()
+	ivan := createEmployee(t, "ivan", 100)
+
+	w := putAllowance(uuid.New(), "weekly", `{"amount": 50, "schedule": "@weekly", "reason": "Бонус", "enabled": false}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	runs, _, err := allowances.RunDue(migrations.DB, time.Now().AddDate(0, 1, 0))
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID))
+}
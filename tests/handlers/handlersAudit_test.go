@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func changeMerchPrice(adminID uuid.UUID, item string, price uint, requestID string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(handlers.MerchInfo{Type: item, Price: price})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/merch/new", bytes.NewReader(body))
+	req.RemoteAddr = "10.0.0.7:51234"
+	req.Header.Set(utils.RequestIDHeader, requestID)
+	w := httptest.NewRecorder()
+	utils.RequestMetaMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), utils.UserIDKey, adminID))
+		handlers.AddOrChangeMerchHandler(w, r)
+	})).ServeHTTP(w, req)
+	return w
+}
+
+func auditPage(query string) (*httptest.ResponseRecorder, handlers.AuditPage) {
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/audit?"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.AuditHandler(w, req)
+
+	var page handlers.AuditPage
+	_ = json.Unmarshal(w.Body.Bytes(), &page)
+	return w, page
+}
+
+func TestAuditHandler_PriceChangeWithBeforeAfter(t *testing.T) {
+	SetupTestDB()
+	adminID := uuid.New()
+
+	assert.Equal(t, http.StatusOK, changeMerchPrice(adminID, "cup", 20, "req-create").Code)
+	assert.Equal(t, http.StatusOK, changeMerchPrice(adminID, "cup", 35, "req-price").Code)
+
+	w, page := auditPage("action=" + models.AUDIT_MERCH_PRICE)
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, page.Events, 1) {
+		event := page.Events[0]
+		assert.Equal(t, adminID, *event.ActorID)
+		assert.Equal(t, "merch", event.TargetType)
+		assert.Equal(t, float64(20), event.Before["price"])
+		assert.Equal(t, float64(35), event.After["price"])
+		assert.Equal(t, "req-price", event.RequestID)
+		assert.Equal(t, "10.0.0.7", event.IP)
+	}
+
+	_, page = auditPage("requestId=req-create")
+	if assert.Len(t, page.Events, 1) {
+		assert.Equal(t, models.AUDIT_MERCH_CREATE, page.Events[0].Action)
+		assert.Nil(t, page.Events[0].Before)
+	}
+}
+
+func TestAuditHandler_FiltersAndPagination(t *testing.T) {
+	SetupTestDB()
+	adminID := uuid.New()
+
+	for i, price := range []uint{10, 20, 30} {
+		assert.Equal(t, http.StatusOK, changeMerchPrice(adminID, "pen", price, "req-"+string(rune('a'+i))).Code)
+	}
+
+	_, first := auditPage("targetType=merch&limit=2")
+	assert.Len(t, first.Events, 2)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, float64(30), first.Events[0].After["price"])
+
+	_, second := auditPage("targetType=merch&limit=2&cursor=" + first.NextCursor)
+	assert.Len(t, second.Events, 1)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, models.AUDIT_MERCH_CREATE, second.Events[0].Action)
+
+	_, page := auditPage("actorId=" + uuid.New().String())
+	assert.Empty(t, page.Events)
+
+	w, _ := auditPage("from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAuditEvents_AppendOnly(t *testing.T) {
+	SetupTestDB()
+	assert.Equal(t, http.StatusOK, changeMerchPrice(uuid.New(), "mug", 15, "req-mug").Code)
+
+	err := migrations.DB.Exec("UPDATE audit_events SET details = 'изменено'").Error
+	assert.Error(t, err, "Записи журнала аудита нельзя изменять")
+	err = migrations.DB.Exec("DELETE FROM audit_events").Error
+	assert.Error(t, err, "Записи журнала аудита нельзя удалять")
+
+	var count int64
+	migrations.DB.Model(&models.AuditEvent{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -73,8 +73,30 @@ func TestAuthHandler_InvalidPassword(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
 }
 
+func TestAuthHandler_UnknownEmail(t *testing.T) {
+	SetupTestDB()
+	requestBody, _ := json.Marshal(map[string]string{
+		"email":    "typo@example.com",
+		"password": "newpassword",
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+
+	handlers.AuthHandler(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	var count int64
+	migrations.DB.Model(&models.User{}).Where("email = ?", "typo@example.com").Count(&count)
+	assert.Equal(t, int64(0), count, "Пользователь не должен создаваться при входе")
+}
+
 func TestAuthHandler_CreateNewUser(t *testing.T) {
 	SetupTestDB()
+	t.Setenv("AUTH_AUTO_PROVISION", "true")
 	requestBody, _ := json.Marshal(map[string]string{
 		"email":    "newuser@example.com",
 		"password": "newpassword",
@@ -87,7 +109,7 @@ func TestAuthHandler_CreateNewUser(t *testing.T) {
 	res := w.Result()
 	defer res.Body.Close()
 
-	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
 
 	var user models.User
 	err := migrations.DB.Where("email = ?", "newuser@example.com").First(&user).Error
@@ -156,3 +178,80 @@ func TestLogoutHandler_InvalidTokenFormat(t *testing.T) {
 
 	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
 }
+
+func loginForTokens(t *testing.T, email, password string) utils.TokenPair {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	migrations.DB.Create(&models.User{
+		ID:       uuid.New(),
+		Username: "TestUser",
+		Email:    email,
+		Password: string(hashedPassword),
+	})
+
+	requestBody, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+	handlers.AuthHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var tokens utils.TokenPair
+	err := json.NewDecoder(w.Body).Decode(&tokens)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	return tokens
+}
+
+func refresh(refreshToken string) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.RefreshRequest{RefreshToken: refreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+	handlers.RefreshHandler(w, req)
+	return w
+}
+
+func TestRefreshHandler_RotatesToken(t *testing.T) {
+	SetupTestDB()
+	tokens := loginForTokens(t, "test@example.com", "password123")
+
+	w := refresh(tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var rotated utils.TokenPair
+	err := json.NewDecoder(w.Body).Decode(&rotated)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, rotated.AccessToken)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+}
+
+func TestRefreshHandler_ReuseRevokesFamily(t *testing.T) {
+	SetupTestDB()
+	tokens := loginForTokens(t, "test@example.com", "password123")
+
+	first := refresh(tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, first.Code)
+	var rotated utils.TokenPair
+	_ = json.NewDecoder(first.Body).Decode(&rotated)
+
+	reused := refresh(tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, reused.Code)
+
+	afterReuse := refresh(rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, afterReuse.Code, "После повторного использования вся сессия должна быть отозвана")
+}
+
+func TestRefreshHandler_UnknownToken(t *testing.T) {
+	SetupTestDB()
+
+	w := refresh("unknown-token")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshHandler_EmptyBody(t *testing.T) {
+	SetupTestDB()
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader([]byte("{}")))
+	w := httptest.NewRecorder()
+	handlers.RefreshHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
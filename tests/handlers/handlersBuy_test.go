@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/ledger"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func purchase(userID uuid.UUID, body interface{}) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/buy", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.PurchaseHandler(w, req)
+	return w
+}
+
+func TestPurchaseHandler_Quantity(t *testing.T) {
+	SetupTestDB()
+	stock := uint(5)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20, Stock: &stock})
+	buyer := createBuyer(t, 100)
+
+	w := purchase(buyer.ID, handlers.BuyRequest{Item: "cup", Quantity: 3})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response handlers.InfoAfterBying
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, float64(40), response.Balance)
+	assert.Equal(t, float64(3), response.Quantity)
+
+	var cup models.Merch
+	migrations.DB.First(&cup, "name = ?", "cup")
+	assert.Equal(t, uint(2), *cup.Stock)
+
+	var order models.Order
+	migrations.DB.Preload("Items").First(&order, "id = ?", response.OrderID)
+	assert.Equal(t, uint(60), order.Total)
+	assert.Equal(t, uint(3), order.Items[0].Quantity)
+	assert.Equal(t, uint(20), order.Items[0].Price)
+}
+
+func TestPurchaseHandler_Rejections(t *testing.T) {
+	SetupTestDB()
+	stock := uint(5)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20, Stock: &stock})
+	buyer := createBuyer(t, 50)
+
+	assert.Equal(t, http.StatusBadRequest, purchase(buyer.ID, handlers.BuyRequest{Item: "cup"}).Code, "Количество обязательно")
+	assert.Equal(t, http.StatusBadRequest, purchase(buyer.ID, handlers.BuyRequest{Quantity: 1}).Code, "Товар обязателен")
+	assert.Equal(t, http.StatusBadRequest, purchase(buyer.ID, handlers.BuyRequest{Item: "cup", Quantity: models.MaxCartQuantity + 1}).Code)
+	assert.Equal(t, http.StatusConflict, purchase(buyer.ID, handlers.BuyRequest{Item: "cup", Quantity: 6}).Code, "Больше остатка")
+
+	w := purchase(buyer.ID, handlers.BuyRequest{Item: "cup", Quantity: 3})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Стоимость всех штук больше баланса")
+
+	var cup models.Merch
+	migrations.DB.First(&cup, "name = ?", "cup")
+	assert.Equal(t, uint(5), *cup.Stock)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(50), wallet.Coin)
+}
+
+// Параллельные покупки одного сотрудника: 550 монет хватает ровно на 5 книг и 4 кружки
+// (весь остаток) в любом порядке, лишние запросы должны получить отказ, а баланс не уйти в минус.
+func TestPurchaseHandler_ConcurrentBuysNeverOverspend(t *testing.T) {
+	SetupTestDB()
+	stock := uint(4)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "book", Price: 100})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 10, Stock: &stock})
+	buyer := createBuyer(t, 550)
+
+	const parallel = 20
+	var mu sync.Mutex
+	succeeded := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		for _, item := range []string{"book", "cup"} {
+			wg.Add(1)
+			go func(item string) {
+				defer wg.Done()
+				code := purchase(buyer.ID, handlers.BuyRequest{Item: item, Quantity: 1}).Code
+				assert.Contains(t, []int{http.StatusOK, http.StatusBadRequest, http.StatusConflict}, code)
+				if code == http.StatusOK {
+					mu.Lock()
+					succeeded[item]++
+					mu.Unlock()
+				}
+			}(item)
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, succeeded["book"])
+	assert.Equal(t, 4, succeeded["cup"])
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(10), wallet.Coin)
+
+	var cup models.Merch
+	migrations.DB.First(&cup, "name = ?", "cup")
+	assert.Equal(t, uint(0), *cup.Stock)
+
+	var orders int64
+	migrations.DB.Model(&models.Order{}).Where("user_id = ?", buyer.ID).Count(&orders)
+	assert.Equal(t, int64(9), orders)
+
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+}
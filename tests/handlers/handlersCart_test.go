@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func addToCart(userID uuid.UUID, item string, quantity uint) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.CartItemRequest{Item: item, Quantity: quantity})
+	req := httptest.NewRequest(http.MethodPost, "/api/cart/items", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.AddToCartHandler(w, req)
+	return w
+}
+
+func setCartQuantity(userID uuid.UUID, item string, quantity uint) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.CartQuantityRequest{Quantity: quantity})
+	req := httptest.NewRequest(http.MethodPut, "/api/cart/items/"+item, bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"item": item})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.SetCartQuantityHandler(w, req)
+	return w
+}
+
+func checkout(userID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/checkout", nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.CheckoutHandler(w, req)
+	return w
+}
+
+func TestCart_AddUpdateRemove(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "pen", Price: 10})
+	buyer := createBuyer(t, 1000)
+
+	assert.Equal(t, http.StatusOK, addToCart(buyer.ID, "cup", 1).Code)
+	assert.Equal(t, http.StatusOK, addToCart(buyer.ID, "cup", 2).Code)
+	w := addToCart(buyer.ID, "pen", 1)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var cart handlers.CartView
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&cart))
+	assert.Len(t, cart.Items, 2)
+	assert.Equal(t, uint(3*20+10), cart.Total)
+
+	w = setCartQuantity(buyer.ID, "cup", 0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	cart = handlers.CartView{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&cart))
+	assert.Len(t, cart.Items, 1)
+	assert.Equal(t, "pen", cart.Items[0].Item)
+
+	assert.Equal(t, http.StatusNotFound, addToCart(buyer.ID, "missing", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, setCartQuantity(buyer.ID, "pen", models.MaxCartQuantity+1).Code)
+}
+
+func TestCheckout_CreatesSingleOrder(t *testing.T) {
+	SetupTestDB()
+	stock := uint(5)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20, Stock: &stock})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "pen", Price: 10})
+	buyer := createBuyer(t, 1000)
+
+	addToCart(buyer.ID, "cup", 2)
+	addToCart(buyer.ID, "pen", 3)
+
+	w := checkout(buyer.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var order models.Order
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&order))
+	assert.Equal(t, uint(2*20+3*10), order.Total)
+	assert.Len(t, order.Items, 2)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(1000-70), wallet.Coin)
+
+	var cup models.Merch
+	migrations.DB.First(&cup, "name = ?", "cup")
+	assert.Equal(t, uint(3), *cup.Stock)
+
+	var purchases, cartItems int64
+	migrations.DB.Model(&models.Purchase{}).Where("user_id = ?", buyer.ID).Count(&purchases)
+	migrations.DB.Model(&models.CartItem{}).Where("user_id = ?", buyer.ID).Count(&cartItems)
+	assert.Equal(t, int64(0), purchases, "Заказ не должен создавать отдельные покупки")
+	assert.Equal(t, int64(0), cartItems, "Корзина должна очиститься")
+
+	assert.Equal(t, http.StatusBadRequest, checkout(buyer.ID).Code, "Пустую корзину оформить нельзя")
+}
+
+func TestCheckout_InfoShowsNewBalance(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	buyer := createBuyer(t, 1000)
+
+	info := func() handlers.InfoMain {
+		req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+		req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, buyer.ID))
+		w := httptest.NewRecorder()
+		handlers.InformationHandler(w, req)
+		var info handlers.InfoMain
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+		return info
+	}
+	assert.Equal(t, uint(1000), info().Coins)
+
+	addToCart(buyer.ID, "cup", 2)
+	assert.Equal(t, http.StatusCreated, checkout(buyer.ID).Code)
+	assert.Equal(t, uint(1000-40), info().Coins, "Баланс из кэша должен сброситься после оформления")
+}
+
+func TestCheckout_AllOrNothing(t *testing.T) {
+	SetupTestDB()
+	stock := uint(5)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "hoody", Price: 300, Stock: &stock})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	buyer := createBuyer(t, 500)
+
+	addToCart(buyer.ID, "cup", 1)
+	addToCart(buyer.ID, "hoody", 2)
+
+	assert.Equal(t, http.StatusBadRequest, checkout(buyer.ID).Code)
+
+	var hoody models.Merch
+	migrations.DB.First(&hoody, "name = ?", "hoody")
+	assert.Equal(t, uint(5), *hoody.Stock, "Остаток не должен измениться при неудачном оформлении")
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(500), wallet.Coin)
+
+	var orders int64
+	migrations.DB.Model(&models.Order{}).Count(&orders)
+	assert.Equal(t, int64(0), orders)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -160,6 +161,58 @@ func TestSendCoinHandler_Success(t *testing.T) {
 	assert.Equal(t, uint(10), transaction.Amount)
 }
 
+func TestSendCoinHandler_MemoAndCategory(t *testing.T) {
+	SetupTestDB()
+
+	senderID := uuid.New()
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	sender := models.User{ID: senderID, Username: "sender", Email: "sender@example.com"}
+
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"toUser":   "receiver",
+		"coin":     10,
+		"memo":     "  Спасибо\nза\u202e   ревью ",
+		"category": models.TRANSFER_CATEGORY_THANKS,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, senderID))
+	w := httptest.NewRecorder()
+
+	handlers.SendCoinHandler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var transaction models.Transaction
+	migrations.DB.First(&transaction)
+	assert.Equal(t, "Спасибо за ревью", transaction.Memo)
+	assert.Equal(t, models.TRANSFER_CATEGORY_THANKS, transaction.Category)
+}
+
+func TestSendCoinHandler_InvalidMemoOrCategory(t *testing.T) {
+	SetupTestDB()
+
+	senderID := uuid.New()
+	for _, body := range []map[string]interface{}{
+		{"toUser": "receiver", "coin": 10, "memo": strings.Repeat("а", models.MaxMemoLength+1)},
+		{"toUser": "receiver", "coin": 10, "category": "bribe"},
+	} {
+		requestBody, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(requestBody))
+		req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, senderID))
+		w := httptest.NewRecorder()
+
+		handlers.SendCoinHandler(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestSendCoinHandler_NotEnoughCoins(t *testing.T) {
 	SetupTestDB()
 
@@ -0,0 +1,84 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/expiry"
+	"Shop/handlers"
+	"Shop/treasury"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiry_ShownInInfoAndExpiredOnce(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("COIN_EXPIRY_MONTHS", "1")
+	t.Setenv("EXPIRY_NOTICE_DAYS", "40")
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com", Role: models.ADMIN_ROLE}
+	migrations.DB.Create(&admin)
+	ivan := createEmployee(t, "ivan", 100)
+
+	err := migrations.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := treasury.LockWallets(tx, []uuid.UUID{ivan.ID}); err != nil {
+			return err
+		}
+		_, _, err := treasury.Grant(tx, admin.ID, ivan.ID, 200, "Премия")
+		return err
+	})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, ivan.ID))
+	w := httptest.NewRecorder()
+	handlers.InformationHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var info handlers.InfoMain
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+	assert.Equal(t, uint(300), info.Coins)
+	assert.Len(t, info.Expiring, 1, "Монеты, бывшие на кошельке до учета партий, не сгорают")
+	assert.Equal(t, uint(200), info.Expiring[0].Amount)
+
+	later := time.Now().AddDate(0, 2, 0)
+	expired, total, err := expiry.Run(migrations.DB, later)
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ivan.ID}, expired)
+	assert.Equal(t, uint64(200), total)
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID))
+
+	var transaction models.Transaction
+	assert.NoError(t, migrations.DB.Where("kind = ?", models.TRANSACTION_KIND_EXPIRY).First(&transaction).Error)
+	assert.Equal(t, ivan.ID, transaction.FromUser)
+	assert.Equal(t, models.TreasuryUserID, transaction.ToUser)
+	assert.Equal(t, uint(200), transaction.Amount)
+	assert.Nil(t, transaction.AdminID)
+
+	expired, _, err = expiry.Run(migrations.DB, later)
+	assert.NoError(t, err)
+	assert.Empty(t, expired, "Сгоревшие монеты не списываются повторно")
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID))
+}
+
+func TestSendCoinHandler_BalanceLimit(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("MAX_WALLET_BALANCE", "150")
+	ivan := createEmployee(t, "ivan", 100)
+	createEmployee(t, "petr", 100)
+
+	body := `{"toUser": "petr", "coin": 60}`
+	req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, ivan.ID))
+	w := httptest.NewRecorder()
+	handlers.SendCoinHandler(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID))
+}
@@ -0,0 +1,171 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/grants"
+	"Shop/handlers"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func createEmployee(t *testing.T, username string, coins uint) models.User {
+	user := models.User{ID: uuid.New(), Username: username, Email: username + "@example.com", Password: "x"}
+	assert.NoError(t, migrations.DB.Create(&user).Error)
+	assert.NoError(t, migrations.DB.Create(&models.Wallet{UserID: user.ID, Coin: coins}).Error)
+	return user
+}
+
+func bulkGrant(adminID uuid.UUID, query, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/grants/bulk"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, adminID))
+	w := httptest.NewRecorder()
+	handlers.BulkGrantHandler(w, req)
+	return w
+}
+
+func walletCoin(t *testing.T, userID uuid.UUID) uint {
+	var wallet models.Wallet
+	assert.NoError(t, migrations.DB.First(&wallet, "user_id = ?", userID).Error)
+	return wallet.Coin
+}
+
+func TestBulkGrant_DryRunReportsAllErrors(t *testing.T) {
+	SetupTestDB()
+	ivan := createEmployee(t, "ivan", 100)
+	createEmployee(t, "petr", 100)
+
+	csv := "username,email,amount,reason\n" +
+		"ivan,,500,Квартальная премия\n" +
+		"ghost,,100,Премия\n" +
+		",petr@example.com,0,\n" +
+		",ivan@example.com,100,Дубль\n" +
+		"treasury,,100,Казне\n"
+	w := bulkGrant(uuid.New(), "?dryRun=true", "text/csv", csv)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report grants.Report
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.False(t, report.Valid)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, int64(500), report.TotalAmount)
+	lines := []int{}
+	for _, rowError := range report.Errors {
+		lines = append(lines, rowError.Line)
+	}
+	assert.Equal(t, []int{3, 4, 4, 5, 6}, lines, "Сумма и причина в строке 4 — две ошибки")
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID), "Проверка ничего не начисляет")
+
+	w = bulkGrant(uuid.New(), "", "text/csv", csv)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, uint(100), walletCoin(t, ivan.ID), "Список с ошибками не применяется")
+}
+
+func TestBulkGrant_AppliesInOneTransaction(t *testing.T) {
+	SetupTestDB()
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com", Role: models.ADMIN_ROLE}
+	migrations.DB.Create(&admin)
+	ivan := createEmployee(t, "ivan", 100)
+	petr := createEmployee(t, "petr", 0)
+
+	w := bulkGrant(admin.ID, "", "application/json",
+		`[{"username": "ivan", "amount": 1500, "reason": "Квартальная премия"}, {"email": "PETR@example.com", "amount": 700, "reason": "Квартальная премия"}]`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var result handlers.BulkGrantResult
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, models.BULK_GRANT_STATUS_COMPLETED, result.Job.Status)
+	assert.Equal(t, 2, result.Job.Granted)
+	assert.Equal(t, int64(2200), result.Job.GrantedAmount)
+
+	assert.Equal(t, uint(1600), walletCoin(t, ivan.ID))
+	assert.Equal(t, uint(700), walletCoin(t, petr.ID))
+
+	var transaction models.Transaction
+	assert.NoError(t, migrations.DB.First(&transaction, "to_user = ?", petr.ID).Error)
+	assert.Equal(t, models.TRANSACTION_KIND_GRANT, transaction.Kind)
+	assert.Equal(t, admin.ID, *transaction.AdminID)
+}
+
+func TestBulkGrant_ChunkedJobAndResume(t *testing.T) {
+	SetupTestDB()
+	adminID := uuid.New()
+	users := []models.User{createEmployee(t, "ivan", 0), createEmployee(t, "petr", 0), createEmployee(t, "olga", 0)}
+
+	w := bulkGrant(adminID, "?chunkSize=2", "text/csv", "username,amount,reason\nivan,10,Премия\npetr,20,Премия\nolga,30,Премия\n")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var result handlers.BulkGrantResult
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, models.BULK_GRANT_STATUS_PENDING, result.Job.Status)
+	assert.Equal(t, uint(0), walletCoin(t, users[0].ID), "Задание применяется в фоне")
+
+	processed, granted, err := grants.ProcessNext(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Len(t, granted, 2)
+
+	// Кошелек пропал между проверкой и применением — порция не применяется, задание останавливается.
+	migrations.DB.Where("user_id = ?", users[2].ID).Delete(&models.Wallet{})
+	processed, _, err = grants.ProcessNext(migrations.DB)
+	assert.True(t, processed)
+	assert.Error(t, err)
+
+	progress := jobProgress(t, result.Job.ID)
+	assert.Equal(t, models.BULK_GRANT_STATUS_FAILED, progress.Job.Status)
+	assert.Equal(t, 2, progress.Job.Granted)
+	assert.NotEmpty(t, progress.Job.Error)
+	if assert.Len(t, progress.Pending, 1) {
+		assert.Equal(t, "olga", progress.Pending[0].Username)
+	}
+
+	processed, _, err = grants.ProcessNext(migrations.DB)
+	assert.NoError(t, err)
+	assert.False(t, processed, "Остановленное задание само не продолжается")
+
+	migrations.DB.Create(&models.Wallet{UserID: users[2].ID})
+	assert.Equal(t, http.StatusOK, resumeJob(adminID, result.Job.ID).Code)
+	assert.Equal(t, http.StatusConflict, resumeJob(adminID, result.Job.ID).Code)
+
+	processed, _, err = grants.ProcessNext(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	progress = jobProgress(t, result.Job.ID)
+	assert.Equal(t, models.BULK_GRANT_STATUS_COMPLETED, progress.Job.Status)
+	assert.Equal(t, int64(60), progress.Job.GrantedAmount)
+	assert.Empty(t, progress.Pending)
+	assert.Equal(t, uint(10), walletCoin(t, users[0].ID))
+	assert.Equal(t, uint(20), walletCoin(t, users[1].ID))
+	assert.Equal(t, uint(30), walletCoin(t, users[2].ID))
+}
+
+func jobProgress(t *testing.T, jobID uuid.UUID) handlers.BulkGrantProgress {
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/grants/bulk/"+jobID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": jobID.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.BulkGrantJobHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var progress handlers.BulkGrantProgress
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&progress))
+	return progress
+}
+
+func resumeJob(adminID, jobID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/grants/bulk/"+jobID.String()+"/resume", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": jobID.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, adminID))
+	w := httptest.NewRecorder()
+	handlers.ResumeBulkGrantHandler(w, req)
+	return w
+}
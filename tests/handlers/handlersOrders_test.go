@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func setOrderStatus(orderID uuid.UUID, body handlers.OrderStatusRequest) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/orders/"+orderID.String()+"/status", bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"id": orderID.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.UpdateOrderStatusHandler(w, req)
+	return w
+}
+
+func cancelMyOrder(userID, orderID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/cancel", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": orderID.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.CancelMyOrderHandler(w, req)
+	return w
+}
+
+func boughtOrder(t *testing.T, userID uuid.UUID, item string) handlers.InfoAfterBying {
+	w := buyItem(userID, item)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response handlers.InfoAfterBying
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	return response
+}
+
+func TestOrderLifecycle_PickupCode(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	buyer := createBuyer(t, 1000)
+
+	bought := boughtOrder(t, buyer.ID, "cup")
+	orderID := uuid.MustParse(bought.OrderID.(string))
+	pickupCode := bought.PickupCode.(string)
+	assert.Len(t, pickupCode, 6)
+
+	var order models.Order
+	migrations.DB.First(&order, "id = ?", orderID)
+	assert.Equal(t, models.ORDER_STATUS_PLACED, order.Status)
+
+	w := setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_FULFILLED, PickupCode: pickupCode})
+	assert.Equal(t, http.StatusConflict, w.Code, "Несобранный заказ выдать нельзя")
+
+	w = setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_READY_FOR_PICKUP})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), pickupCode, "Код выдачи не показывается админу")
+
+	w = setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_FULFILLED, PickupCode: "000000x"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_FULFILLED, PickupCode: pickupCode})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	migrations.DB.First(&order, "id = ?", orderID)
+	assert.Equal(t, models.ORDER_STATUS_FULFILLED, order.Status)
+	assert.NotNil(t, order.FulfilledAt)
+
+	w = setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_CANCELLED, Reason: "поздно"})
+	assert.Equal(t, http.StatusConflict, w.Code, "Выданный заказ отменить нельзя")
+
+	var events int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ? AND target_id = ?", models.AUDIT_ORDER_STATUS, orderID.String()).Count(&events)
+	assert.Equal(t, int64(2), events)
+}
+
+func TestOrderCancel_RefundsCoinsAndStock(t *testing.T) {
+	SetupTestDB()
+	stock := uint(3)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "hoody", Price: 300, Stock: &stock})
+	buyer := createBuyer(t, 1000)
+
+	bought := boughtOrder(t, buyer.ID, "hoody")
+	orderID := uuid.MustParse(bought.OrderID.(string))
+
+	w := setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_CANCELLED})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Отмена без причины")
+
+	w = setOrderStatus(orderID, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_CANCELLED, Reason: "Брак"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(1000), wallet.Coin)
+
+	var hoody models.Merch
+	migrations.DB.First(&hoody, "name = ?", "hoody")
+	assert.Equal(t, uint(3), *hoody.Stock)
+
+	var refunds int64
+	migrations.DB.Model(&models.JournalEntry{}).Where("kind = ? AND reference = ?", models.ENTRY_REFUND, orderID.String()).Count(&refunds)
+	assert.Equal(t, int64(1), refunds)
+
+	var order models.Order
+	migrations.DB.First(&order, "id = ?", orderID)
+	assert.Equal(t, "Брак", order.CancelReason)
+}
+
+func TestCancelMyOrder(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	buyer := createBuyer(t, 100)
+
+	first := uuid.MustParse(boughtOrder(t, buyer.ID, "cup").OrderID.(string))
+	second := uuid.MustParse(boughtOrder(t, buyer.ID, "cup").OrderID.(string))
+
+	assert.Equal(t, http.StatusNotFound, cancelMyOrder(uuid.New(), first).Code, "Чужой заказ отменить нельзя")
+
+	assert.Equal(t, http.StatusOK, cancelMyOrder(buyer.ID, first).Code)
+	assert.Equal(t, http.StatusConflict, cancelMyOrder(buyer.ID, first).Code)
+
+	setOrderStatus(second, handlers.OrderStatusRequest{Status: models.ORDER_STATUS_READY_FOR_PICKUP})
+	assert.Equal(t, http.StatusConflict, cancelMyOrder(buyer.ID, second).Code, "Собранный заказ сотрудник не отменяет")
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(80), wallet.Coin)
+}
@@ -0,0 +1,192 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/ledger"
+	"Shop/reconcile"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func runReconciliation(t *testing.T) handlers.ReconciliationResult {
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/reconciliation/runs", nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.RunReconciliationHandler(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var result handlers.ReconciliationResult
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	return result
+}
+
+func resolveMismatch(id uuid.UUID, resolution, reason string) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.ResolveMismatchRequest{Resolution: resolution, Reason: reason})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/reconciliation/mismatches/"+id.String()+"/resolve", bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"id": id.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.ResolveMismatchHandler(w, req)
+	return w
+}
+
+// tamperedWallet сотрудник, чей кошелек изменили в обход журнала на delta монет.
+func tamperedWallet(t *testing.T, username string, delta int) models.User {
+	user := models.User{ID: uuid.New(), Username: username, Email: username + "@example.com", Password: "x"}
+	assert.NoError(t, migrations.DB.Create(&user).Error)
+	_, err := ledger.OpenWallet(migrations.DB, user.ID, 1000)
+	assert.NoError(t, err)
+	topUp(t, username, 200)
+	migrations.DB.Exec("UPDATE wallets SET coin = coin + ? WHERE user_id = ?", delta, user.ID)
+	return user
+}
+
+func TestReconciliation_ReportsMismatchWithBreakdown(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 50)
+	clean := models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", Password: "x"}
+	migrations.DB.Create(&clean)
+	_, err := ledger.OpenWallet(migrations.DB, clean.ID, 1000)
+	assert.NoError(t, err)
+
+	result := runReconciliation(t)
+	assert.Equal(t, int64(2), result.Run.Wallets)
+	if assert.Len(t, result.Mismatches, 1) {
+		mismatch := result.Mismatches[0]
+		assert.Equal(t, user.ID, mismatch.UserID)
+		assert.Equal(t, int64(1250), mismatch.WalletCoin)
+		assert.Equal(t, int64(1200), mismatch.ExpectedBalance)
+		assert.Equal(t, int64(50), mismatch.Difference)
+		assert.Equal(t, int64(1000), mismatch.Breakdown["startingBalance"])
+		assert.Equal(t, int64(200), mismatch.Breakdown["topUps"])
+	}
+
+	second := runReconciliation(t)
+	var superseded int64
+	migrations.DB.Model(&models.BalanceMismatch{}).Where("status = ?", models.MISMATCH_STATUS_SUPERSEDED).Count(&superseded)
+	assert.Equal(t, int64(1), superseded, "Расхождение прошлой сверки должно устареть")
+	assert.Equal(t, http.StatusConflict, resolveMismatch(result.Mismatches[0].ID, models.MISMATCH_RESOLUTION_LEDGER, "Проверено").Code)
+	assert.Len(t, second.Mismatches, 1)
+}
+
+func TestReconciliation_ResolveWithCorrectingEntry(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 50)
+	mismatch := runReconciliation(t).Mismatches[0]
+
+	assert.Equal(t, http.StatusBadRequest, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "").Code)
+	assert.Equal(t, http.StatusBadRequest, resolveMismatch(mismatch.ID, "delete", "Проверено").Code)
+
+	w := resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "Начисление до появления журнала")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resolved models.BalanceMismatch
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resolved))
+	assert.Equal(t, models.MISMATCH_STATUS_RESOLVED, resolved.Status)
+	assert.NotNil(t, resolved.EntryID)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", user.ID)
+	assert.Equal(t, uint(1250), wallet.Coin, "Кошелек не меняется, исправляется журнал")
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+
+	assert.Equal(t, http.StatusConflict, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "Повтор").Code)
+	var events int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ? AND target_id = ?", models.AUDIT_BALANCE_CORRECTION, user.ID.String()).Count(&events)
+	assert.Equal(t, int64(1), events)
+}
+
+func TestReconciliation_ResolveByResettingWallet(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 500)
+	mismatch := runReconciliation(t).Mismatches[0]
+
+	assert.Equal(t, http.StatusOK, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_WALLET, "Кошелек изменили вручную").Code)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", user.ID)
+	assert.Equal(t, uint(1200), wallet.Coin)
+	assert.Empty(t, runReconciliation(t).Mismatches)
+}
+
+func TestReconciliation_WalletWithoutLedgerAccount(t *testing.T) {
+	SetupTestDB()
+	user := models.User{ID: uuid.New(), Username: "legacy", Email: "legacy@example.com", Password: "x"}
+	migrations.DB.Create(&user)
+	// Кошелек заведен в обход журнала: счета у сотрудника нет.
+	migrations.DB.Create(&models.Wallet{UserID: user.ID, Coin: 300})
+
+	result := runReconciliation(t)
+	assert.Equal(t, int64(1), result.Run.Wallets)
+	if !assert.Len(t, result.Mismatches, 1) {
+		return
+	}
+	mismatch := result.Mismatches[0]
+	assert.Equal(t, int64(0), mismatch.ExpectedBalance)
+	assert.Equal(t, int64(300), mismatch.Difference)
+
+	assert.Equal(t, http.StatusOK, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "Кошелек до появления журнала").Code)
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+	assert.Equal(t, int64(300), lotsRemaining(user.ID))
+}
+
+func TestReconciliation_ResolveSyncsCoinLots(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 500)
+	mismatch := runReconciliation(t).Mismatches[0]
+
+	// Журнал признает 500 монет, добавленных в обход него: у них должна появиться партия.
+	assert.Equal(t, http.StatusOK, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "Начисление вручную").Code)
+	assert.Equal(t, int64(1700), lotsRemaining(user.ID), "Партии должны сойтись с кошельком после исправления")
+}
+
+func lotsRemaining(userID uuid.UUID) int64 {
+	var remaining int64
+	migrations.DB.Model(&models.CoinLot{}).Where("user_id = ?", userID).Select("COALESCE(SUM(remaining), 0)").Scan(&remaining)
+	return remaining
+}
+
+func TestReconciliation_StaleMismatch(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 50)
+	mismatch := runReconciliation(t).Mismatches[0]
+
+	migrations.DB.Exec("UPDATE wallets SET coin = coin + 10 WHERE user_id = ?", user.ID)
+
+	assert.Equal(t, http.StatusConflict, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_WALLET, "Проверено").Code)
+	assert.Equal(t, http.StatusNotFound, resolveMismatch(uuid.New(), models.MISMATCH_RESOLUTION_WALLET, "Проверено").Code)
+}
+
+func TestReconciliation_ScheduledRunOncePerInterval(t *testing.T) {
+	SetupTestDB()
+	user := tamperedWallet(t, "alice", 50)
+
+	_, mismatches, ran, err := reconcile.RunScheduled(migrations.DB, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.Len(t, mismatches, 1)
+
+	// Другой экземпляр сервера в тот же интервал сверку пропускает и не трогает открытое расхождение.
+	_, _, ran, err = reconcile.RunScheduled(migrations.DB, time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, ran)
+
+	var mismatch models.BalanceMismatch
+	migrations.DB.Where("user_id = ?", user.ID).First(&mismatch)
+	assert.Equal(t, models.MISMATCH_STATUS_OPEN, mismatch.Status)
+	assert.Equal(t, http.StatusOK, resolveMismatch(mismatch.ID, models.MISMATCH_RESOLUTION_LEDGER, "проверено").Code)
+}
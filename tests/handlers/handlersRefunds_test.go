@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/ledger"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func reverseRecord(handler http.HandlerFunc, targetID uuid.UUID, reason string) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.ReversalRequest{Reason: reason})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/"+targetID.String(), bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"id": targetID.String()})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func topUp(t *testing.T, username string, coins uint) uuid.UUID {
+	requestBody, _ := json.Marshal(handlers.SendMoney{NickTaker: username, Coin: coins})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.PutMoneyHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body := strings.TrimSpace(w.Body.String())
+	return uuid.MustParse(body[strings.LastIndex(body, " ")+1:])
+}
+
+func TestRefundPurchase(t *testing.T) {
+	SetupTestDB()
+	stock := uint(3)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "hoody", Price: 300, Stock: &stock})
+	buyer := createBuyer(t, 1000)
+
+	bought := boughtOrder(t, buyer.ID, "hoody")
+	purchaseID := uuid.MustParse(bought.PurchaseID.(string))
+	orderID := uuid.MustParse(bought.OrderID.(string))
+
+	assert.Equal(t, http.StatusBadRequest, reverseRecord(handlers.RefundPurchaseHandler, purchaseID, " ").Code, "Причина обязательна")
+	assert.Equal(t, http.StatusNotFound, reverseRecord(handlers.RefundPurchaseHandler, uuid.New(), "Брак").Code)
+
+	w := reverseRecord(handlers.RefundPurchaseHandler, purchaseID, "Брак")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var reversal models.Reversal
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&reversal))
+	assert.Equal(t, int64(300), reversal.Amount)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(1000), wallet.Coin)
+
+	var hoody models.Merch
+	migrations.DB.First(&hoody, "name = ?", "hoody")
+	assert.Equal(t, uint(3), *hoody.Stock)
+
+	var order models.Order
+	migrations.DB.First(&order, "id = ?", orderID)
+	assert.Equal(t, models.ORDER_STATUS_CANCELLED, order.Status)
+
+	var purchases, events int64
+	migrations.DB.Model(&models.Purchase{}).Where("id = ?", purchaseID).Count(&purchases)
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ? AND target_id = ?", models.AUDIT_PURCHASE_REFUND, purchaseID.String()).Count(&events)
+	assert.Equal(t, int64(1), purchases, "Исходная покупка не удаляется")
+	assert.Equal(t, int64(1), events)
+
+	assert.Equal(t, http.StatusConflict, reverseRecord(handlers.RefundPurchaseHandler, purchaseID, "Брак").Code, "Повторный возврат")
+
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestRefundPurchase_CancelledOrder(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+	buyer := createBuyer(t, 100)
+
+	bought := boughtOrder(t, buyer.ID, "cup")
+	assert.Equal(t, http.StatusOK, cancelMyOrder(buyer.ID, uuid.MustParse(bought.OrderID.(string))).Code)
+
+	w := reverseRecord(handlers.RefundPurchaseHandler, uuid.MustParse(bought.PurchaseID.(string)), "Брак")
+	assert.Equal(t, http.StatusConflict, w.Code, "Монеты за отмененный заказ уже возвращены")
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(100), wallet.Coin)
+}
+
+func TestReverseTopUp(t *testing.T) {
+	SetupTestDB()
+	buyer := createBuyer(t, 0)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+
+	mistaken := topUp(t, buyer.Username, 100)
+	spent := topUp(t, buyer.Username, 50)
+
+	w := reverseRecord(handlers.ReverseTopUpHandler, mistaken, "Начислено не тому сотруднику")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var reversal models.Reversal
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&reversal))
+	assert.Equal(t, int64(-100), reversal.Amount)
+	assert.Equal(t, buyer.ID, reversal.UserID)
+
+	var debit models.Transaction
+	assert.NoError(t, migrations.DB.Where("kind = ?", models.TRANSACTION_KIND_DEBIT).First(&debit).Error, "Отмена должна появиться в истории сотрудника")
+	assert.Equal(t, buyer.ID, debit.FromUser)
+	assert.Equal(t, models.TreasuryUserID, debit.ToUser)
+	assert.Equal(t, uint(100), debit.Amount)
+	assert.Equal(t, "Начислено не тому сотруднику", debit.Reason)
+
+	assert.Equal(t, http.StatusConflict, reverseRecord(handlers.ReverseTopUpHandler, mistaken, "Повтор").Code)
+
+	boughtOrder(t, buyer.ID, "cup")
+	w = reverseRecord(handlers.ReverseTopUpHandler, spent, "Ошибка")
+	assert.Equal(t, http.StatusConflict, w.Code, "Потраченные монеты списать нельзя")
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(30), wallet.Coin)
+
+	var events int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ?", models.AUDIT_TOP_UP_REVERSAL).Count(&events)
+	assert.Equal(t, int64(1), events)
+}
@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func register(body handlers.RegisterRequest) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+	handlers.RegisterHandler(w, req)
+	return w
+}
+
+func TestRegisterHandler_Success(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("STARTING_BALANCE", "250")
+
+	w := register(handlers.RegisterRequest{Email: "New.User@Example.com", Password: "password123"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response handlers.RegisterResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Username)
+	assert.NotEmpty(t, response.AccessToken)
+	assert.Equal(t, uint(250), response.Balance)
+
+	var user models.User
+	err = migrations.DB.Where("email = ?", "new.user@example.com").First(&user).Error
+	assert.NoError(t, err)
+	assert.Equal(t, models.EMPLOYEE_ROLE, user.Role)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", user.ID)
+	assert.Equal(t, uint(250), wallet.Coin)
+}
+
+func TestRegisterHandler_LoginWithMixedCaseEmail(t *testing.T) {
+	SetupTestDB()
+
+	w := register(handlers.RegisterRequest{Email: "Ivan@Corp.com", Password: "password123"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	for _, email := range []string{"Ivan@Corp.com", " IVAN@corp.com "} {
+		requestBody, _ := json.Marshal(map[string]string{"email": email, "password": "password123"})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(requestBody))
+		w := httptest.NewRecorder()
+		handlers.AuthHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Вход с email %q", email)
+	}
+}
+
+func TestNormalizeEmails_LegacyMixedCaseAccounts(t *testing.T) {
+	SetupTestDB()
+
+	password, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	// Учетные записи, созданные до нормализации email с исходным написанием.
+	legacy := models.User{ID: uuid.New(), Username: "legacy", Email: "Legacy@Corp.com", Password: string(password)}
+	older := models.User{ID: uuid.New(), Username: "older", Email: "Twin@Corp.com", Password: string(password), CreatedAt: time.Now().Add(-time.Hour)}
+	newer := models.User{ID: uuid.New(), Username: "newer", Email: "TWIN@corp.com", Password: string(password)}
+	for _, user := range []*models.User{&legacy, &older, &newer} {
+		assert.NoError(t, migrations.DB.Create(user).Error)
+	}
+
+	assert.NoError(t, migrations.NormalizeEmails(migrations.DB))
+
+	requestBody, _ := json.Marshal(map[string]string{"email": "Legacy@Corp.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+	handlers.AuthHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// При совпадении нормализуется самая ранняя учетная запись, остальные остаются как есть.
+	migrations.DB.First(&older, "id = ?", older.ID)
+	migrations.DB.First(&newer, "id = ?", newer.ID)
+	assert.Equal(t, "twin@corp.com", older.Email)
+	assert.Equal(t, "TWIN@corp.com", newer.Email)
+}
+
+func TestRegisterHandler_DuplicateEmail(t *testing.T) {
+	SetupTestDB()
+
+	first := register(handlers.RegisterRequest{Email: "user@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	second := register(handlers.RegisterRequest{Email: "user@example.com", Password: "password123"})
+	assert.Equal(t, http.StatusConflict, second.Code)
+}
+
+func TestRegisterHandler_InvalidInput(t *testing.T) {
+	SetupTestDB()
+
+	w := register(handlers.RegisterRequest{Email: "not-an-email", Password: "password123"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = register(handlers.RegisterRequest{Email: "user@example.com", Password: "123"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRegisterHandler_DomainNotAllowed(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("REGISTRATION_ALLOWED_DOMAINS", "company.com, company.ru")
+
+	w := register(handlers.RegisterRequest{Email: "user@gmail.com", Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = register(handlers.RegisterRequest{Email: "user@company.ru", Password: "password123"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestRegisterHandler_InviteCode(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("REGISTRATION_ALLOWED_DOMAINS", "company.com")
+	t.Setenv("REGISTRATION_INVITE_REQUIRED", "true")
+
+	adminID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/invites", bytes.NewReader([]byte(`{"expiresInHours": 1}`)))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, adminID))
+	w := httptest.NewRecorder()
+	handlers.CreateInviteHandler(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var invite models.InviteCode
+	err := json.NewDecoder(w.Body).Decode(&invite)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, invite.Code)
+
+	var event models.AuditEvent
+	assert.NoError(t, migrations.DB.Where("action = ? AND target_id = ?", models.AUDIT_INVITE_CREATE, invite.ID.String()).First(&event).Error)
+	assert.Equal(t, adminID, *event.ActorID)
+	assert.NotContains(t, event.After, "code", "Код приглашения не должен попадать в журнал аудита")
+
+	withoutInvite := register(handlers.RegisterRequest{Email: "user@company.com", Password: "password123"})
+	assert.Equal(t, http.StatusForbidden, withoutInvite.Code)
+
+	withInvite := register(handlers.RegisterRequest{Email: "contractor@gmail.com", Password: "password123", InviteCode: invite.Code})
+	assert.Equal(t, http.StatusCreated, withInvite.Code)
+
+	reused := register(handlers.RegisterRequest{Email: "other@gmail.com", Password: "password123", InviteCode: invite.Code})
+	assert.Equal(t, http.StatusForbidden, reused.Code)
+}
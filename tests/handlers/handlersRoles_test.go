@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func changeRoleRequest(handler http.HandlerFunc, actorID uuid.UUID, username string) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(handlers.RoleChangeRequest{Username: username, Reason: "test"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/roles", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, actorID))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestBootstrapAdmin_CreatesOnlyFirstAdmin(t *testing.T) {
+	SetupTestDB()
+
+	admin, created, err := handlers.BootstrapAdmin(migrations.DB, "root@example.com", "password123")
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, models.ADMIN_ROLE, admin.Role)
+
+	second, created, err := handlers.BootstrapAdmin(migrations.DB, "other@example.com", "password123")
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, admin.ID, second.ID)
+
+	var count int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ?", models.AUDIT_ADMIN_BOOTSTRAP).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestBootstrapAdmin_PromotesExistingUser(t *testing.T) {
+	SetupTestDB()
+
+	user := models.User{ID: uuid.New(), Username: "worker", Email: "worker@example.com", Role: models.EMPLOYEE_ROLE}
+	migrations.DB.Create(&user)
+
+	admin, created, err := handlers.BootstrapAdmin(migrations.DB, "worker@example.com", "")
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, user.ID, admin.ID)
+
+	migrations.DB.First(&user, "id = ?", user.ID)
+	assert.Equal(t, models.ADMIN_ROLE, user.Role)
+}
+
+func TestBootstrapAdmin_NormalisesEmail(t *testing.T) {
+	SetupTestDB()
+
+	admin, created, err := handlers.BootstrapAdmin(migrations.DB, " Admin@Corp.com ", "password123")
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "admin@corp.com", admin.Email)
+
+	requestBody, _ := json.Marshal(map[string]string{"email": "Admin@Corp.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth", bytes.NewReader(requestBody))
+	w := httptest.NewRecorder()
+	handlers.AuthHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "Администратор должен входить с тем же email")
+
+	_, _, err = handlers.BootstrapAdmin(migrations.DB, "not-an-email", "password123")
+	assert.Error(t, err)
+}
+
+func TestGrantAndRevokeAdmin(t *testing.T) {
+	SetupTestDB()
+
+	admin, _, err := handlers.BootstrapAdmin(migrations.DB, "root@example.com", "password123")
+	assert.NoError(t, err)
+
+	worker := models.User{ID: uuid.New(), Username: "worker", Email: "worker@example.com", Role: models.EMPLOYEE_ROLE}
+	migrations.DB.Create(&worker)
+
+	w := changeRoleRequest(handlers.GrantRoleHandler, admin.ID, "worker")
+	assert.Equal(t, http.StatusOK, w.Code)
+	migrations.DB.First(&worker, "id = ?", worker.ID)
+	assert.Equal(t, models.ADMIN_ROLE, worker.Role)
+
+	w = changeRoleRequest(handlers.GrantRoleHandler, admin.ID, "worker")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = changeRoleRequest(handlers.RevokeRoleHandler, worker.ID, admin.Username)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var wallet models.Wallet
+	err = migrations.DB.First(&wallet, "user_id = ?", admin.ID).Error
+	assert.NoError(t, err, "У администратора, созданного bootstrap-admin, должен быть кошелек")
+
+	var events []models.AuditEvent
+	migrations.DB.Where("action IN ?", []string{models.AUDIT_ROLE_GRANT, models.AUDIT_ROLE_REVOKE}).Find(&events)
+	assert.Len(t, events, 2)
+}
+
+func TestRevokeAdmin_LastAdmin(t *testing.T) {
+	SetupTestDB()
+
+	admin, _, err := handlers.BootstrapAdmin(migrations.DB, "root@example.com", "password123")
+	assert.NoError(t, err)
+
+	w := changeRoleRequest(handlers.RevokeRoleHandler, admin.ID, admin.Username)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGrantAdmin_UserNotFound(t *testing.T) {
+	SetupTestDB()
+
+	w := changeRoleRequest(handlers.GrantRoleHandler, uuid.New(), "nobody")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
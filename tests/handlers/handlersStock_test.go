@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func buyItem(userID uuid.UUID, item string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/buy/"+item, nil)
+	req = mux.SetURLVars(req, map[string]string{"item": item})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.BuyItemHandler(w, req)
+	return w
+}
+
+func restock(item string, body handlers.RestockRequest) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/merch/"+item+"/restock", bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"item": item})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.RestockMerchHandler(w, req)
+	return w
+}
+
+func createBuyer(t *testing.T, coins uint) models.User {
+	user := models.User{ID: uuid.New(), Username: "buyer", Email: "buyer@example.com", Password: "x"}
+	assert.NoError(t, migrations.DB.Create(&user).Error)
+	assert.NoError(t, migrations.DB.Create(&models.Wallet{UserID: user.ID, Coin: coins}).Error)
+	return user
+}
+
+func TestBuyItemHandler_StockDecrementAndSoldOut(t *testing.T) {
+	SetupTestDB()
+
+	stock := uint(2)
+	merch := models.Merch{ID: uuid.New(), Name: "hoody", Price: 100, Stock: &stock, LowStockThreshold: 1}
+	migrations.DB.Create(&merch)
+	buyer := createBuyer(t, 1000)
+
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "hoody").Code)
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "hoody").Code)
+
+	w := buyItem(buyer.ID, "hoody")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "Товар закончился")
+
+	migrations.DB.First(&merch, "id = ?", merch.ID)
+	assert.Equal(t, uint(0), *merch.Stock)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(800), wallet.Coin, "Покупка распроданного товара не должна списывать монеты")
+}
+
+func TestBuyItemHandler_UnlimitedStock(t *testing.T) {
+	SetupTestDB()
+
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "pen", Price: 10})
+	buyer := createBuyer(t, 100)
+
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "pen").Code)
+
+	var merch models.Merch
+	migrations.DB.First(&merch, "name = ?", "pen")
+	assert.Nil(t, merch.Stock)
+}
+
+func TestRestockMerchHandler(t *testing.T) {
+	SetupTestDB()
+
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+
+	threshold := uint(5)
+	w := restock("cup", handlers.RestockRequest{Quantity: 3, LowStockThreshold: &threshold})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = restock("cup", handlers.RestockRequest{Quantity: 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var info handlers.StockInfo
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&info))
+	assert.Equal(t, uint(7), *info.Stock)
+	assert.Equal(t, uint(5), info.LowStockThreshold)
+
+	var count int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ?", models.AUDIT_MERCH_RESTOCK).Count(&count)
+	assert.Equal(t, int64(2), count)
+
+	assert.Equal(t, http.StatusNotFound, restock("missing", handlers.RestockRequest{Quantity: 1}).Code)
+	assert.Equal(t, http.StatusBadRequest, restock("cup", handlers.RestockRequest{}).Code)
+}
+
+func TestLowStockHandler(t *testing.T) {
+	SetupTestDB()
+
+	low, enough := uint(2), uint(50)
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "umbrella", Price: 200, Stock: &low, LowStockThreshold: 3})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "socks", Price: 10, Stock: &enough, LowStockThreshold: 3})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "book", Price: 50})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/merch/low-stock", nil)
+	w := httptest.NewRecorder()
+	handlers.LowStockHandler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report []handlers.StockInfo
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.Len(t, report, 1)
+	assert.Equal(t, "umbrella", report[0].Item)
+}
@@ -0,0 +1,119 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func listTransactions(t *testing.T, userID uuid.UUID, query string) (int, handlers.TransactionsPage) {
+	req := httptest.NewRequest(http.MethodGet, "/api/transactions?"+query, nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	handlers.TransactionsHandler(w, req)
+
+	var page handlers.TransactionsPage
+	if w.Code == http.StatusOK {
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	}
+	return w.Code, page
+}
+
+func seedTransactions(t *testing.T) (models.User, models.User, models.User, time.Time) {
+	alice := models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", Password: "x"}
+	carol := models.User{ID: uuid.New(), Username: "carol", Email: "carol@example.com", Password: "x"}
+	for _, user := range []*models.User{&alice, &bob, &carol} {
+		assert.NoError(t, migrations.DB.Create(user).Error)
+	}
+
+	base := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	transactions := []models.Transaction{
+		{FromUser: bob.ID, ToUser: alice.ID, Amount: 10, CreatedAt: base},
+		{FromUser: alice.ID, ToUser: bob.ID, Amount: 20, CreatedAt: base.Add(time.Hour), Memo: "за обед"},
+		{FromUser: carol.ID, ToUser: alice.ID, Amount: 30, CreatedAt: base.Add(2 * time.Hour)},
+		{FromUser: alice.ID, ToUser: carol.ID, Amount: 40, CreatedAt: base.Add(3 * time.Hour)},
+		{FromUser: bob.ID, ToUser: carol.ID, Amount: 50, CreatedAt: base.Add(4 * time.Hour)},
+	}
+	for i := range transactions {
+		transactions[i].ID = uuid.New()
+		assert.NoError(t, migrations.DB.Create(&transactions[i]).Error)
+	}
+	return alice, bob, carol, base
+}
+
+func TestTransactionsHandler_Pagination(t *testing.T) {
+	SetupTestDB()
+	alice, _, _, _ := seedTransactions(t)
+
+	var amounts []uint
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		code, response := listTransactions(t, alice.ID, "limit=3&cursor="+cursor)
+		assert.Equal(t, http.StatusOK, code)
+		for _, item := range response.Transactions {
+			amounts = append(amounts, item.Amount)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		cursor = response.NextCursor
+	}
+
+	assert.Equal(t, []uint{40, 30, 20, 10}, amounts)
+}
+
+func TestTransactionsHandler_Fields(t *testing.T) {
+	SetupTestDB()
+	alice, _, _, _ := seedTransactions(t)
+
+	code, page := listTransactions(t, alice.ID, "counterparty=bob&direction=out")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Transactions, 1)
+	item := page.Transactions[0]
+	assert.NotEqual(t, uuid.Nil, item.ID)
+	assert.Equal(t, handlers.DIRECTION_OUT, item.Direction)
+	assert.Equal(t, "bob", item.Counterparty)
+	assert.Equal(t, uint(20), item.Amount)
+	assert.Equal(t, "за обед", item.Memo)
+	assert.False(t, item.CreatedAt.IsZero())
+}
+
+func TestTransactionsHandler_Filters(t *testing.T) {
+	SetupTestDB()
+	alice, _, _, base := seedTransactions(t)
+
+	code, page := listTransactions(t, alice.ID, "direction=in")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Transactions, 2)
+
+	code, page = listTransactions(t, alice.ID, "minAmount=20&maxAmount=30")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Transactions, 2)
+
+	from := base.Add(30 * time.Minute).Format(time.RFC3339)
+	to := base.Add(150 * time.Minute).Format(time.RFC3339)
+	code, page = listTransactions(t, alice.ID, "from="+from+"&to="+to)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, page.Transactions, 2)
+}
+
+func TestTransactionsHandler_InvalidParams(t *testing.T) {
+	code, _ := listTransactions(t, uuid.New(), "direction=sideways")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = listTransactions(t, uuid.New(), "from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = listTransactions(t, uuid.New(), "minAmount=-5")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
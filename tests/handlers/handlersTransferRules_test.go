@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sendCoin(senderID uuid.UUID, toUser string, coin uint) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"toUser": %q, "coin": %d}`, toUser, coin)
+	req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, senderID))
+	w := httptest.NewRecorder()
+	handlers.SendCoinHandler(w, req)
+	return w
+}
+
+func TestSendCoinHandler_TransferRules(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("TRANSFER_MAX_AMOUNT", "300")
+	t.Setenv("TRANSFER_DAILY_LIMIT", "500")
+	t.Setenv("TRANSFER_RECIPIENT_DAILY_LIMIT", "350")
+	ivan := createEmployee(t, "ivan", 1000)
+	createEmployee(t, "petr", 0)
+	createEmployee(t, "olga", 0)
+
+	assert.Equal(t, http.StatusOK, sendCoin(ivan.ID, "petr", 300).Code)
+
+	w := sendCoin(ivan.ID, "petr", 100)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var rejected handlers.TransferRejected
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&rejected))
+	assert.Len(t, rejected.Violations, 1)
+	assert.Equal(t, models.TRANSFER_RULE_RECIPIENT_LIMIT, rejected.Violations[0].Rule)
+	assert.Equal(t, int64(350), rejected.Violations[0].Limit)
+	assert.Equal(t, int64(300), rejected.Violations[0].Used)
+
+	w = sendCoin(ivan.ID, "olga", 301)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&rejected))
+	rules := []string{}
+	for _, violation := range rejected.Violations {
+		rules = append(rules, violation.Rule)
+	}
+	assert.ElementsMatch(t, []string{models.TRANSFER_RULE_MAX_AMOUNT, models.TRANSFER_RULE_DAILY_LIMIT}, rules)
+
+	assert.Equal(t, http.StatusOK, sendCoin(ivan.ID, "olga", 200).Code)
+	assert.Equal(t, uint(500), walletCoin(t, ivan.ID), "Отклоненные переводы не списывают монеты")
+
+	var blocked []models.BlockedTransfer
+	assert.NoError(t, migrations.DB.Order("created_at").Find(&blocked).Error)
+	assert.Len(t, blocked, 2)
+	assert.Equal(t, ivan.ID, blocked[0].SenderID)
+	assert.Equal(t, uint(100), blocked[0].Amount)
+	assert.Equal(t, models.BLOCKED_TRANSFER_OPEN, blocked[0].Status)
+}
+
+func TestSendCoinHandler_VelocityAndNewAccount(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("TRANSFER_MAX_PER_HOUR", "2")
+	ivan := createEmployee(t, "ivan", 100)
+	createEmployee(t, "petr", 0)
+
+	assert.Equal(t, http.StatusOK, sendCoin(ivan.ID, "petr", 1).Code)
+	assert.Equal(t, http.StatusOK, sendCoin(ivan.ID, "petr", 1).Code)
+	w := sendCoin(ivan.ID, "petr", 1)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), models.TRANSFER_RULE_VELOCITY)
+
+	t.Setenv("TRANSFER_MAX_PER_HOUR", "0")
+	t.Setenv("NEW_ACCOUNT_TRANSFER_COOLDOWN", "72h")
+	w = sendCoin(ivan.ID, "petr", 1)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), models.TRANSFER_RULE_NEW_ACCOUNT)
+
+	migrations.DB.Model(&ivan).UpdateColumn("created_at", time.Now().Add(-73*time.Hour))
+	assert.Equal(t, http.StatusOK, sendCoin(ivan.ID, "petr", 1).Code)
+	assert.Equal(t, uint(97), walletCoin(t, ivan.ID))
+}
+
+func TestReviewBlockedTransferHandler(t *testing.T) {
+	SetupTestDB()
+	t.Setenv("TRANSFER_MAX_AMOUNT", "10")
+	admin := models.User{ID: uuid.New(), Username: "admin", Email: "admin@example.com", Role: models.ADMIN_ROLE}
+	migrations.DB.Create(&admin)
+	ivan := createEmployee(t, "ivan", 100)
+	createEmployee(t, "petr", 0)
+
+	var rejected handlers.TransferRejected
+	assert.NoError(t, json.NewDecoder(sendCoin(ivan.ID, "petr", 50).Body).Decode(&rejected))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/blocked-transfers?userId="+ivan.ID.String(), nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, admin.ID))
+	w := httptest.NewRecorder()
+	handlers.BlockedTransfersHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var blocked []models.BlockedTransfer
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&blocked))
+	assert.Len(t, blocked, 1)
+	assert.Equal(t, rejected.BlockedID, blocked[0].ID)
+
+	review := func(note string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"note": %q}`, note)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/blocked-transfers/"+rejected.BlockedID.String()+"/review", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": rejected.BlockedID.String()})
+		req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, admin.ID))
+		w := httptest.NewRecorder()
+		handlers.ReviewBlockedTransferHandler(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, review(" ").Code)
+	assert.Equal(t, http.StatusOK, review("Ошибся суммой").Code)
+	assert.Equal(t, http.StatusConflict, review("Повторно").Code)
+
+	var event models.AuditEvent
+	assert.NoError(t, migrations.DB.Where("action = ?", models.AUDIT_TRANSFER_REVIEW).First(&event).Error)
+	assert.Equal(t, rejected.BlockedID.String(), event.TargetID)
+}
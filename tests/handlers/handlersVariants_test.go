@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func putVariant(item, sku string, body handlers.VariantRequest) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/merch/"+item+"/variants/"+sku, bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"item": item, "sku": sku})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.PutVariantHandler(w, req)
+	return w
+}
+
+func TestPutVariantHandler(t *testing.T) {
+	SetupTestDB()
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "t-shirt", Price: 80})
+	migrations.DB.Create(&models.Merch{ID: uuid.New(), Name: "cup", Price: 20})
+
+	price, stock := uint(90), uint(3)
+	w := putVariant("t-shirt", "TS-L-BLACK", handlers.VariantRequest{
+		Attributes: map[string]string{"size": "L", "color": "black"},
+		Price:      &price,
+		Stock:      &stock,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var variant models.MerchVariant
+	assert.NoError(t, migrations.DB.First(&variant, "sku = ?", "TS-L-BLACK").Error)
+	assert.Equal(t, "black", variant.Attributes["color"])
+	assert.Equal(t, uint(90), *variant.Price)
+
+	assert.Equal(t, http.StatusConflict, putVariant("cup", "TS-L-BLACK", handlers.VariantRequest{}).Code)
+	assert.Equal(t, http.StatusConflict, putVariant("t-shirt", "cup", handlers.VariantRequest{}).Code)
+	assert.Equal(t, http.StatusNotFound, putVariant("missing", "X-1", handlers.VariantRequest{}).Code)
+	assert.Equal(t, http.StatusBadRequest, putVariant("t-shirt", "bad sku", handlers.VariantRequest{}).Code)
+}
+
+func TestBuyItemHandler_Variant(t *testing.T) {
+	SetupTestDB()
+	merch := models.Merch{ID: uuid.New(), Name: "t-shirt", Price: 80}
+	migrations.DB.Create(&merch)
+
+	price, stock := uint(90), uint(1)
+	migrations.DB.Create(&models.MerchVariant{MerchID: merch.ID, SKU: "TS-L", Attributes: map[string]string{"size": "L"}, Price: &price, Stock: &stock})
+	migrations.DB.Create(&models.MerchVariant{MerchID: merch.ID, SKU: "TS-M", Attributes: map[string]string{"size": "M"}})
+	buyer := createBuyer(t, 1000)
+
+	w := buyItem(buyer.ID, "t-shirt")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Мерч с вариантами покупается по SKU")
+
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "TS-L").Code)
+	assert.Equal(t, http.StatusConflict, buyItem(buyer.ID, "TS-L").Code)
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "TS-M").Code)
+	assert.Equal(t, http.StatusOK, buyItem(buyer.ID, "TS-M").Code)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", buyer.ID)
+	assert.Equal(t, uint(1000-90-80-80), wallet.Coin)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, buyer.ID))
+	info := httptest.NewRecorder()
+	handlers.InformationHandler(info, req)
+	assert.Equal(t, http.StatusOK, info.Code)
+
+	var response handlers.InfoMain
+	assert.NoError(t, json.NewDecoder(info.Body).Decode(&response))
+	quantities := map[string]int{}
+	for _, item := range response.Inventory {
+		assert.Equal(t, "t-shirt", item.Type)
+		quantities[item.SKU] = item.Quantity
+	}
+	assert.Equal(t, map[string]int{"TS-L": 1, "TS-M": 2}, quantities)
+}
+
+func TestShowMerchHandler_Variants(t *testing.T) {
+	SetupTestDB()
+	merch := models.Merch{ID: uuid.New(), Name: "hoody", Price: 300}
+	migrations.DB.Create(&merch)
+	migrations.DB.Create(&models.MerchVariant{MerchID: merch.ID, SKU: "HD-XL", Attributes: map[string]string{"size": "XL"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/merch", nil)
+	w := httptest.NewRecorder()
+	handlers.ShowMerchHandler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var merches []models.Merch
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&merches))
+	assert.Len(t, merches, 1)
+	assert.Len(t, merches[0].Variants, 1)
+	assert.Equal(t, "XL", merches[0].Variants[0].Attributes["size"])
+}
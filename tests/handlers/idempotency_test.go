@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sendCoinWithKey(senderID uuid.UUID, key string, body map[string]interface{}) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sendCoin", bytes.NewReader(requestBody))
+	req.Header.Set(utils.IdempotencyHeader, key)
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, senderID))
+
+	w := httptest.NewRecorder()
+	utils.IdempotencyMiddleware(http.HandlerFunc(handlers.SendCoinHandler)).ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	SetupTestDB()
+
+	sender := models.User{ID: uuid.New(), Username: "sender", Email: "sender@example.com"}
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	body := map[string]interface{}{"toUser": "receiver", "coin": 10}
+
+	first := sendCoinWithKey(sender.ID, "retry-1", body)
+	second := sendCoinWithKey(sender.ID, "retry-1", body)
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", sender.ID)
+	assert.Equal(t, uint(90), wallet.Coin, "Повторный запрос не должен списывать монеты второй раз")
+
+	var count int64
+	migrations.DB.Model(&models.Transaction{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestIdempotency_KeyReusedWithDifferentBody(t *testing.T) {
+	SetupTestDB()
+
+	sender := models.User{ID: uuid.New(), Username: "sender", Email: "sender@example.com"}
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	first := sendCoinWithKey(sender.ID, "retry-2", map[string]interface{}{"toUser": "receiver", "coin": 10})
+	second := sendCoinWithKey(sender.ID, "retry-2", map[string]interface{}{"toUser": "receiver", "coin": 20})
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, second.Code)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", sender.ID)
+	assert.Equal(t, uint(90), wallet.Coin)
+}
+
+func TestIdempotency_InProgressConflict(t *testing.T) {
+	SetupTestDB()
+
+	sender := models.User{ID: uuid.New(), Username: "sender", Email: "sender@example.com"}
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	body := map[string]interface{}{"toUser": "receiver", "coin": 10}
+	first := sendCoinWithKey(sender.ID, "retry-3", body)
+	assert.Equal(t, http.StatusOK, first.Code)
+	// Ответ еще не записан: первый запрос выполняется.
+	migrations.DB.Model(&models.IdempotencyKey{}).Where("key = ?", "retry-3").Update("status_code", 0)
+
+	second := sendCoinWithKey(sender.ID, "retry-3", body)
+	assert.Equal(t, http.StatusConflict, second.Code)
+}
+
+func TestIdempotency_AbandonedKeyCanBeRetried(t *testing.T) {
+	SetupTestDB()
+
+	sender := models.User{ID: uuid.New(), Username: "sender", Email: "sender@example.com"}
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	// Процесс упал во время запроса: ключ занят, ответ не записан, перевод не выполнен.
+	migrations.DB.Create(&models.IdempotencyKey{
+		UserID:      sender.ID,
+		Key:         "retry-4",
+		Method:      http.MethodPost,
+		Path:        "/api/sendCoin",
+		Fingerprint: "abandoned",
+		CreatedAt:   time.Now().Add(-11 * time.Minute),
+	})
+
+	retry := sendCoinWithKey(sender.ID, "retry-4", map[string]interface{}{"toUser": "receiver", "coin": 10})
+	assert.Equal(t, http.StatusOK, retry.Code, "Брошенный ключ должен освобождаться для повтора")
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", sender.ID)
+	assert.Equal(t, uint(90), wallet.Coin)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	SetupTestDB()
+
+	sender := models.User{ID: uuid.New(), Username: "sender", Email: "sender@example.com"}
+	receiver := models.User{ID: uuid.New(), Username: "receiver", Email: "receiver@example.com"}
+	migrations.DB.Create(&sender)
+	migrations.DB.Create(&receiver)
+	migrations.DB.Create(&models.Wallet{UserID: sender.ID, Coin: 100})
+	migrations.DB.Create(&models.Wallet{UserID: receiver.ID, Coin: 50})
+
+	body := map[string]interface{}{"toUser": "receiver", "coin": 10}
+	sendCoinWithKey(sender.ID, "", body)
+	sendCoinWithKey(sender.ID, "", body)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", sender.ID)
+	assert.Equal(t, uint(80), wallet.Coin)
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/handlers"
+	"Shop/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func createUserWithRole(t *testing.T, username, role string) string {
+	user := models.User{ID: uuid.New(), Username: username, Email: username + "@example.com", Password: "x", Role: role}
+	assert.NoError(t, migrations.DB.Create(&user).Error)
+	token, err := utils.GenerateJWT(user.ID, user.Email)
+	assert.NoError(t, err)
+	return token
+}
+
+func callProtected(permission, token string) int {
+	handler := utils.AuthMiddleware(permission)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code
+}
+
+func putRole(actorID uuid.UUID, name string, body handlers.RoleRequest) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/roles/"+name, bytes.NewReader(requestBody))
+	req = mux.SetURLVars(req, map[string]string{"name": name})
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, actorID))
+	w := httptest.NewRecorder()
+	handlers.PutRoleHandler(w, req)
+	return w
+}
+
+func TestAuthMiddleware_AdminHasEmployeePermissions(t *testing.T) {
+	SetupTestDB()
+
+	token := createUserWithRole(t, "boss", models.ADMIN_ROLE)
+	assert.Equal(t, http.StatusOK, callProtected(models.PERMISSION_INFO_READ, token))
+	assert.Equal(t, http.StatusOK, callProtected(models.PERMISSION_COINS_MINT, token))
+}
+
+func TestAuthMiddleware_EmployeeForbiddenForAdminPermission(t *testing.T) {
+	SetupTestDB()
+
+	token := createUserWithRole(t, "worker", models.EMPLOYEE_ROLE)
+	assert.Equal(t, http.StatusOK, callProtected(models.PERMISSION_INFO_READ, token))
+	assert.Equal(t, http.StatusForbidden, callProtected(models.PERMISSION_MERCH_WRITE, token))
+}
+
+func TestPutRoleHandler_CustomRole(t *testing.T) {
+	SetupTestDB()
+
+	w := putRole(uuid.New(), "CATALOG_MANAGER", handlers.RoleRequest{
+		Description: "Менеджер каталога",
+		Permissions: []string{models.PERMISSION_MERCH_WRITE, models.PERMISSION_INFO_READ},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var role handlers.RoleInfo
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&role))
+	assert.ElementsMatch(t, []string{models.PERMISSION_INFO_READ, models.PERMISSION_MERCH_WRITE}, role.Permissions)
+
+	token := createUserWithRole(t, "manager", "CATALOG_MANAGER")
+	assert.Equal(t, http.StatusOK, callProtected(models.PERMISSION_MERCH_WRITE, token))
+	assert.Equal(t, http.StatusForbidden, callProtected(models.PERMISSION_COINS_MINT, token))
+
+	var count int64
+	migrations.DB.Model(&models.AuditEvent{}).Where("action = ? AND target_id = ?", models.AUDIT_ROLE_UPDATE, "CATALOG_MANAGER").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPutRoleHandler_Validation(t *testing.T) {
+	SetupTestDB()
+
+	w := putRole(uuid.New(), "BROKEN", handlers.RoleRequest{Permissions: []string{"coins:steal"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = putRole(uuid.New(), models.ADMIN_ROLE, handlers.RoleRequest{Permissions: []string{}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = putRole(uuid.New(), "bad name", handlers.RoleRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGrantRoleHandler_UnknownRole(t *testing.T) {
+	SetupTestDB()
+
+	createUserWithRole(t, "worker", models.EMPLOYEE_ROLE)
+	requestBody, _ := json.Marshal(handlers.RoleChangeRequest{Username: "worker", Role: "NO_SUCH_ROLE"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/roles/grant", bytes.NewReader(requestBody))
+	req = req.WithContext(context.WithValue(req.Context(), utils.UserIDKey, uuid.New()))
+	w := httptest.NewRecorder()
+	handlers.GrantRoleHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,257 @@
+package ledger_test
+
+import (
+	"Shop/config"
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"Shop/ledger"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("POSTGRES_HOST", "localhost")
+	os.Setenv("POSTGRES_USERNAME", "testuser")
+	os.Setenv("POSTGRES_PASSWORD", "testpassword")
+	os.Setenv("POSTGRES_DATABASE", "testdb")
+	os.Setenv("POSTGRES_PORT", "5433")
+	os.Setenv("REDIS_HOST", "localhost")
+	os.Setenv("REDIS_PORT", "6379")
+	migrations.InitDB()
+	config.InitRedis()
+	os.Exit(m.Run())
+}
+
+func SetupTestDB() {
+	if migrations.DB == nil {
+		log.Fatal("Database connection is not initialized")
+	}
+	migrations.DB.Exec("DELETE FROM users")
+	migrations.DB.Exec("DELETE FROM wallets")
+	migrations.DB.Exec("DELETE FROM postings")
+	migrations.DB.Exec("DELETE FROM journal_entries")
+	migrations.DB.Exec("DELETE FROM ledger_accounts")
+	migrations.DB.Exec("DELETE FROM coin_lots")
+	if config.Rdb != nil {
+		config.Rdb.FlushAll(context.Background())
+	}
+}
+
+func TestOpenWallet(t *testing.T) {
+	SetupTestDB()
+
+	userID := uuid.New()
+	wallet, err := ledger.OpenWallet(migrations.DB, userID, 1000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1000), wallet.Coin)
+
+	account, err := ledger.UserAccount(migrations.DB, userID)
+	assert.NoError(t, err)
+	balance, err := ledger.Balance(migrations.DB, account.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), balance)
+}
+
+func TestOpenWallet_ZeroBalance(t *testing.T) {
+	SetupTestDB()
+
+	wallet, err := ledger.OpenWallet(migrations.DB, uuid.New(), 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), wallet.Coin)
+}
+
+func TestUserAccount_OpeningForExistingWallet(t *testing.T) {
+	SetupTestDB()
+
+	userID := uuid.New()
+	migrations.DB.Create(&models.Wallet{UserID: userID, Coin: 300})
+
+	account, err := ledger.UserAccount(migrations.DB, userID)
+	assert.NoError(t, err)
+
+	balance, err := ledger.Balance(migrations.DB, account.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), balance)
+
+	var wallet models.Wallet
+	migrations.DB.First(&wallet, "user_id = ?", userID)
+	assert.Equal(t, uint(300), wallet.Coin, "Запись OPENING не должна менять кошелек")
+}
+
+func TestTransfer_Success(t *testing.T) {
+	SetupTestDB()
+
+	senderID, takerID := uuid.New(), uuid.New()
+	_, err := ledger.OpenWallet(migrations.DB, senderID, 100)
+	assert.NoError(t, err)
+	_, err = ledger.OpenWallet(migrations.DB, takerID, 50)
+	assert.NoError(t, err)
+
+	sender, _ := ledger.UserAccount(migrations.DB, senderID)
+	taker, _ := ledger.UserAccount(migrations.DB, takerID)
+
+	entry, err := ledger.Transfer(migrations.DB, sender, taker, 30, models.ENTRY_TRANSFER, "")
+	assert.NoError(t, err)
+	assert.Len(t, entry.Postings, 2)
+
+	var senderWallet, takerWallet models.Wallet
+	migrations.DB.First(&senderWallet, "user_id = ?", senderID)
+	migrations.DB.First(&takerWallet, "user_id = ?", takerID)
+	assert.Equal(t, uint(70), senderWallet.Coin)
+	assert.Equal(t, uint(80), takerWallet.Coin)
+
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.True(t, report.Balanced)
+}
+
+func TestTransfer_InsufficientFunds(t *testing.T) {
+	SetupTestDB()
+
+	senderID, takerID := uuid.New(), uuid.New()
+	_, _ = ledger.OpenWallet(migrations.DB, senderID, 10)
+	_, _ = ledger.OpenWallet(migrations.DB, takerID, 0)
+
+	sender, _ := ledger.UserAccount(migrations.DB, senderID)
+	taker, _ := ledger.UserAccount(migrations.DB, takerID)
+
+	tx := migrations.DB.Begin()
+	_, err := ledger.Transfer(tx, sender, taker, 30, models.ENTRY_TRANSFER, "")
+	tx.Rollback()
+
+	assert.ErrorIs(t, err, ledger.ErrInsufficientFunds)
+}
+
+func TestPost_Unbalanced(t *testing.T) {
+	SetupTestDB()
+
+	mint, err := ledger.SystemAccount(migrations.DB, models.SYSTEM_ACCOUNT_MINT)
+	assert.NoError(t, err)
+	revenue, err := ledger.SystemAccount(migrations.DB, models.SYSTEM_ACCOUNT_REVENUE)
+	assert.NoError(t, err)
+
+	_, err = ledger.Post(migrations.DB, models.ENTRY_MINT, "",
+		ledger.Line{Account: mint, Amount: -10},
+		ledger.Line{Account: revenue, Amount: 5},
+	)
+	assert.ErrorIs(t, err, ledger.ErrUnbalancedEntry)
+}
+
+func TestVerify_DetectsDirectWalletChange(t *testing.T) {
+	SetupTestDB()
+
+	userID := uuid.New()
+	_, err := ledger.OpenWallet(migrations.DB, userID, 100)
+	assert.NoError(t, err)
+
+	migrations.DB.Model(&models.Wallet{}).Where("user_id = ?", userID).Update("coin", 500)
+
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.False(t, report.Balanced)
+	assert.Len(t, report.Mismatches, 1)
+	assert.Equal(t, int64(500), report.Mismatches[0].WalletCoin)
+	assert.Equal(t, int64(100), report.Mismatches[0].LedgerBalance)
+}
+
+func lots(t *testing.T, userID uuid.UUID) []models.CoinLot {
+	var found []models.CoinLot
+	assert.NoError(t, migrations.DB.Where("user_id = ? AND remaining > 0", userID).
+		Order("expires_at NULLS LAST, granted_at").Find(&found).Error)
+	return found
+}
+
+func TestLots_SpendEarliestExpiringFirstAndCarryExpiry(t *testing.T) {
+	SetupTestDB()
+
+	t.Setenv("COIN_EXPIRY_MONTHS", "1")
+	senderID, takerID := uuid.New(), uuid.New()
+	_, err := ledger.OpenWallet(migrations.DB, senderID, 100)
+	assert.NoError(t, err)
+	_, err = ledger.OpenWallet(migrations.DB, takerID, 0)
+	assert.NoError(t, err)
+
+	t.Setenv("COIN_EXPIRY_MONTHS", "12")
+	mint, _ := ledger.SystemAccount(migrations.DB, models.SYSTEM_ACCOUNT_MINT)
+	sender, _ := ledger.UserAccount(migrations.DB, senderID)
+	taker, _ := ledger.UserAccount(migrations.DB, takerID)
+	_, err = ledger.Transfer(migrations.DB, mint, sender, 50, models.ENTRY_MINT, "")
+	assert.NoError(t, err)
+
+	_, err = ledger.Transfer(migrations.DB, sender, taker, 120, models.ENTRY_TRANSFER, "")
+	assert.NoError(t, err)
+
+	senderLots := lots(t, senderID)
+	assert.Len(t, senderLots, 1, "Партия со стартовым балансом сгорает раньше и потрачена первой")
+	assert.Equal(t, uint(30), senderLots[0].Remaining)
+	assert.True(t, senderLots[0].ExpiresAt.After(time.Now().AddDate(0, 11, 0)))
+
+	takerLots := lots(t, takerID)
+	assert.Len(t, takerLots, 2)
+	assert.Equal(t, uint(100), takerLots[0].Remaining)
+	assert.Equal(t, uint(20), takerLots[1].Remaining)
+	assert.True(t, takerLots[0].ExpiresAt.Before(time.Now().AddDate(0, 2, 0)), "Срок переходит к получателю вместе с монетами")
+}
+
+func TestLots_UntrackedBalanceNeverExpires(t *testing.T) {
+	SetupTestDB()
+
+	t.Setenv("COIN_EXPIRY_MONTHS", "1")
+	userID := uuid.New()
+	migrations.DB.Create(&models.Wallet{UserID: userID, Coin: 300})
+
+	expired, err := ledger.ExpiredLots(migrations.DB, userID, time.Now().AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+
+	found := lots(t, userID)
+	assert.Len(t, found, 1)
+	assert.Equal(t, models.LOT_SOURCE_BALANCE, found[0].Source)
+	assert.Equal(t, uint(300), found[0].Remaining)
+	assert.Nil(t, found[0].ExpiresAt)
+}
+
+func TestPost_BalanceLimit(t *testing.T) {
+	SetupTestDB()
+
+	t.Setenv("MAX_WALLET_BALANCE", "100")
+	senderID, takerID := uuid.New(), uuid.New()
+	_, _ = ledger.OpenWallet(migrations.DB, senderID, 50)
+	_, _ = ledger.OpenWallet(migrations.DB, takerID, 90)
+	sender, _ := ledger.UserAccount(migrations.DB, senderID)
+	taker, _ := ledger.UserAccount(migrations.DB, takerID)
+
+	tx := migrations.DB.Begin()
+	_, err := ledger.Transfer(tx, sender, taker, 20, models.ENTRY_TRANSFER, "")
+	tx.Rollback()
+	assert.ErrorIs(t, err, ledger.ErrBalanceLimit)
+
+	_, err = ledger.Transfer(migrations.DB, sender, taker, 10, models.ENTRY_TRANSFER, "")
+	assert.NoError(t, err, "Ровно до предела можно")
+
+	revenue, _ := ledger.SystemAccount(migrations.DB, models.SYSTEM_ACCOUNT_REVENUE)
+	_, err = ledger.Transfer(migrations.DB, revenue, taker, 30, models.ENTRY_REFUND, "")
+	assert.NoError(t, err, "Возврат не ограничивается пределом")
+}
+
+func TestVerify_WalletWithoutAccount(t *testing.T) {
+	SetupTestDB()
+
+	userID := uuid.New()
+	migrations.DB.Create(&models.Wallet{UserID: userID, Coin: 300})
+
+	report, err := ledger.Verify(migrations.DB)
+	assert.NoError(t, err)
+	assert.False(t, report.Balanced)
+	if assert.Len(t, report.Mismatches, 1) {
+		assert.Equal(t, userID, report.Mismatches[0].UserID)
+		assert.Equal(t, int64(0), report.Mismatches[0].LedgerBalance)
+	}
+}
@@ -31,10 +31,39 @@ func SetupTestDB() {
 	}
 	migrations.DB.Exec("DELETE FROM users")
 	migrations.DB.Exec("DELETE FROM revoked_tokens")
+	migrations.DB.Exec("DELETE FROM merch_variants")
 	migrations.DB.Exec("DELETE FROM merches")
 	migrations.DB.Exec("DELETE FROM wallets")
 	migrations.DB.Exec("DELETE FROM purchases")
 	migrations.DB.Exec("DELETE FROM transactions")
+	migrations.DB.Exec("DELETE FROM postings")
+	migrations.DB.Exec("DELETE FROM journal_entries")
+	migrations.DB.Exec("DELETE FROM ledger_accounts")
+	migrations.DB.Exec("DELETE FROM idempotency_keys")
+	migrations.DB.Exec("DELETE FROM refresh_tokens")
+	migrations.DB.Exec("DELETE FROM invite_codes")
+	migrations.DB.Exec("TRUNCATE audit_events")
+	migrations.DB.Exec("DELETE FROM cart_items")
+	migrations.DB.Exec("DELETE FROM order_items")
+	migrations.DB.Exec("DELETE FROM orders")
+	migrations.DB.Exec("DELETE FROM reversals")
+	migrations.DB.Exec("DELETE FROM balance_mismatches")
+	migrations.DB.Exec("DELETE FROM reconciliation_runs")
+	migrations.DB.Exec("DELETE FROM bulk_grant_items")
+	migrations.DB.Exec("DELETE FROM bulk_grant_jobs")
+	migrations.DB.Exec("DELETE FROM allowance_grants")
+	migrations.DB.Exec("DELETE FROM allowance_runs")
+	migrations.DB.Exec("DELETE FROM allowance_rules")
+	migrations.DB.Exec("DELETE FROM coin_lots")
+	migrations.DB.Exec("DELETE FROM blocked_transfers")
+	migrations.DB.Exec("DELETE FROM role_permissions")
+	migrations.DB.Exec("DELETE FROM roles")
+	if err := migrations.SeedRoles(migrations.DB); err != nil {
+		log.Fatal(err)
+	}
+	if err := migrations.SeedTreasury(migrations.DB); err != nil {
+		log.Fatal(err)
+	}
 	if config.Rdb != nil {
 		config.Rdb.FlushAll(context.Background())
 	}
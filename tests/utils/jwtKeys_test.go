@@ -0,0 +1,158 @@
+package utils_test
+
+import (
+	"Shop/utils"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	assert.NoError(t, err)
+	return path
+}
+
+func rsaKeyFiles(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	return writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		writePEM(t, "rsa.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"userID": "test", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestKeySet_RS256SignAndVerify(t *testing.T) {
+	private, _ := rsaKeyFiles(t)
+	keys, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "rsa-1", Alg: "RS256", PrivateKeyFile: private}}, "")
+	assert.NoError(t, err)
+
+	signed, err := keys.Sign(testClaims())
+	assert.NoError(t, err)
+
+	token, err := jwt.Parse(signed, keys.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, "rsa-1", token.Header["kid"])
+}
+
+func TestKeySet_EdDSASignAndVerify(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	private := writePEM(t, "ed.pem", "PRIVATE KEY", der)
+
+	keys, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "ed-1", Alg: "EdDSA", PrivateKeyFile: private}}, "")
+	assert.NoError(t, err)
+
+	signed, err := keys.Sign(testClaims())
+	assert.NoError(t, err)
+	token, err := jwt.Parse(signed, keys.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+
+	jwks := keys.JWKS()
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+	assert.Equal(t, "ed-1", jwks.Keys[0].Kid)
+}
+
+func TestKeySet_RotationKeepsOldKeyForVerification(t *testing.T) {
+	oldPrivate, oldPublic := rsaKeyFiles(t)
+	newPrivate, _ := rsaKeyFiles(t)
+
+	oldKeys, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "old", Alg: "RS256", PrivateKeyFile: oldPrivate}}, "")
+	assert.NoError(t, err)
+	issuedBeforeRotation, err := oldKeys.Sign(testClaims())
+	assert.NoError(t, err)
+
+	rotated, err := utils.NewKeySet([]utils.KeyConfig{
+		{Kid: "old", Alg: "RS256", PublicKeyFile: oldPublic},
+		{Kid: "new", Alg: "RS256", PrivateKeyFile: newPrivate},
+	}, "new")
+	assert.NoError(t, err)
+
+	token, err := jwt.Parse(issuedBeforeRotation, rotated.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+
+	issuedAfterRotation, err := rotated.Sign(testClaims())
+	assert.NoError(t, err)
+	token, err = jwt.Parse(issuedAfterRotation, rotated.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", token.Header["kid"])
+
+	assert.Len(t, rotated.JWKS().Keys, 2)
+}
+
+func TestKeySet_UnknownKidRejected(t *testing.T) {
+	first, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "a", Alg: "HS256", Secret: "secret-a"}}, "")
+	assert.NoError(t, err)
+	second, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "b", Alg: "HS256", Secret: "secret-b"}}, "")
+	assert.NoError(t, err)
+
+	signed, err := first.Sign(testClaims())
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(signed, second.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeySet_AlgorithmMismatchRejected(t *testing.T) {
+	private, _ := rsaKeyFiles(t)
+	keys, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "rsa-1", Alg: "RS256", PrivateKeyFile: private}}, "")
+	assert.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = "rsa-1"
+	signed, err := forged.SignedString([]byte("guess"))
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(signed, keys.Keyfunc)
+	assert.Error(t, err)
+}
+
+func TestKeySet_HMACNotPublished(t *testing.T) {
+	keys, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "hs", Alg: "HS256", Secret: "secret"}}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, keys.JWKS().Keys)
+}
+
+func TestKeySet_InvalidConfig(t *testing.T) {
+	_, err := utils.NewKeySet([]utils.KeyConfig{{Kid: "hs", Alg: "HS256", Secret: "secret"}}, "missing")
+	assert.Error(t, err)
+
+	_, err = utils.NewKeySet([]utils.KeyConfig{{Kid: "x", Alg: "none"}}, "")
+	assert.Error(t, err)
+
+	_, err = utils.NewKeySet(nil, "")
+	assert.Error(t, err)
+}
+
+func TestLoadKeySet_RequiresConfiguredKey(t *testing.T) {
+	t.Setenv("JWT_KEYS_FILE", "")
+	t.Setenv("JWT_SECRET", "")
+	_, err := utils.LoadKeySet()
+	assert.ErrorIs(t, err, utils.ErrNoSigningKey)
+
+	t.Setenv("JWT_SECRET", "secret")
+	keys, err := utils.LoadKeySet()
+	assert.NoError(t, err)
+	_, err = keys.Sign(testClaims())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"Shop/utils"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveWithRequestMeta(req *http.Request) (*httptest.ResponseRecorder, string, string) {
+	var requestID, clientIP string
+	handler := utils.RequestMetaMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = utils.RequestID(r.Context())
+		clientIP = utils.ClientIP(r.Context())
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w, requestID, clientIP
+}
+
+func TestRequestMetaMiddleware_KeepsIncomingID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+	req.RemoteAddr = "10.0.0.7:51234"
+	req.Header.Set(utils.RequestIDHeader, "req-123")
+
+	w, requestID, clientIP := serveWithRequestMeta(req)
+
+	assert.Equal(t, "req-123", requestID)
+	assert.Equal(t, "req-123", w.Header().Get(utils.RequestIDHeader))
+	assert.Equal(t, "10.0.0.7", clientIP)
+}
+
+func TestRequestMetaMiddleware_GeneratesID(t *testing.T) {
+	for _, incoming := range []string{"", "with space", strings.Repeat("a", 101)} {
+		req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+		req.Header.Set(utils.RequestIDHeader, incoming)
+
+		w, requestID, _ := serveWithRequestMeta(req)
+
+		assert.NotEmpty(t, requestID)
+		assert.NotEqual(t, incoming, requestID)
+		assert.Equal(t, requestID, w.Header().Get(utils.RequestIDHeader))
+	}
+}
@@ -0,0 +1,22 @@
+package utils_test
+
+import (
+	"Shop/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSanitizeMemo(t *testing.T) {
+	cases := map[string]string{
+		"":                     "",
+		"Спасибо!":             "Спасибо!",
+		"  много   пробелов  ": "много пробелов",
+		"строка\nвторая\r\nтретья":    "строка вторая третья",
+		"тихий\u0000символ\u0007":     "тихийсимвол",
+		"gnirts\u202e desrever\u200b": "gnirts desrever",
+		"\t\n":                        "",
+	}
+	for input, expected := range cases {
+		assert.Equal(t, expected, utils.SanitizeMemo(input), "вход: %q", input)
+	}
+}
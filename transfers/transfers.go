@@ -0,0 +1,203 @@
+package transfers
+
+import (
+	"Shop/audit"
+	"Shop/config"
+	"Shop/database/models"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrBlockedNotFound = errors.New("отклоненный перевод не найден")
+	ErrAlreadyReviewed = errors.New("отклоненный перевод уже разобран")
+	ErrNoteRequired    = errors.New("нужно указать комментарий до 255 символов")
+)
+
+// Request перевод, который проверяют правила.
+type Request struct {
+	Sender      models.User
+	RecipientID uuid.UUID
+	Amount      uint
+	Now         time.Time
+}
+
+// rule проверяет одно правило. nil — правило выключено или не нарушено.
+type rule func(tx *gorm.DB, request Request) (*models.TransferViolation, error)
+
+// rules правила переводов в порядке проверки: сначала те, которым не нужна история.
+var rules = []rule{
+	maxAmount,
+	newAccount,
+	dailyLimit,
+	weeklyLimit,
+	recipientLimit,
+	velocity,
+}
+
+// Check проверяет перевод всеми правилами и возвращает нарушенные. Кошелек отправителя
+// вызывающий блокирует заранее: тогда параллельные переводы того же сотрудника не пройдут
+// проверку по одной и той же истории.
+func Check(tx *gorm.DB, request Request) ([]models.TransferViolation, error) {
+	var violations []models.TransferViolation
+	for _, check := range rules {
+		violation, err := check(tx, request)
+		if err != nil {
+			return nil, err
+		}
+		if violation != nil {
+			violations = append(violations, *violation)
+		}
+	}
+	return violations, nil
+}
+
+func maxAmount(_ *gorm.DB, request Request) (*models.TransferViolation, error) {
+	limit := config.TransferMaxAmount()
+	if limit == 0 || request.Amount <= limit {
+		return nil, nil
+	}
+	return &models.TransferViolation{
+		Rule:    models.TRANSFER_RULE_MAX_AMOUNT,
+		Message: fmt.Sprintf("Одним переводом можно отправить не больше %d монет", limit),
+		Limit:   int64(limit),
+		Used:    int64(request.Amount),
+	}, nil
+}
+
+func newAccount(_ *gorm.DB, request Request) (*models.TransferViolation, error) {
+	cooldown := config.NewAccountTransferCooldown()
+	age := request.Now.Sub(request.Sender.CreatedAt)
+	if cooldown == 0 || age >= cooldown {
+		return nil, nil
+	}
+	return &models.TransferViolation{
+		Rule:    models.TRANSFER_RULE_NEW_ACCOUNT,
+		Message: fmt.Sprintf("Переводить монеты можно через %s после регистрации, осталось %s", cooldown, (cooldown - age).Round(time.Minute)),
+		Limit:   int64(cooldown / time.Second),
+		Used:    int64(age / time.Second),
+	}, nil
+}
+
+func dailyLimit(tx *gorm.DB, request Request) (*models.TransferViolation, error) {
+	return amountLimit(tx, request, config.TransferDailyLimit(), 24*time.Hour, uuid.Nil,
+		models.TRANSFER_RULE_DAILY_LIMIT, "За сутки можно перевести не больше %d монет, уже переведено %d")
+}
+
+func weeklyLimit(tx *gorm.DB, request Request) (*models.TransferViolation, error) {
+	return amountLimit(tx, request, config.TransferWeeklyLimit(), 7*24*time.Hour, uuid.Nil,
+		models.TRANSFER_RULE_WEEKLY_LIMIT, "За неделю можно перевести не больше %d монет, уже переведено %d")
+}
+
+func recipientLimit(tx *gorm.DB, request Request) (*models.TransferViolation, error) {
+	return amountLimit(tx, request, config.TransferRecipientDailyLimit(), 24*time.Hour, request.RecipientID,
+		models.TRANSFER_RULE_RECIPIENT_LIMIT, "Одному получателю за сутки можно перевести не больше %d монет, уже переведено %d")
+}
+
+func velocity(tx *gorm.DB, request Request) (*models.TransferViolation, error) {
+	limit := config.TransferMaxPerHour()
+	if limit == 0 {
+		return nil, nil
+	}
+	var count int64
+	if err := sentSince(tx, request, request.Now.Add(-time.Hour), uuid.Nil).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("число переводов за час: %w", err)
+	}
+	if count < int64(limit) {
+		return nil, nil
+	}
+	return &models.TransferViolation{
+		Rule:    models.TRANSFER_RULE_VELOCITY,
+		Message: fmt.Sprintf("За час можно сделать не больше %d переводов", limit),
+		Limit:   int64(limit),
+		Used:    count,
+	}, nil
+}
+
+// amountLimit нарушено ли ограничение суммы переводов отправителя за окно window до перевода.
+// recipientID — считать только переводы этому получателю, uuid.Nil — все.
+func amountLimit(tx *gorm.DB, request Request, limit uint, window time.Duration, recipientID uuid.UUID, rule, message string) (*models.TransferViolation, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+	var sent int64
+	if err := sentSince(tx, request, request.Now.Add(-window), recipientID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&sent).Error; err != nil {
+		return nil, fmt.Errorf("сумма переводов для правила %s: %w", rule, err)
+	}
+	if sent+int64(request.Amount) <= int64(limit) {
+		return nil, nil
+	}
+	return &models.TransferViolation{
+		Rule:    rule,
+		Message: fmt.Sprintf(message, limit, sent),
+		Limit:   int64(limit),
+		Used:    sent,
+	}, nil
+}
+
+// sentSince переводы отправителя другим сотрудникам после since.
+func sentSince(tx *gorm.DB, request Request, since time.Time, recipientID uuid.UUID) *gorm.DB {
+	query := tx.Model(&models.Transaction{}).
+		Where("from_user = ? AND kind = ? AND created_at > ?", request.Sender.ID, models.TRANSACTION_KIND_TRANSFER, since)
+	if recipientID != uuid.Nil {
+		query = query.Where("to_user = ?", recipientID)
+	}
+	return query
+}
+
+// Block сохраняет отклоненный перевод для разбора админом. Вызывается вне транзакции перевода:
+// она откатывается, а запись должна остаться.
+func Block(db *gorm.DB, request Request, violations []models.TransferViolation) (models.BlockedTransfer, error) {
+	blocked := models.BlockedTransfer{
+		SenderID:    request.Sender.ID,
+		RecipientID: request.RecipientID,
+		Amount:      request.Amount,
+		Violations:  violations,
+		Status:      models.BLOCKED_TRANSFER_OPEN,
+	}
+	err := db.Create(&blocked).Error
+	return blocked, err
+}
+
+// Review отмечает отклоненный перевод разобранным с комментарием админа. Перевод не выполняется:
+// если он правомерен, сотрудник повторяет его сам. Вызывать внутри транзакции.
+func Review(tx *gorm.DB, adminID, blockedID uuid.UUID, note string) (models.BlockedTransfer, error) {
+	var blocked models.BlockedTransfer
+	if note == "" || len([]rune(note)) > 255 {
+		return blocked, ErrNoteRequired
+	}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", blockedID).First(&blocked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return blocked, ErrBlockedNotFound
+	}
+	if err != nil {
+		return blocked, err
+	}
+	if blocked.Status != models.BLOCKED_TRANSFER_OPEN {
+		return blocked, ErrAlreadyReviewed
+	}
+
+	now := time.Now()
+	blocked.Status = models.BLOCKED_TRANSFER_REVIEWED
+	blocked.ReviewedBy = &adminID
+	blocked.ReviewedAt = &now
+	blocked.Note = note
+	if err := tx.Save(&blocked).Error; err != nil {
+		return blocked, err
+	}
+
+	return blocked, audit.Write(tx, adminID, audit.Event{
+		Action:     models.AUDIT_TRANSFER_REVIEW,
+		TargetType: "blocked_transfer",
+		TargetID:   blocked.ID.String(),
+		Before:     map[string]interface{}{"status": models.BLOCKED_TRANSFER_OPEN},
+		After:      map[string]interface{}{"status": blocked.Status, "note": note},
+		Details:    fmt.Sprintf("разобран перевод %d монет от %s к %s", blocked.Amount, blocked.SenderID, blocked.RecipientID),
+	})
+}
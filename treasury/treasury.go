@@ -0,0 +1,80 @@
+package treasury
+
+import (
+	"Shop/database/models"
+	"Shop/ledger"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+)
+
+var ErrInvalidKind = errors.New("вид операции казны должен быть grant, debit или expiry")
+
+// Record записывает в историю переводов операцию казны: начисление (grant) — перевод от казны
+// сотруднику, списание (debit) и сгорание монет (expiry) — от сотрудника казне. У сгорания нет
+// админа, adminID — uuid.Nil. Проводки в журнале делает вызывающий.
+func Record(tx *gorm.DB, kind string, adminID, userID uuid.UUID, amount uint, reason string) (models.Transaction, error) {
+	transaction := models.Transaction{
+		Kind:   kind,
+		Amount: amount,
+		Reason: reason,
+	}
+	if adminID != uuid.Nil {
+		transaction.AdminID = &adminID
+	}
+	switch kind {
+	case models.TRANSACTION_KIND_GRANT:
+		transaction.FromUser, transaction.ToUser = models.TreasuryUserID, userID
+	case models.TRANSACTION_KIND_DEBIT, models.TRANSACTION_KIND_EXPIRY:
+		transaction.FromUser, transaction.ToUser = userID, models.TreasuryUserID
+	default:
+		return transaction, ErrInvalidKind
+	}
+	err := tx.Create(&transaction).Error
+	return transaction, err
+}
+
+// Grant начисляет сотруднику amount монет со счета эмиссии по решению админа и показывает
+// начисление в его истории как перевод от казны. Кошелек получателя вызывающий блокирует заранее.
+func Grant(tx *gorm.DB, adminID, userID uuid.UUID, amount uint, reason string) (models.Transaction, models.JournalEntry, error) {
+	var entry models.JournalEntry
+	transaction, err := Record(tx, models.TRANSACTION_KIND_GRANT, adminID, userID, amount, reason)
+	if err != nil {
+		return transaction, entry, err
+	}
+
+	mint, err := ledger.SystemAccount(tx, models.SYSTEM_ACCOUNT_MINT)
+	if err != nil {
+		return transaction, entry, err
+	}
+	account, err := ledger.UserAccount(tx, userID)
+	if err != nil {
+		return transaction, entry, err
+	}
+	entry, err = ledger.Transfer(tx, mint, account, amount, models.ENTRY_MINT, transaction.ID.String())
+	return transaction, entry, err
+}
+
+// LockWallets блокирует кошельки сотрудников в порядке ID и возвращает их остатки. Начисления
+// нескольким сотрудникам в одной транзакции должны начинаться с нее: начисление берет
+// блокировку цепочки переводов, и кошелек, заблокированный после нее, мог бы привести
+// к взаимной блокировке с обычным переводом. Сотрудников без кошелька в ответе нет.
+func LockWallets(tx *gorm.DB, userIDs []uuid.UUID) (map[uuid.UUID]uint, error) {
+	sorted := append([]uuid.UUID(nil), userIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+	var wallets []models.Wallet
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id IN ?", sorted).
+		Order("user_id").
+		Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	coins := make(map[uuid.UUID]uint, len(wallets))
+	for _, wallet := range wallets {
+		coins[wallet.UserID] = wallet.Coin
+	}
+	return coins, nil
+}
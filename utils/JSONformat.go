@@ -7,6 +7,10 @@ import (
 )
 
 func JSONFormat(w http.ResponseWriter, r *http.Request, v interface{}) {
+	JSONFormatWithStatus(w, r, http.StatusOK, v)
+}
+
+func JSONFormatWithStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
 	formattedJSON, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		log.Println("Ошибка при форматировании JSON:", err)
@@ -14,7 +18,7 @@ func JSONFormat(w http.ResponseWriter, r *http.Request, v interface{}) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(status)
 	_, err = w.Write(formattedJSON)
 	if err != nil {
 		log.Println("Ошибка при JSON:", err)
@@ -1,10 +1,10 @@
 package utils
 
 import (
+	"Shop/config"
 	"Shop/database/migrations"
 	"Shop/database/models"
 	"context"
-	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"net/http"
@@ -19,19 +19,24 @@ const (
 	roleKey   contextKey = "role"
 )
 
-var jwtSecret = []byte("h8hjfdjiudfgh487&849fd04kfmfdo32nifsdnf3")
-
+// GenerateJWT выпускает короткоживущий access-токен. Для продления сессии используется refresh-токен.
 func GenerateJWT(userId uuid.UUID, email string) (string, error) {
 	claims := jwt.MapClaims{
 		"userID": userId,
 		"email":  email,
-		"exp":    time.Now().Add(time.Hour * 24).Unix(),
+		"typ":    "access",
+		"exp":    time.Now().Add(config.AccessTokenTTL()).Unix(),
+	}
+	keys, err := jwtKeys()
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return keys.Sign(claims)
 }
 
-func AuthMiddleware(requiredRole string) func(http.Handler) http.Handler {
+// AuthMiddleware проверяет access-токен и, если requiredPermission не пуст, наличие права
+// у роли пользователя. Права ролей хранятся в таблице role_permissions.
+func AuthMiddleware(requiredPermission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenString := r.Header.Get("Authorization")
@@ -53,12 +58,13 @@ func AuthMiddleware(requiredRole string) func(http.Handler) http.Handler {
 				return
 			}
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return jwtSecret, nil
-			})
+			keys, err := jwtKeys()
+			if err != nil {
+				http.Error(w, "token verification is not configured", http.StatusInternalServerError)
+				return
+			}
+
+			token, err := jwt.Parse(tokenString, keys.Keyfunc)
 
 			if err != nil || !token.Valid {
 				http.Error(w, "invalid token", http.StatusUnauthorized)
@@ -91,9 +97,16 @@ func AuthMiddleware(requiredRole string) func(http.Handler) http.Handler {
 
 			role := user.Role
 
-			if requiredRole != "" && role != requiredRole {
-				http.Error(w, "forbidden: insufficient permissions", http.StatusForbidden)
-				return
+			if requiredPermission != "" {
+				allowed, err := HasPermission(migrations.DB, role, requiredPermission)
+				if err != nil {
+					http.Error(w, "permission check failed", http.StatusInternalServerError)
+					return
+				}
+				if !allowed {
+					http.Error(w, "forbidden: insufficient permissions", http.StatusForbidden)
+					return
+				}
 			}
 
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// EncodeCursor упаковывает позицию keyset-пагинации в непрозрачную для клиента строку.
+func EncodeCursor(position interface{}) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor распаковывает строку, полученную из EncodeCursor.
+func DecodeCursor(cursor string, position interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, position)
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+// DeprecationMiddleware помечает ответы устаревшего маршрута заголовками Deprecation и Sunset
+// (RFC 8594) и ссылкой на маршрут, который его заменяет.
+func DeprecationMiddleware(sunset time.Time, successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"Shop/database/migrations"
+	"Shop/database/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKey = 255
+	// idempotencyAbandonedAfter через сколько незавершенный запрос считается брошенным (процесс
+	// упал, не записав ответ). Должно быть заметно больше самого долгого таймаута обработчика
+	// (60 секунд у массового начисления), иначе повтор перехватит ключ у запроса, который еще
+	// фиксирует транзакцию, и операция выполнится дважды.
+	idempotencyAbandonedAfter = 10 * time.Minute
+)
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	target := r.URL.Path
+	// Параметры запроса меняют его смысл (например, dryRun), но в отпечатки запросов без них
+	// не добавляются, чтобы сохраненные ключи остались действительными.
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	hash.Write([]byte(r.Method + " " + target + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// IdempotencyMiddleware повторяет сохраненный ответ для повторного запроса с тем же
+// заголовком Idempotency-Key. Ключ привязан к пользователю, поэтому middleware должна
+// подключаться после AuthMiddleware. Ответы 5xx не сохраняются, такой запрос можно повторить.
+// Ключ, ответ на который не записан дольше idempotencyAbandonedAfter, тоже можно использовать снова.
+func IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			http.Error(w, "Ключ идемпотентности длиннее 255 символов", http.StatusBadRequest)
+			return
+		}
+
+		userID, _ := r.Context().Value(UserIDKey).(uuid.UUID)
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Не удалось прочитать тело запроса", http.StatusBadRequest)
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		db := migrations.DB.WithContext(r.Context())
+		now := time.Now()
+		db.Where("user_id = ? AND key = ?", userID, key).
+			Where("created_at < ? OR (status_code = 0 AND created_at < ?)", now.Add(-idempotencyTTL), now.Add(-idempotencyAbandonedAfter)).
+			Delete(&models.IdempotencyKey{})
+
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Fingerprint: fingerprint,
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			http.Error(w, "Не удалось сохранить ключ идемпотентности", http.StatusInternalServerError)
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			var existing models.IdempotencyKey
+			if err := db.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+				http.Error(w, "Не удалось загрузить ключ идемпотентности", http.StatusInternalServerError)
+				return
+			}
+			switch {
+			case existing.Fingerprint != fingerprint:
+				http.Error(w, "Ключ идемпотентности уже использован для другого запроса", http.StatusUnprocessableEntity)
+			case existing.StatusCode == 0:
+				http.Error(w, "Запрос с этим ключом идемпотентности еще выполняется", http.StatusConflict)
+			default:
+				if existing.ContentType != "" {
+					w.Header().Set("Content-Type", existing.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(existing.StatusCode)
+				_, _ = w.Write(existing.Body)
+			}
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+			migrations.DB.Delete(&record)
+			return
+		}
+		migrations.DB.Model(&record).Updates(map[string]interface{}{
+			"status_code":  recorder.status,
+			"content_type": recorder.Header().Get("Content-Type"),
+			"body":         recorder.body.Bytes(),
+		})
+	})
+}
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+)
+
+// KeyConfig описание ключа в файле JWT_KEYS_FILE.
+//
+// Для HS256 задается secret, для RS256 и EdDSA — PEM-файлы. Ключ только с publicKeyFile
+// используется лишь для проверки подписи: так во время ротации старый ключ продолжает
+// принимать выданные им токены, пока они не истекут.
+type KeyConfig struct {
+	Kid            string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKeyFile string `json:"privateKeyFile,omitempty"`
+	PublicKeyFile  string `json:"publicKeyFile,omitempty"`
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet набор ключей: один активный для подписи и все известные для проверки.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// ErrNoSigningKey ключ подписи не настроен. Случайный ключ не подходит: токены не переживали бы
+// перезапуск, а при нескольких экземплярах один не принимал бы токены другого.
+var ErrNoSigningKey = errors.New("не задан ключ подписи JWT: укажите JWT_SECRET или JWT_KEYS_FILE")
+
+var (
+	keySetOnce sync.Once
+	keySetVal  *KeySet
+	keySetErr  error
+)
+
+// InitJWTKeys загружает ключи из окружения. Вызывается при старте сервера, чтобы
+// ошибка конфигурации обнаружилась сразу, а не на первом запросе.
+func InitJWTKeys() error {
+	_, err := jwtKeys()
+	return err
+}
+
+func jwtKeys() (*KeySet, error) {
+	keySetOnce.Do(func() {
+		keySetVal, keySetErr = LoadKeySet()
+	})
+	return keySetVal, keySetErr
+}
+
+// LoadKeySet читает ключи из JWT_KEYS_FILE (активный ключ выбирается JWT_ACTIVE_KID)
+// или, если файла нет, из JWT_SECRET. Без настроек возвращает ErrNoSigningKey.
+func LoadKeySet() (*KeySet, error) {
+	var configs []KeyConfig
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("чтение JWT_KEYS_FILE: %w", err)
+		}
+		if err := json.Unmarshal(data, &configs); err != nil {
+			return nil, fmt.Errorf("разбор JWT_KEYS_FILE: %w", err)
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		configs = []KeyConfig{{Kid: "default", Alg: "HS256", Secret: secret}}
+	} else {
+		return nil, ErrNoSigningKey
+	}
+
+	return NewKeySet(configs, os.Getenv("JWT_ACTIVE_KID"))
+}
+
+// NewKeySet собирает набор ключей. Если activeKid пуст, активным становится первый ключ,
+// которым можно подписывать.
+func NewKeySet(configs []KeyConfig, activeKid string) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*signingKey)}
+
+	for _, cfg := range configs {
+		if cfg.Kid == "" {
+			return nil, errors.New("у ключа JWT не указан kid")
+		}
+		if _, exists := set.keys[cfg.Kid]; exists {
+			return nil, fmt.Errorf("kid %q указан у нескольких ключей JWT", cfg.Kid)
+		}
+		key, err := parseKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("ключ JWT %q: %w", cfg.Kid, err)
+		}
+		set.keys[cfg.Kid] = key
+
+		if set.active == nil && key.private != nil && (activeKid == "" || activeKid == cfg.Kid) {
+			set.active = key
+		}
+	}
+
+	if set.active == nil {
+		if activeKid != "" {
+			return nil, fmt.Errorf("активный ключ JWT %q не найден или у него нет закрытой части", activeKid)
+		}
+		return nil, ErrNoSigningKey
+	}
+	return set, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+func parseKey(cfg KeyConfig) (*signingKey, error) {
+	key := &signingKey{kid: cfg.Kid}
+
+	privatePEM, err := readPEM(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM, err := readPEM(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Alg {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("для HS256 нужен secret")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.private = []byte(cfg.Secret)
+		key.public = []byte(cfg.Secret)
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if privatePEM != nil {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.private = private
+			key.public = &private.PublicKey
+		}
+		if publicPEM != nil {
+			public, err := jwt.ParseRSAPublicKeyFromPEM(publicPEM)
+			if err != nil {
+				return nil, err
+			}
+			key.public = public
+		}
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if privatePEM != nil {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			edPrivate, ok := private.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("закрытый ключ EdDSA не Ed25519")
+			}
+			key.private = edPrivate
+			key.public = edPrivate.Public()
+		}
+		if publicPEM != nil {
+			public, err := jwt.ParseEdPublicKeyFromPEM(publicPEM)
+			if err != nil {
+				return nil, err
+			}
+			key.public = public
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый alg %q", cfg.Alg)
+	}
+
+	if key.public == nil {
+		return nil, errors.New("у ключа нет ни закрытой, ни открытой части")
+	}
+	return key, nil
+}
+
+// Sign подписывает claims активным ключом и проставляет kid в заголовок.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.method, claims)
+	token.Header["kid"] = s.active.kid
+	return token.SignedString(s.active.private)
+}
+
+// Keyfunc выбирает ключ проверки по kid. Токены без kid проверяются активным ключом.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key := s.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = s.keys[kid]; !ok {
+			return nil, fmt.Errorf("неизвестный kid %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("неожиданный метод подписи: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWK публичный ключ в формате RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS набор публичных ключей для проверки токенов другими сервисами.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные части асимметричных ключей. HS256-ключи не публикуются.
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for kid, key := range s.keys {
+		jwk := JWK{Kid: kid, Alg: key.method.Alg(), Use: "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// PublicJWKS публичные ключи текущей конфигурации.
+func PublicJWKS() (JWKS, error) {
+	set, err := jwtKeys()
+	if err != nil {
+		return JWKS{}, err
+	}
+	return set.JWKS(), nil
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"Shop/database/models"
+	"gorm.io/gorm"
+)
+
+// HasPermission проверяет, входит ли право в роль.
+func HasPermission(db *gorm.DB, role, permission string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RolePermission{}).
+		Where("role_name = ? AND permission_name = ?", role, permission).
+		Count(&count).Error
+	return count > 0, err
+}
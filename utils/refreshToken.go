@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"Shop/config"
+	"Shop/database/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token not found")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// TokenPair ответ с парой токенов. Поле token оставлено под старым именем для совместимости клиентов.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func issueTokenPair(db *gorm.DB, user models.User, familyID uuid.UUID) (TokenPair, error) {
+	accessToken, err := GenerateJWT(user.ID, user.Email)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	raw, err := newRefreshToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(raw),
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresIn:    int64(config.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// IssueTokenPair выпускает access-токен и refresh-токен нового семейства (новый вход).
+func IssueTokenPair(db *gorm.DB, user models.User) (TokenPair, error) {
+	return issueTokenPair(db, user, uuid.New())
+}
+
+// RotateRefreshToken обменивает refresh-токен на новую пару. Токен одноразовый: повторное
+// предъявление уже использованного токена считается утечкой, и отзывается все семейство.
+func RotateRefreshToken(db *gorm.DB, raw string) (TokenPair, error) {
+	var pair TokenPair
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashRefreshToken(raw)).
+			First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if token.UsedAt != nil || token.RevokedAt != nil {
+			reused = true
+			return tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+				Update("revoked_at", now).Error
+		}
+		if now.After(token.ExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Where("id = ?", token.UserID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if reused {
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// RevokeRefreshToken отзывает семейство, к которому принадлежит refresh-токен.
+func RevokeRefreshToken(db *gorm.DB, raw string) error {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashRefreshToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenInvalid
+		}
+		return err
+	}
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
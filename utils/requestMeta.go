@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	RequestIDKey contextKey = "requestID"
+	ClientIPKey  contextKey = "clientIP"
+
+	maxRequestIDLength = 100
+)
+
+// RequestMetaMiddleware кладет в контекст ID запроса и IP клиента, чтобы их можно было записать
+// в журнал аудита. ID берется из заголовка X-Request-ID (если его выставил прокси) или генерируется
+// и возвращается клиенту в том же заголовке.
+func RequestMetaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+		ctx = context.WithValue(ctx, ClientIPKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID ID текущего запроса или пустая строка вне HTTP-запроса.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+// ClientIP IP клиента текущего запроса или пустая строка вне HTTP-запроса.
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ClientIPKey).(string)
+	return ip
+}
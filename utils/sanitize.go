@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SanitizeMemo приводит пользовательский текст к одной строке: управляющие и невидимые
+// символы форматирования (в том числе переключатели направления текста) удаляются,
+// переводы строк и пробелы схлопываются в один пробел.
+func SanitizeMemo(memo string) string {
+	var builder strings.Builder
+	space := false
+	for _, r := range memo {
+		switch {
+		case unicode.IsSpace(r):
+			space = builder.Len() > 0
+			continue
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		}
+		if space {
+			builder.WriteRune(' ')
+			space = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}